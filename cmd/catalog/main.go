@@ -0,0 +1,121 @@
+// Command catalog manages the plan catalog as code.
+//
+//	catalog export [-format yaml|json] [-o file]
+//	catalog diff -f catalog.yaml
+//	catalog apply -f catalog.yaml
+//
+// export prints the current catalog, diff shows what applying a catalog file
+// would change and apply makes those changes. Changing the sold terms of a
+// plan creates a new plan version; existing subscribers keep the old one.
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/Harshal292004/subscription-service/internal/catalog"
+	"github.com/Harshal292004/subscription-service/internal/config"
+	"github.com/Harshal292004/subscription-service/internal/repository"
+	"github.com/Harshal292004/subscription-service/internal/services"
+	"github.com/Harshal292004/subscription-service/internal/utils"
+	"github.com/sirupsen/logrus"
+)
+
+func main() {
+	if len(os.Args) < 2 {
+		usage()
+	}
+	command, args := os.Args[1], os.Args[2:]
+
+	flags := flag.NewFlagSet(command, flag.ExitOnError)
+	file := flags.String("f", "", "catalog file (.yaml, .yml or .json)")
+	format := flags.String("format", "yaml", "export format: yaml or json")
+	output := flags.String("o", "", "export to this file instead of stdout")
+	if err := flags.Parse(args); err != nil {
+		os.Exit(2)
+	}
+
+	utils.InitLogger()
+	logrus.SetOutput(os.Stderr)
+	config.LoadEnv()
+
+	db, err := config.InitPostgres()
+	if err != nil {
+		logrus.WithError(err).Fatal("failed to connect to PostgreSQL")
+	}
+	redisClient := config.InitRedis()
+	defer redisClient.Close()
+
+	service := services.NewCatalogService(repository.NewRepository(db, redisClient))
+
+	switch command {
+	case "export":
+		err = export(service, *format, *output)
+	case "diff", "apply":
+		if *file == "" {
+			logrus.Fatalf("%s needs -f <catalog file>", command)
+		}
+		err = diffOrApply(service, command, *file)
+	default:
+		usage()
+	}
+	if err != nil {
+		logrus.WithError(err).Fatalf("catalog %s failed", command)
+	}
+}
+
+func export(service *services.CatalogService, format, output string) error {
+	c, err := service.Export()
+	if err != nil {
+		return err
+	}
+
+	var w io.Writer = os.Stdout
+	if output != "" {
+		f, err := os.Create(output)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		w = f
+	}
+	return catalog.Write(w, c, format)
+}
+
+func diffOrApply(service *services.CatalogService, command, file string) error {
+	desired, err := catalog.Load(file)
+	if err != nil {
+		return err
+	}
+
+	var changes []catalog.Change
+	if command == "apply" {
+		changes, err = service.Apply(desired)
+	} else {
+		changes, err = service.Diff(desired)
+	}
+	if err != nil {
+		return err
+	}
+
+	if len(changes) == 0 {
+		fmt.Println("catalog is up to date")
+		return nil
+	}
+	for _, change := range changes {
+		fmt.Println(change)
+	}
+	if command == "apply" {
+		fmt.Printf("applied %d changes\n", len(changes))
+	}
+	return nil
+}
+
+func usage() {
+	fmt.Fprintln(os.Stderr, "usage: catalog export [-format yaml|json] [-o file]")
+	fmt.Fprintln(os.Stderr, "       catalog diff -f catalog.yaml")
+	fmt.Fprintln(os.Stderr, "       catalog apply -f catalog.yaml")
+	os.Exit(2)
+}
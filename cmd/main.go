@@ -6,6 +6,14 @@ package main
 // @host        localhost:3000
 // @BasePath    /api
 // @schemes     http
+
+// @securityDefinitions.apikey BearerAuth
+// @in                         header
+// @name                       Authorization
+
+// @securityDefinitions.apikey AdminToken
+// @in                         header
+// @name                       X-Admin-Token
 import (
 	"context"
 	"os"
@@ -67,14 +75,16 @@ func main() {
 
 	// Route registration
 	registerRoutes(app, repo)
-	if err := app.Listen(":3000"); err != nil {
-		logrus.WithError(err).Fatal("Fiber app failed")
-	}
 
 	// Start Cron Job
-	_, cancel := context.WithCancel(context.Background())
-	// defer cancel()
-	// go config.StartCronJobs(ctx, services.NewSubscriptionService(repo))
+	ctx, cancel := context.WithCancel(context.Background())
+	config.StartCronJobs(ctx, services.NewSubscriptionService(repo))
+
+	go func() {
+		if err := app.Listen(":3000"); err != nil {
+			logrus.WithError(err).Fatal("Fiber app failed")
+		}
+	}()
 
 	// Graceful shutdown
 	gracefulShutdown(app, cancel, db)
@@ -91,6 +101,11 @@ func registerRoutes(app *fiber.App, repo *repository.Repository) {
 	handlers.RegisterUserRoutes(api.Group("/user"), userService)
 	handlers.RegisterPlanRoutes(api.Group("/plans"), planService)
 	handlers.RegisterSubscriptionRoutes(api.Group("/subs"), subService)
+
+	admin := api.Group("/admin", middleware.AdminMiddleware())
+	handlers.RegisterAdminPlanRoutes(admin, planService)
+	handlers.RegisterAdminUserRoutes(admin, userService)
+	handlers.RegisterAdminSubscriptionRoutes(admin, subService)
 }
 func gracefulShutdown(app *fiber.App, cancel context.CancelFunc, db *gorm.DB) {
 	quit := make(chan os.Signal, 1)
@@ -0,0 +1,250 @@
+// Package catalog describes the plan catalog as code: a YAML or JSON file
+// holding the entitlement schema and the current version of every public
+// plan, and the changes needed to bring the database in line with it.
+package catalog
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/Harshal292004/subscription-service/internal/models"
+	"gopkg.in/yaml.v2"
+)
+
+type Catalog struct {
+	Entitlements []Entitlement `json:"entitlements" yaml:"entitlements"`
+	Plans        []Plan        `json:"plans" yaml:"plans"`
+}
+
+type Entitlement struct {
+	Key           string                 `json:"key" yaml:"key"`
+	Type          models.EntitlementType `json:"type" yaml:"type"`
+	AllowedValues []string               `json:"allowed_values,omitempty" yaml:"allowed_values,omitempty"`
+	Description   string                 `json:"description,omitempty" yaml:"description,omitempty"`
+}
+
+type Plan struct {
+	Code           string                 `json:"code" yaml:"code"`
+	Name           string                 `json:"name" yaml:"name"`
+	Price          float64                `json:"price" yaml:"price"`
+	Currency       string                 `json:"currency" yaml:"currency"`
+	PricingModel   models.PricingModel    `json:"pricing_model" yaml:"pricing_model"`
+	PriceTiers     []Tier                 `json:"price_tiers,omitempty" yaml:"price_tiers,omitempty"`
+	IntervalUnit   models.IntervalUnit    `json:"interval_unit,omitempty" yaml:"interval_unit,omitempty"`
+	IntervalCount  int                    `json:"interval_count,omitempty" yaml:"interval_count,omitempty"`
+	DurationDays   int                    `json:"duration_days,omitempty" yaml:"duration_days,omitempty"`
+	TrialDays      int                    `json:"trial_days,omitempty" yaml:"trial_days,omitempty"`
+	Features       []string               `json:"features" yaml:"features"`
+	Entitlements   map[string]interface{} `json:"entitlements" yaml:"entitlements"`
+	AvailableFrom  *time.Time             `json:"available_from,omitempty" yaml:"available_from,omitempty"`
+	AvailableUntil *time.Time             `json:"available_until,omitempty" yaml:"available_until,omitempty"`
+	MaxSubscribers *int                   `json:"max_subscribers,omitempty" yaml:"max_subscribers,omitempty"`
+	MinQuantity    int                    `json:"min_quantity,omitempty" yaml:"min_quantity,omitempty"`
+	MaxQuantity    *int                   `json:"max_quantity,omitempty" yaml:"max_quantity,omitempty"`
+}
+
+type Tier struct {
+	UpTo      *int    `json:"up_to,omitempty" yaml:"up_to,omitempty"`
+	UnitPrice float64 `json:"unit_price" yaml:"unit_price"`
+	FlatFee   float64 `json:"flat_fee,omitempty" yaml:"flat_fee,omitempty"`
+}
+
+// Load reads a catalog file, choosing the format from its extension.
+func Load(path string) (Catalog, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return Catalog{}, err
+	}
+
+	var c Catalog
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".json":
+		err = json.Unmarshal(data, &c)
+	case ".yaml", ".yml":
+		err = yaml.UnmarshalStrict(data, &c)
+	default:
+		return Catalog{}, fmt.Errorf("unsupported catalog format %q, use .yaml, .yml or .json", filepath.Ext(path))
+	}
+	if err != nil {
+		return Catalog{}, fmt.Errorf("parse %s: %w", path, err)
+	}
+	return c.normalize()
+}
+
+// Write encodes the catalog as "yaml" or "json".
+func Write(w io.Writer, c Catalog, format string) error {
+	switch format {
+	case "json":
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		return enc.Encode(c)
+	case "yaml", "yml":
+		data, err := yaml.Marshal(c)
+		if err != nil {
+			return err
+		}
+		_, err = w.Write(data)
+		return err
+	}
+	return fmt.Errorf("unsupported catalog format %q", format)
+}
+
+// normalize fills in defaults, sorts the catalog and round-trips it through
+// JSON so that numbers compare the same whichever format they came from.
+func (c Catalog) normalize() (Catalog, error) {
+	seen := map[string]bool{}
+	for i := range c.Plans {
+		p := &c.Plans[i]
+		if p.Code == "" {
+			return Catalog{}, fmt.Errorf("plan %d: code is required", i+1)
+		}
+		if seen[p.Code] {
+			return Catalog{}, fmt.Errorf("plan %q is listed twice", p.Code)
+		}
+		seen[p.Code] = true
+		if p.Currency == "" {
+			p.Currency = "USD"
+		}
+		if p.PricingModel == "" {
+			p.PricingModel = models.PricingFlat
+		}
+		if p.IntervalUnit != "" && p.IntervalCount == 0 {
+			p.IntervalCount = 1
+		}
+		if p.Features == nil {
+			p.Features = []string{}
+		}
+		if p.Entitlements == nil {
+			p.Entitlements = map[string]interface{}{}
+		}
+		p.AvailableFrom, p.AvailableUntil = utc(p.AvailableFrom), utc(p.AvailableUntil)
+	}
+	sort.Slice(c.Plans, func(i, j int) bool { return c.Plans[i].Code < c.Plans[j].Code })
+	sort.Slice(c.Entitlements, func(i, j int) bool { return c.Entitlements[i].Key < c.Entitlements[j].Key })
+
+	data, err := json.Marshal(c)
+	if err != nil {
+		return Catalog{}, err
+	}
+	var normalized Catalog
+	if err := json.Unmarshal(data, &normalized); err != nil {
+		return Catalog{}, err
+	}
+	return normalized, nil
+}
+
+func utc(t *time.Time) *time.Time {
+	if t == nil {
+		return nil
+	}
+	u := t.UTC()
+	return &u
+}
+
+// FromModels builds the catalog of the current plan versions.
+func FromModels(plans []models.Plan, defs []models.EntitlementDefinition) (Catalog, error) {
+	var c Catalog
+	for _, d := range defs {
+		c.Entitlements = append(c.Entitlements, Entitlement{
+			Key:           d.Key,
+			Type:          d.Type,
+			AllowedValues: d.AllowedValues,
+			Description:   d.Description,
+		})
+	}
+	for _, p := range plans {
+		if p.ArchivedAt != nil {
+			continue
+		}
+		spec, err := FromModel(p)
+		if err != nil {
+			return Catalog{}, err
+		}
+		c.Plans = append(c.Plans, spec)
+	}
+	return c.normalize()
+}
+
+func FromModel(p models.Plan) (Plan, error) {
+	var features []string
+	if len(p.Features) > 0 {
+		if err := json.Unmarshal(p.Features, &features); err != nil {
+			return Plan{}, fmt.Errorf("plan %q: features are not a list of strings: %w", p.Code, err)
+		}
+	}
+	var tiers []Tier
+	for _, t := range p.PriceTiers {
+		tiers = append(tiers, Tier{UpTo: t.UpTo, UnitPrice: t.UnitPrice, FlatFee: t.FlatFee})
+	}
+	spec := Plan{
+		Code:           p.Code,
+		Name:           p.Name,
+		Price:          p.Price,
+		Currency:       p.Currency,
+		PricingModel:   p.PricingModel,
+		PriceTiers:     tiers,
+		DurationDays:   p.Duration,
+		TrialDays:      p.TrialDays,
+		Features:       features,
+		Entitlements:   p.Entitlements,
+		AvailableFrom:  p.AvailableFrom,
+		AvailableUntil: p.AvailableUntil,
+		MaxSubscribers: p.MaxSubscribers,
+		MaxQuantity:    p.MaxQuantity,
+	}
+	if p.MinQuantity > 1 {
+		spec.MinQuantity = p.MinQuantity
+	}
+	if p.IntervalUnit != "" {
+		spec.IntervalUnit, spec.IntervalCount = p.Interval()
+	}
+	return spec, nil
+}
+
+// Model converts the spec to a plan row. Code and version are left to the
+// caller applying the change.
+func (p Plan) Model() models.Plan {
+	features, _ := json.Marshal(p.Features)
+	tiers := models.PriceTiers{}
+	for _, t := range p.PriceTiers {
+		tiers = append(tiers, models.PriceTier{UpTo: t.UpTo, UnitPrice: t.UnitPrice, FlatFee: t.FlatFee})
+	}
+	return models.Plan{
+		Code:           p.Code,
+		Name:           p.Name,
+		Price:          p.Price,
+		Currency:       p.Currency,
+		PricingModel:   p.PricingModel,
+		PriceTiers:     tiers,
+		Features:       features,
+		Duration:       p.DurationDays,
+		IntervalUnit:   p.IntervalUnit,
+		IntervalCount:  max(p.IntervalCount, 1),
+		Entitlements:   models.EntitlementSet(p.Entitlements),
+		TrialDays:      p.TrialDays,
+		AvailableFrom:  p.AvailableFrom,
+		AvailableUntil: p.AvailableUntil,
+		MaxSubscribers: p.MaxSubscribers,
+		MinQuantity:    max(p.MinQuantity, 1),
+		MaxQuantity:    p.MaxQuantity,
+	}
+}
+
+func (e Entitlement) Model() models.EntitlementDefinition {
+	values := e.AllowedValues
+	if values == nil {
+		values = []string{}
+	}
+	return models.EntitlementDefinition{
+		Key:           e.Key,
+		Type:          e.Type,
+		AllowedValues: values,
+		Description:   e.Description,
+	}
+}
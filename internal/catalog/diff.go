@@ -0,0 +1,164 @@
+package catalog
+
+import (
+	"encoding/json"
+	"fmt"
+	"slices"
+	"strings"
+)
+
+type Action string
+
+const (
+	CreatePlan        Action = "create"
+	NewPlanVersion    Action = "new_version"
+	UpdatePlan        Action = "update"
+	ArchivePlan       Action = "archive"
+	CreateEntitlement Action = "create_entitlement"
+	UpdateEntitlement Action = "update_entitlement"
+)
+
+// Change is one step of bringing the current catalog in line with the
+// desired one. Fields lists what differs for updates and new versions.
+type Change struct {
+	Action      Action       `json:"action"`
+	Code        string       `json:"code"`
+	Fields      []string     `json:"fields,omitempty"`
+	Plan        *Plan        `json:"plan,omitempty"`
+	Entitlement *Entitlement `json:"entitlement,omitempty"`
+}
+
+func (c Change) String() string {
+	fields := ""
+	if len(c.Fields) > 0 {
+		fields = " (" + strings.Join(c.Fields, ", ") + ")"
+	}
+	switch c.Action {
+	case CreatePlan:
+		return fmt.Sprintf("+ plan %s", c.Code)
+	case NewPlanVersion:
+		return fmt.Sprintf("~ plan %s: new version%s", c.Code, fields)
+	case UpdatePlan:
+		return fmt.Sprintf("~ plan %s: update in place%s", c.Code, fields)
+	case ArchivePlan:
+		return fmt.Sprintf("- plan %s: archive", c.Code)
+	case CreateEntitlement:
+		return fmt.Sprintf("+ entitlement %s", c.Code)
+	case UpdateEntitlement:
+		return fmt.Sprintf("~ entitlement %s%s", c.Code, fields)
+	}
+	return fmt.Sprintf("? %s %s", c.Action, c.Code)
+}
+
+// Diff lists the changes turning current into desired. Sold terms (price,
+// billing interval, trial, entitlements) are never edited: changing them
+// yields a new plan version. The name, feature list and availability of the
+// current version are updated in place. Plans missing from desired are
+// archived.
+func Diff(current, desired Catalog) ([]Change, error) {
+	var changes []Change
+
+	currentDefs := map[string]Entitlement{}
+	for _, e := range current.Entitlements {
+		currentDefs[e.Key] = e
+	}
+	for _, e := range desired.Entitlements {
+		e := e
+		existing, ok := currentDefs[e.Key]
+		if !ok {
+			changes = append(changes, Change{Action: CreateEntitlement, Code: e.Key, Entitlement: &e})
+			continue
+		}
+		if existing.Type != e.Type {
+			return nil, fmt.Errorf("entitlement %q: type cannot change from %s to %s", e.Key, existing.Type, e.Type)
+		}
+		for _, v := range existing.AllowedValues {
+			if !slices.Contains(e.AllowedValues, v) {
+				return nil, fmt.Errorf("entitlement %q: allowed value %q cannot be removed", e.Key, v)
+			}
+		}
+		var fields []string
+		if !slices.Equal(existing.AllowedValues, e.AllowedValues) {
+			fields = append(fields, "allowed_values")
+		}
+		if existing.Description != e.Description {
+			fields = append(fields, "description")
+		}
+		if len(fields) > 0 {
+			changes = append(changes, Change{Action: UpdateEntitlement, Code: e.Key, Fields: fields, Entitlement: &e})
+		}
+	}
+
+	currentPlans := map[string]Plan{}
+	for _, p := range current.Plans {
+		currentPlans[p.Code] = p
+	}
+	wanted := map[string]bool{}
+	for _, p := range desired.Plans {
+		p := p
+		wanted[p.Code] = true
+		existing, ok := currentPlans[p.Code]
+		if !ok {
+			changes = append(changes, Change{Action: CreatePlan, Code: p.Code, Plan: &p})
+			continue
+		}
+		if terms := ChangedTerms(existing, p); len(terms) > 0 {
+			changes = append(changes, Change{Action: NewPlanVersion, Code: p.Code, Fields: terms, Plan: &p})
+			continue
+		}
+		if fields := changedInPlace(existing, p); len(fields) > 0 {
+			changes = append(changes, Change{Action: UpdatePlan, Code: p.Code, Fields: fields, Plan: &p})
+		}
+	}
+	for _, p := range current.Plans {
+		if !wanted[p.Code] {
+			changes = append(changes, Change{Action: ArchivePlan, Code: p.Code})
+		}
+	}
+	return changes, nil
+}
+
+// ChangedTerms lists the sold terms that differ between two versions of a
+// plan. Subscribers keep the terms they bought, so changing any of them takes
+// a new plan version.
+func ChangedTerms(a, b Plan) []string {
+	var fields []string
+	check := func(name string, equal bool) {
+		if !equal {
+			fields = append(fields, name)
+		}
+	}
+	check("price", a.Price == b.Price)
+	check("currency", a.Currency == b.Currency)
+	check("pricing_model", a.PricingModel == b.PricingModel)
+	check("price_tiers", sameJSON(a.PriceTiers, b.PriceTiers))
+	check("interval", a.IntervalUnit == b.IntervalUnit && a.IntervalCount == b.IntervalCount)
+	check("duration_days", a.DurationDays == b.DurationDays)
+	check("trial_days", a.TrialDays == b.TrialDays)
+	check("quantity", max(a.MinQuantity, 1) == max(b.MinQuantity, 1) && sameJSON(a.MaxQuantity, b.MaxQuantity))
+	check("entitlements", sameJSON(a.Entitlements, b.Entitlements))
+	return fields
+}
+
+func changedInPlace(a, b Plan) []string {
+	var fields []string
+	if a.Name != b.Name {
+		fields = append(fields, "name")
+	}
+	if !slices.Equal(a.Features, b.Features) {
+		fields = append(fields, "features")
+	}
+	if !sameJSON(a.AvailableFrom, b.AvailableFrom) || !sameJSON(a.AvailableUntil, b.AvailableUntil) {
+		fields = append(fields, "availability")
+	}
+	if !sameJSON(a.MaxSubscribers, b.MaxSubscribers) {
+		fields = append(fields, "max_subscribers")
+	}
+	return fields
+}
+
+func sameJSON(a, b interface{}) bool {
+	x, errA := json.Marshal(a)
+	y, errB := json.Marshal(b)
+	return errA == nil && errB == nil && string(x) == string(y)
+}
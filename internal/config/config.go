@@ -1,10 +1,15 @@
 package config
 
 import (
+	"context"
 	"fmt"
+	"log"
 	"os"
 
+	"github.com/Harshal292004/subscription-service/internal/services"
 	"github.com/redis/go-redis/v9"
+	"github.com/robfig/cron"
+	"github.com/sirupsen/logrus"
 	"gorm.io/driver/postgres"
 	"gorm.io/gorm"
 )
@@ -71,21 +76,42 @@ func InitRedis() *redis.Client {
 	return NewRedisConnection(&configuration)
 }
 
-// func StartCronJobs(ctx context.Context, subService *services.SubscriptionService) {
-// 	c := cron.New()
-// 	err := c.AddFunc("@every 1h", func() {
-// 		log.Println("Running CheckExpiredSubscriptions job")
-// 		if err := subService.CheckExpiredSubscriptions(); err != nil {
-// 			log.Printf("Error running CheckExpiredSubscriptions: %v", err)
-// 		}
-// 	})
-// 	if err != nil {
-// 		log.Fatalf("Failed to schedule cron: %v", err)
-// 	}
-// 	c.Start()
-// 	go func() {
-// 		<-ctx.Done()
-// 		logrus.Info("Stopping cron jobs...")
-// 		c.Stop()
-// 	}()
-// }
+// cronJob is a background job and how often it runs.
+type cronJob struct {
+	name     string
+	schedule string
+	run      func() error
+}
+
+func StartCronJobs(ctx context.Context, subService *services.SubscriptionService) {
+	jobs := []cronJob{
+		{"ProcessPlanSunsets", "@every 5m", subService.ProcessPlanSunsets},
+		{"DeliverNotifications", "@every 1m", subService.DeliverNotifications},
+		{"RunMigrationJobs", "@every 1m", subService.RunMigrationJobs},
+		{"ApplyPriceChanges", "@every 5m", subService.ApplyPriceChanges},
+		{"EndCancelledSubscriptions", "@every 5m", subService.EndCancelledSubscriptions},
+		{"ResumePausedSubscriptions", "@every 5m", subService.ResumePausedSubscriptions},
+		{"RenewSubscriptions", "@every 5m", subService.RenewSubscriptions},
+		{"CheckExpiredSubscriptions", "@every 1h", subService.CheckExpiredSubscriptions},
+		{"EndDunning", "@every 5m", subService.EndDunning},
+	}
+
+	c := cron.New()
+	for _, job := range jobs {
+		err := c.AddFunc(job.schedule, func() {
+			log.Printf("Running %s job", job.name)
+			if err := job.run(); err != nil {
+				log.Printf("Error running %s: %v", job.name, err)
+			}
+		})
+		if err != nil {
+			log.Fatalf("Failed to schedule %s: %v", job.name, err)
+		}
+	}
+	c.Start()
+	go func() {
+		<-ctx.Done()
+		logrus.Info("Stopping cron jobs...")
+		c.Stop()
+	}()
+}
@@ -1,34 +1,1016 @@
 package handlers
 
 import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"strconv"
+	"time"
+
+	"github.com/Harshal292004/subscription-service/internal/middleware"
+	"github.com/Harshal292004/subscription-service/internal/models"
+	"github.com/Harshal292004/subscription-service/internal/repository"
 	"github.com/Harshal292004/subscription-service/internal/services"
+	"github.com/Harshal292004/subscription-service/internal/utils"
 	"github.com/gofiber/fiber/v2"
+	"gorm.io/gorm"
 )
 
 type PlanHandler struct {
 	service *services.PlanService
 }
 
+type PlanInput struct {
+	Code           string                `json:"code" validate:"max=100"`
+	Name           string                `json:"name" validate:"required,max=100"`
+	Price          float64               `json:"price" validate:"gte=0"`
+	Currency       string                `json:"currency" validate:"omitempty,len=3,uppercase"`
+	PricingModel   models.PricingModel   `json:"pricing_model" validate:"omitempty,oneof=flat per_unit graduated volume"`
+	PriceTiers     []models.PriceTier    `json:"price_tiers"`
+	Features       []string              `json:"features"`
+	Duration       int                   `json:"duration_days" validate:"gte=0"`
+	IntervalUnit   models.IntervalUnit   `json:"interval_unit" validate:"omitempty,oneof=day week month year"`
+	IntervalCount  int                   `json:"interval_count" validate:"gte=0"`
+	Entitlements   models.EntitlementSet `json:"entitlements" swaggertype:"object"`
+	TrialDays      int                   `json:"trial_days" validate:"gte=0"`
+	AvailableFrom  *time.Time            `json:"available_from"`
+	AvailableUntil *time.Time            `json:"available_until"`
+	MaxSubscribers *int                  `json:"max_subscribers" validate:"omitempty,gte=1"`
+	MinQuantity    int                   `json:"min_quantity" validate:"gte=0"`
+	MaxQuantity    *int                  `json:"max_quantity" validate:"omitempty,gte=1"`
+}
+
+// PrivatePlanInput holds the negotiated terms of a private plan. Omitted
+// fields keep the terms of the base plan.
+type PrivatePlanInput struct {
+	Code           string                `json:"code" validate:"max=100"`
+	Name           string                `json:"name" validate:"max=100"`
+	Price          *float64              `json:"price" validate:"omitempty,gte=0"`
+	PriceTiers     []models.PriceTier    `json:"price_tiers"`
+	Entitlements   models.EntitlementSet `json:"entitlements" swaggertype:"object"`
+	OwnerUserID    *uint                 `json:"owner_user_id"`
+	OrganizationID *uint                 `json:"organization_id"`
+	AvailableUntil *time.Time            `json:"available_until"`
+}
+
+type AddOnInput struct {
+	Code          string                `json:"code" validate:"required,max=100"`
+	Name          string                `json:"name" validate:"required,max=100"`
+	Price         float64               `json:"price" validate:"gte=0"`
+	Entitlements  models.EntitlementSet `json:"entitlements" swaggertype:"object"`
+	Compatibility []AddOnRuleInput      `json:"compatibility" validate:"dive"`
+}
+
+type PlanTranslationInput struct {
+	Name     string   `json:"name" validate:"required,max=100"`
+	Features []string `json:"features"`
+}
+
+type EntitlementTranslationInput struct {
+	Description string `json:"description" validate:"required"`
+}
+
+type ExperimentInput struct {
+	Name     string         `json:"name" validate:"required,max=100"`
+	PlanID   uint           `json:"plan_id" validate:"required"`
+	Variants []VariantInput `json:"variants" validate:"min=2,dive"`
+}
+
+type VariantInput struct {
+	Name   string  `json:"name" validate:"required,max=100"`
+	Price  float64 `json:"price" validate:"gte=0"`
+	Weight int     `json:"weight" validate:"gte=1"`
+}
+
+type SunsetInput struct {
+	PlanID            uint `json:"plan_id" validate:"required"`
+	ReplacementPlanID uint `json:"replacement_plan_id" validate:"required"`
+	NoticeDays        *int `json:"notice_days" validate:"omitempty,gte=0"`
+}
+
+type PriceChangeInput struct {
+	PlanID        uint    `json:"plan_id" validate:"required"`
+	NewPrice      float64 `json:"new_price" validate:"gte=0"`
+	NoticeDays    *int    `json:"notice_days" validate:"omitempty,gte=0"`
+	ExemptUserIDs []uint  `json:"exempt_user_ids"`
+}
+
+type AddOnRuleInput struct {
+	PlanID      uint `json:"plan_id" validate:"required"`
+	MaxQuantity int  `json:"max_quantity" validate:"gte=1"`
+}
+
 // RegisterPlanRoutes godoc
 // @Summary     Get all available plans
 // @Tags        plans
 func RegisterPlanRoutes(r fiber.Router, service *services.PlanService) {
 	h := &PlanHandler{service}
+	r.Use(middleware.OptionalAuthMiddleware())
 	r.Get("/plans", h.GetAllPlans)
+	r.Get("/entitlements", h.GetEntitlementDefinitions)
+	r.Get("/addons", h.GetAddOns)
+	r.Get("/:id/quote", h.GetQuote)
+	r.Get("/:id", h.GetPlan)
+}
+
+// RegisterAdminPlanRoutes godoc
+// @Summary     Manage plans and the entitlement schema
+// @Tags        admin
+func RegisterAdminPlanRoutes(r fiber.Router, service *services.PlanService) {
+	h := &PlanHandler{service}
+	r.Post("/plans", h.CreatePlan)
+	r.Put("/plans/:id", h.UpdatePlan)
+	r.Post("/plans/:id/private", h.CreatePrivatePlan)
+	r.Put("/plans/:id/translations/:locale", h.PutPlanTranslation)
+	r.Post("/entitlements", h.CreateEntitlementDefinition)
+	r.Put("/entitlements/:key/translations/:locale", h.PutEntitlementTranslation)
+	r.Post("/addons", h.CreateAddOn)
+	r.Get("/transitions", h.GetPlanTransitions)
+	r.Put("/transitions", h.PutPlanTransition)
+	r.Delete("/transitions/:from/:to", h.DeletePlanTransition)
+	r.Get("/experiments", h.GetExperiments)
+	r.Post("/experiments", h.CreateExperiment)
+	r.Post("/experiments/:id/stop", h.StopExperiment)
+	r.Get("/experiments/:id/report", h.GetExperimentReport)
+	r.Get("/sunsets", h.GetPlanSunsets)
+	r.Post("/sunsets", h.CreatePlanSunset)
+	r.Get("/sunsets/:id", h.GetPlanSunset)
+	r.Get("/price-changes", h.GetPriceChanges)
+	r.Post("/price-changes", h.CreatePriceChange)
+	r.Get("/price-changes/:id", h.GetPriceChange)
+	r.Put("/price-changes/:id/exemptions/:userId", h.ExemptFromPriceChange)
 }
 
 // GetAllPlans godoc
 // @Summary     Retrieve all plans
+// @Description Supports filtering and sorting. Responses carry a strong ETag; send it back in If-None-Match to get a 304 when the catalog is unchanged. Send a bearer token to see the prices of your price experiment variants and the private plans offered to you.
 // @Tags        plans
 // @Accept      json
 // @Produce     json
+// @Param       min_price       query  number false "Minimum price"
+// @Param       max_price       query  number false "Maximum price"
+// @Param       currency        query  string false "ISO currency code"
+// @Param       interval        query  string false "Billing interval unit (day, week, month, year)"
+// @Param       feature         query  string false "Entitlement key or feature name the plan must grant"
+// @Param       sort            query  string false "Comma separated sort keys (id, name, price, created_at), prefix with - for descending"
+// @Param       locale          query  string false "Locale, overrides Accept-Language"
+// @Param       Accept-Language header string false "Preferred locales"
+// @Param       If-None-Match   header string false "ETag of a previous response"
 // @Success     200 {array} models.Plan
+// @Success     304
+// @Failure     400 {object} map[string]string
 // @Failure     500 {object} map[string]string
 // @Router      /api/plans/plans [get]
 func (h *PlanHandler) GetAllPlans(c *fiber.Ctx) error {
-	plans, err := h.service.GetAllPlans()
+	filter, err := parsePlanFilter(c)
+	if err != nil {
+		return c.Status(400).JSON(fiber.Map{"error": err.Error()})
+	}
+
+	locale, err := h.resolveLocale(c)
+	if err != nil {
+		return c.Status(planErrorStatus(err)).JSON(fiber.Map{"error": err.Error()})
+	}
+
+	userId, _ := c.Locals("userId").(int)
+	return h.sendCacheable(c, locale, userId, func() (interface{}, error) {
+		return h.service.FindPlans(filter, c.Query("sort"), locale, userId)
+	})
+}
+
+// GetPlan godoc
+// @Summary     Retrieve a plan
+// @Description Archived plan versions stay readable for their subscribers. Private plans are only found by the users they are offered to. Supports If-None-Match like the plan listing.
+// @Tags        plans
+// @Produce     json
+// @Param       id              path   int    true  "Plan ID"
+// @Param       locale          query  string false "Locale, overrides Accept-Language"
+// @Param       Accept-Language header string false "Preferred locales"
+// @Param       If-None-Match   header string false "ETag of a previous response"
+// @Success     200 {object} models.Plan
+// @Success     304
+// @Failure     400 {object} map[string]string
+// @Failure     404 {object} map[string]string
+// @Failure     500 {object} map[string]string
+// @Router      /api/plans/{id} [get]
+func (h *PlanHandler) GetPlan(c *fiber.Ctx) error {
+	planId, err := c.ParamsInt("id")
+	if err != nil || planId <= 0 {
+		return c.Status(400).JSON(fiber.Map{"error": "Invalid plan id"})
+	}
+
+	locale, err := h.resolveLocale(c)
+	if err != nil {
+		return c.Status(planErrorStatus(err)).JSON(fiber.Map{"error": err.Error()})
+	}
+
+	userId, _ := c.Locals("userId").(int)
+	return h.sendCacheable(c, locale, userId, func() (interface{}, error) {
+		return h.service.GetPlan(planId, locale, userId)
+	})
+}
+
+// GetQuote godoc
+// @Summary     Quote the price of a plan
+// @Description Computes the charge for a quantity (seats, units) under the plan's pricing model
+// @Tags        plans
+// @Produce     json
+// @Param       id       path  int true  "Plan ID"
+// @Param       quantity query int false "Quantity, defaults to 1"
+// @Success     200 {object} models.PriceQuote
+// @Failure     400 {object} map[string]string
+// @Failure     404 {object} map[string]string
+// @Failure     500 {object} map[string]string
+// @Router      /api/plans/{id}/quote [get]
+func (h *PlanHandler) GetQuote(c *fiber.Ctx) error {
+	planId, err := c.ParamsInt("id")
+	if err != nil || planId <= 0 {
+		return c.Status(400).JSON(fiber.Map{"error": "Invalid plan id"})
+	}
+	quantity := c.QueryInt("quantity", 1)
+	userId, _ := c.Locals("userId").(int)
+
+	quote, err := h.service.QuotePlan(planId, quantity, userId)
+	if err != nil {
+		return c.Status(planErrorStatus(err)).JSON(fiber.Map{"error": err.Error()})
+	}
+	return c.JSON(fiber.Map{"data": quote})
+}
+
+// GetEntitlementDefinitions godoc
+// @Summary     Retrieve the entitlement schema
+// @Tags        plans
+// @Produce     json
+// @Param       locale          query  string false "Locale, overrides Accept-Language"
+// @Param       Accept-Language header string false "Preferred locales"
+// @Success     200 {array} models.EntitlementDefinition
+// @Failure     400 {object} map[string]string
+// @Failure     500 {object} map[string]string
+// @Router      /api/plans/entitlements [get]
+func (h *PlanHandler) GetEntitlementDefinitions(c *fiber.Ctx) error {
+	locale, err := h.resolveLocale(c)
+	if err != nil {
+		return c.Status(planErrorStatus(err)).JSON(fiber.Map{"error": err.Error()})
+	}
+
+	defs, err := h.service.GetEntitlementDefinitions(locale)
 	if err != nil {
 		return c.Status(500).JSON(fiber.Map{"error": err.Error()})
 	}
-	return c.JSON(fiber.Map{"data": plans})
+	return c.JSON(fiber.Map{"data": defs})
+}
+
+// CreatePlan godoc
+// @Summary     Create a plan
+// @Description Entitlements are validated against the entitlement schema
+// @Tags        admin
+// @Accept      json
+// @Produce     json
+// @Param       input body PlanInput true "Plan"
+// @Success     201 {object} models.Plan
+// @Failure     400 {object} map[string]string
+// @Failure     500 {object} map[string]string
+// @Router      /api/admin/plans [post]
+// @Security    AdminToken
+func (h *PlanHandler) CreatePlan(c *fiber.Ctx) error {
+	plan, err := parsePlanInput(c)
+	if err != nil {
+		return c.Status(400).JSON(fiber.Map{"error": err.Error()})
+	}
+
+	plan, err = h.service.CreatePlan(plan)
+	if err != nil {
+		return c.Status(planErrorStatus(err)).JSON(fiber.Map{"error": err.Error()})
+	}
+	return c.Status(201).JSON(fiber.Map{"data": plan})
+}
+
+// UpdatePlan godoc
+// @Summary     Update a plan
+// @Description Entitlements are validated against the entitlement schema. Sold terms (price, currency, pricing, interval, trial, entitlements and seat bounds) cannot change on archived versions or on plans with subscribers; publish a new version through the catalog instead.
+// @Tags        admin
+// @Accept      json
+// @Produce     json
+// @Param       id    path int       true "Plan ID"
+// @Param       input body PlanInput true "Plan"
+// @Success     200 {object} models.Plan
+// @Failure     400 {object} map[string]string
+// @Failure     404 {object} map[string]string
+// @Failure     500 {object} map[string]string
+// @Router      /api/admin/plans/{id} [put]
+// @Security    AdminToken
+func (h *PlanHandler) UpdatePlan(c *fiber.Ctx) error {
+	planId, err := c.ParamsInt("id")
+	if err != nil || planId <= 0 {
+		return c.Status(400).JSON(fiber.Map{"error": "Invalid plan id"})
+	}
+
+	plan, err := parsePlanInput(c)
+	if err != nil {
+		return c.Status(400).JSON(fiber.Map{"error": err.Error()})
+	}
+
+	plan, err = h.service.UpdatePlan(planId, plan)
+	if err != nil {
+		return c.Status(planErrorStatus(err)).JSON(fiber.Map{"error": err.Error()})
+	}
+	return c.JSON(fiber.Map{"data": plan})
+}
+
+// CreatePrivatePlan godoc
+// @Summary     Create a private plan for a user or organization
+// @Description Derives a plan from a public base plan with a negotiated price and entitlements. Entitlements are merged over the base plan's. Private plans are listed and sold only to their owner, or to the members of their organization, and never appear in the public catalog.
+// @Tags        admin
+// @Accept      json
+// @Produce     json
+// @Param       id    path int              true "Base plan ID"
+// @Param       input body PrivatePlanInput true "Private plan terms"
+// @Success     201 {object} models.Plan
+// @Failure     400 {object} map[string]string
+// @Failure     404 {object} map[string]string
+// @Failure     500 {object} map[string]string
+// @Router      /api/admin/plans/{id}/private [post]
+// @Security    AdminToken
+func (h *PlanHandler) CreatePrivatePlan(c *fiber.Ctx) error {
+	basePlanId, err := c.ParamsInt("id")
+	if err != nil || basePlanId <= 0 {
+		return c.Status(400).JSON(fiber.Map{"error": "Invalid plan id"})
+	}
+
+	var input PrivatePlanInput
+	if err := c.BodyParser(&input); err != nil {
+		return c.Status(400).JSON(fiber.Map{"error": "Invalid input"})
+	}
+	if err := utils.ValidateStruct(input); err != nil {
+		return c.Status(400).JSON(fiber.Map{"error": err.Error()})
+	}
+
+	plan, err := h.service.CreatePrivatePlan(basePlanId, models.PrivatePlanTerms{
+		Code:           input.Code,
+		Name:           input.Name,
+		Price:          input.Price,
+		PriceTiers:     input.PriceTiers,
+		Entitlements:   input.Entitlements,
+		OwnerUserID:    input.OwnerUserID,
+		OrganizationID: input.OrganizationID,
+		AvailableUntil: input.AvailableUntil,
+	})
+	if err != nil {
+		return c.Status(planErrorStatus(err)).JSON(fiber.Map{"error": err.Error()})
+	}
+	return c.Status(201).JSON(fiber.Map{"data": plan})
+}
+
+// CreateEntitlementDefinition godoc
+// @Summary     Add an entitlement to the schema
+// @Tags        admin
+// @Accept      json
+// @Produce     json
+// @Param       input body models.EntitlementDefinition true "Entitlement definition"
+// @Success     201 {object} models.EntitlementDefinition
+// @Failure     400 {object} map[string]string
+// @Failure     500 {object} map[string]string
+// @Router      /api/admin/entitlements [post]
+// @Security    AdminToken
+func (h *PlanHandler) CreateEntitlementDefinition(c *fiber.Ctx) error {
+	var def models.EntitlementDefinition
+	if err := c.BodyParser(&def); err != nil {
+		return c.Status(400).JSON(fiber.Map{"error": "Invalid input"})
+	}
+	if err := utils.ValidateStruct(def); err != nil {
+		return c.Status(400).JSON(fiber.Map{"error": err.Error()})
+	}
+
+	def, err := h.service.CreateEntitlementDefinition(def)
+	if err != nil {
+		return c.Status(planErrorStatus(err)).JSON(fiber.Map{"error": err.Error()})
+	}
+	return c.Status(201).JSON(fiber.Map{"data": def})
+}
+
+// PutPlanTranslation godoc
+// @Summary     Set the translation of a plan
+// @Description The translation is keyed by plan code and applies to every version of the plan. Features left empty fall back to the plan's own list.
+// @Tags        admin
+// @Accept      json
+// @Produce     json
+// @Param       id     path int                  true "Plan ID"
+// @Param       locale path string               true "Locale, e.g. fr or pt-BR"
+// @Param       input  body PlanTranslationInput true "Translation"
+// @Success     200 {object} models.PlanTranslation
+// @Failure     400 {object} map[string]string
+// @Failure     404 {object} map[string]string
+// @Failure     500 {object} map[string]string
+// @Router      /api/admin/plans/{id}/translations/{locale} [put]
+// @Security    AdminToken
+func (h *PlanHandler) PutPlanTranslation(c *fiber.Ctx) error {
+	planId, err := c.ParamsInt("id")
+	if err != nil || planId <= 0 {
+		return c.Status(400).JSON(fiber.Map{"error": "Invalid plan id"})
+	}
+	locale, err := models.NormalizeLocale(c.Params("locale"))
+	if err != nil {
+		return c.Status(400).JSON(fiber.Map{"error": "Invalid locale"})
+	}
+
+	var input PlanTranslationInput
+	if err := c.BodyParser(&input); err != nil {
+		return c.Status(400).JSON(fiber.Map{"error": "Invalid input"})
+	}
+	if err := utils.ValidateStruct(input); err != nil {
+		return c.Status(400).JSON(fiber.Map{"error": err.Error()})
+	}
+	if input.Features == nil {
+		input.Features = []string{}
+	}
+	features, err := json.Marshal(input.Features)
+	if err != nil {
+		return c.Status(400).JSON(fiber.Map{"error": err.Error()})
+	}
+
+	translation, err := h.service.SetPlanTranslation(planId, models.PlanTranslation{
+		Locale:   locale,
+		Name:     input.Name,
+		Features: features,
+	})
+	if err != nil {
+		return c.Status(planErrorStatus(err)).JSON(fiber.Map{"error": err.Error()})
+	}
+	return c.JSON(fiber.Map{"data": translation})
+}
+
+// PutEntitlementTranslation godoc
+// @Summary     Set the translated description of an entitlement
+// @Tags        admin
+// @Accept      json
+// @Produce     json
+// @Param       key    path string                      true "Entitlement key"
+// @Param       locale path string                      true "Locale, e.g. fr or pt-BR"
+// @Param       input  body EntitlementTranslationInput true "Translation"
+// @Success     200 {object} models.EntitlementTranslation
+// @Failure     400 {object} map[string]string
+// @Failure     404 {object} map[string]string
+// @Failure     500 {object} map[string]string
+// @Router      /api/admin/entitlements/{key}/translations/{locale} [put]
+// @Security    AdminToken
+func (h *PlanHandler) PutEntitlementTranslation(c *fiber.Ctx) error {
+	locale, err := models.NormalizeLocale(c.Params("locale"))
+	if err != nil {
+		return c.Status(400).JSON(fiber.Map{"error": "Invalid locale"})
+	}
+
+	var input EntitlementTranslationInput
+	if err := c.BodyParser(&input); err != nil {
+		return c.Status(400).JSON(fiber.Map{"error": "Invalid input"})
+	}
+	if err := utils.ValidateStruct(input); err != nil {
+		return c.Status(400).JSON(fiber.Map{"error": err.Error()})
+	}
+
+	translation, err := h.service.SetEntitlementTranslation(models.EntitlementTranslation{
+		EntitlementKey: c.Params("key"),
+		Locale:         locale,
+		Description:    input.Description,
+	})
+	if err != nil {
+		return c.Status(planErrorStatus(err)).JSON(fiber.Map{"error": err.Error()})
+	}
+	return c.JSON(fiber.Map{"data": translation})
+}
+
+// GetAddOns godoc
+// @Summary     Retrieve the add-on catalog
+// @Description Pass planId to list only the add-ons available on that plan
+// @Tags        plans
+// @Produce     json
+// @Param       planId query int false "Base plan ID"
+// @Success     200 {array} models.AddOn
+// @Failure     500 {object} map[string]string
+// @Router      /api/plans/addons [get]
+func (h *PlanHandler) GetAddOns(c *fiber.Ctx) error {
+	addOns, err := h.service.GetAddOns(c.QueryInt("planId"))
+	if err != nil {
+		return c.Status(500).JSON(fiber.Map{"error": err.Error()})
+	}
+	return c.JSON(fiber.Map{"data": addOns})
+}
+
+// CreateAddOn godoc
+// @Summary     Create an add-on
+// @Description compatibility lists the base plans offering the add-on and the units allowed on each
+// @Tags        admin
+// @Accept      json
+// @Produce     json
+// @Param       input body AddOnInput true "Add-on"
+// @Success     201 {object} models.AddOn
+// @Failure     400 {object} map[string]string
+// @Failure     500 {object} map[string]string
+// @Router      /api/admin/addons [post]
+// @Security    AdminToken
+func (h *PlanHandler) CreateAddOn(c *fiber.Ctx) error {
+	var input AddOnInput
+	if err := c.BodyParser(&input); err != nil {
+		return c.Status(400).JSON(fiber.Map{"error": "Invalid input"})
+	}
+	if err := utils.ValidateStruct(input); err != nil {
+		return c.Status(400).JSON(fiber.Map{"error": err.Error()})
+	}
+
+	addOn := models.AddOn{
+		Code:         input.Code,
+		Name:         input.Name,
+		Price:        input.Price,
+		Entitlements: input.Entitlements,
+	}
+	if addOn.Entitlements == nil {
+		addOn.Entitlements = models.EntitlementSet{}
+	}
+	for _, rule := range input.Compatibility {
+		addOn.Compatibility = append(addOn.Compatibility, models.PlanAddOn{PlanID: rule.PlanID, MaxQuantity: rule.MaxQuantity})
+	}
+
+	addOn, err := h.service.CreateAddOn(addOn)
+	if err != nil {
+		return c.Status(planErrorStatus(err)).JSON(fiber.Map{"error": err.Error()})
+	}
+	return c.Status(201).JSON(fiber.Map{"data": addOn})
+}
+
+// GetPlanTransitions godoc
+// @Summary     List the plan transition rules
+// @Description Changes between plans without a rule follow the default policy: upgrades apply immediately with proration, downgrades at the end of the paid period
+// @Tags        admin
+// @Produce     json
+// @Success     200 {array} models.PlanTransition
+// @Failure     500 {object} map[string]string
+// @Router      /api/admin/transitions [get]
+// @Security    AdminToken
+func (h *PlanHandler) GetPlanTransitions(c *fiber.Ctx) error {
+	transitions, err := h.service.GetPlanTransitions()
+	if err != nil {
+		return c.Status(500).JSON(fiber.Map{"error": err.Error()})
+	}
+	return c.JSON(fiber.Map{"data": transitions})
+}
+
+// PutPlanTransition godoc
+// @Summary     Set the transition rule between two plans
+// @Description Rules are keyed by plan code. kind is upgrade, downgrade, lateral or forbidden; timing is immediate or period_end.
+// @Tags        admin
+// @Accept      json
+// @Produce     json
+// @Param       input body models.PlanTransition true "Transition rule"
+// @Success     200 {object} models.PlanTransition
+// @Failure     400 {object} map[string]string
+// @Failure     500 {object} map[string]string
+// @Router      /api/admin/transitions [put]
+// @Security    AdminToken
+func (h *PlanHandler) PutPlanTransition(c *fiber.Ctx) error {
+	var transition models.PlanTransition
+	if err := c.BodyParser(&transition); err != nil {
+		return c.Status(400).JSON(fiber.Map{"error": "Invalid input"})
+	}
+	if err := utils.ValidateStruct(transition); err != nil {
+		return c.Status(400).JSON(fiber.Map{"error": err.Error()})
+	}
+
+	transition, err := h.service.SetPlanTransition(transition)
+	if err != nil {
+		return c.Status(planErrorStatus(err)).JSON(fiber.Map{"error": err.Error()})
+	}
+	return c.JSON(fiber.Map{"data": transition})
+}
+
+// DeletePlanTransition godoc
+// @Summary     Remove the transition rule between two plans
+// @Tags        admin
+// @Param       from path string true "Current plan code"
+// @Param       to   path string true "Target plan code"
+// @Success     204
+// @Failure     404 {object} map[string]string
+// @Failure     500 {object} map[string]string
+// @Router      /api/admin/transitions/{from}/{to} [delete]
+// @Security    AdminToken
+func (h *PlanHandler) DeletePlanTransition(c *fiber.Ctx) error {
+	if err := h.service.DeletePlanTransition(c.Params("from"), c.Params("to")); err != nil {
+		return c.Status(planErrorStatus(err)).JSON(fiber.Map{"error": err.Error()})
+	}
+	return c.SendStatus(204)
+}
+
+// GetExperiments godoc
+// @Summary     List price experiments
+// @Tags        admin
+// @Produce     json
+// @Success     200 {array} models.Experiment
+// @Failure     500 {object} map[string]string
+// @Router      /api/admin/experiments [get]
+// @Security    AdminToken
+func (h *PlanHandler) GetExperiments(c *fiber.Ctx) error {
+	experiments, err := h.service.GetExperiments()
+	if err != nil {
+		return c.Status(500).JSON(fiber.Map{"error": err.Error()})
+	}
+	return c.JSON(fiber.Map{"data": experiments})
+}
+
+// CreateExperiment godoc
+// @Summary     Start a price experiment on a plan
+// @Description Users are assigned to a variant deterministically, with odds proportional to the variant weights, and see and pay the variant price. Only flat and per_unit plans can be tested, one experiment per plan at a time.
+// @Tags        admin
+// @Accept      json
+// @Produce     json
+// @Param       input body ExperimentInput true "Experiment"
+// @Success     201 {object} models.Experiment
+// @Failure     400 {object} map[string]string
+// @Failure     404 {object} map[string]string
+// @Failure     500 {object} map[string]string
+// @Router      /api/admin/experiments [post]
+// @Security    AdminToken
+func (h *PlanHandler) CreateExperiment(c *fiber.Ctx) error {
+	var input ExperimentInput
+	if err := c.BodyParser(&input); err != nil {
+		return c.Status(400).JSON(fiber.Map{"error": "Invalid input"})
+	}
+	if err := utils.ValidateStruct(input); err != nil {
+		return c.Status(400).JSON(fiber.Map{"error": err.Error()})
+	}
+
+	experiment := models.Experiment{Name: input.Name, PlanID: input.PlanID}
+	for _, v := range input.Variants {
+		experiment.Variants = append(experiment.Variants, models.ExperimentVariant{Name: v.Name, Price: v.Price, Weight: v.Weight})
+	}
+
+	experiment, err := h.service.CreateExperiment(experiment)
+	if err != nil {
+		return c.Status(planErrorStatus(err)).JSON(fiber.Map{"error": err.Error()})
+	}
+	return c.Status(201).JSON(fiber.Map{"data": experiment})
+}
+
+// StopExperiment godoc
+// @Summary     Stop a price experiment
+// @Description Users see the plan price again; subscriptions bought at a variant price keep it
+// @Tags        admin
+// @Produce     json
+// @Param       id path int true "Experiment ID"
+// @Success     200 {object} models.Experiment
+// @Failure     400 {object} map[string]string
+// @Failure     404 {object} map[string]string
+// @Failure     500 {object} map[string]string
+// @Router      /api/admin/experiments/{id}/stop [post]
+// @Security    AdminToken
+func (h *PlanHandler) StopExperiment(c *fiber.Ctx) error {
+	experimentId, err := c.ParamsInt("id")
+	if err != nil || experimentId <= 0 {
+		return c.Status(400).JSON(fiber.Map{"error": "Invalid experiment id"})
+	}
+
+	experiment, err := h.service.StopExperiment(experimentId)
+	if err != nil {
+		return c.Status(planErrorStatus(err)).JSON(fiber.Map{"error": err.Error()})
+	}
+	return c.JSON(fiber.Map{"data": experiment})
+}
+
+// GetExperimentReport godoc
+// @Summary     Report conversion per variant
+// @Description assigned counts the users shown a variant price, converted those who subscribed at it
+// @Tags        admin
+// @Produce     json
+// @Param       id path int true "Experiment ID"
+// @Success     200 {array} models.VariantResult
+// @Failure     400 {object} map[string]string
+// @Failure     404 {object} map[string]string
+// @Failure     500 {object} map[string]string
+// @Router      /api/admin/experiments/{id}/report [get]
+// @Security    AdminToken
+func (h *PlanHandler) GetExperimentReport(c *fiber.Ctx) error {
+	experimentId, err := c.ParamsInt("id")
+	if err != nil || experimentId <= 0 {
+		return c.Status(400).JSON(fiber.Map{"error": "Invalid experiment id"})
+	}
+
+	report, err := h.service.GetExperimentReport(experimentId)
+	if err != nil {
+		return c.Status(planErrorStatus(err)).JSON(fiber.Map{"error": err.Error()})
+	}
+	return c.JSON(fiber.Map{"data": report})
+}
+
+// GetPlanSunsets godoc
+// @Summary     List plan sunsets with their progress
+// @Tags        admin
+// @Produce     json
+// @Success     200 {array} models.PlanSunset
+// @Failure     500 {object} map[string]string
+// @Router      /api/admin/sunsets [get]
+// @Security    AdminToken
+func (h *PlanHandler) GetPlanSunsets(c *fiber.Ctx) error {
+	sunsets, err := h.service.GetPlanSunsets()
+	if err != nil {
+		return c.Status(500).JSON(fiber.Map{"error": err.Error()})
+	}
+	return c.JSON(fiber.Map{"data": sunsets})
+}
+
+// CreatePlanSunset godoc
+// @Summary     Retire a plan in favor of a replacement
+// @Description The plan is archived right away. Each subscriber is sent a notice and moved to the replacement plan at their first renewal after notice_days (30 by default). The sunset completes once the plan has no subscribers left.
+// @Tags        admin
+// @Accept      json
+// @Produce     json
+// @Param       input body SunsetInput true "Sunset"
+// @Success     201 {object} models.PlanSunset
+// @Failure     400 {object} map[string]string
+// @Failure     404 {object} map[string]string
+// @Failure     500 {object} map[string]string
+// @Router      /api/admin/sunsets [post]
+// @Security    AdminToken
+func (h *PlanHandler) CreatePlanSunset(c *fiber.Ctx) error {
+	var input SunsetInput
+	if err := c.BodyParser(&input); err != nil {
+		return c.Status(400).JSON(fiber.Map{"error": "Invalid input"})
+	}
+	if err := utils.ValidateStruct(input); err != nil {
+		return c.Status(400).JSON(fiber.Map{"error": err.Error()})
+	}
+	noticeDays := 30
+	if input.NoticeDays != nil {
+		noticeDays = *input.NoticeDays
+	}
+
+	sunset, err := h.service.SunsetPlan(int(input.PlanID), int(input.ReplacementPlanID), noticeDays)
+	if err != nil {
+		return c.Status(planErrorStatus(err)).JSON(fiber.Map{"error": err.Error()})
+	}
+	return c.Status(201).JSON(fiber.Map{"data": sunset})
+}
+
+// GetPlanSunset godoc
+// @Summary     Track the progress of a plan sunset
+// @Description remaining counts the subscribers still on the retired plan, scheduled those among them with a migration date, and migrated those already moved
+// @Tags        admin
+// @Produce     json
+// @Param       id path int true "Sunset ID"
+// @Success     200 {object} models.PlanSunset
+// @Failure     400 {object} map[string]string
+// @Failure     404 {object} map[string]string
+// @Failure     500 {object} map[string]string
+// @Router      /api/admin/sunsets/{id} [get]
+// @Security    AdminToken
+func (h *PlanHandler) GetPlanSunset(c *fiber.Ctx) error {
+	sunsetId, err := c.ParamsInt("id")
+	if err != nil || sunsetId <= 0 {
+		return c.Status(400).JSON(fiber.Map{"error": "Invalid sunset id"})
+	}
+
+	sunset, err := h.service.GetPlanSunset(sunsetId)
+	if err != nil {
+		return c.Status(planErrorStatus(err)).JSON(fiber.Map{"error": err.Error()})
+	}
+	return c.JSON(fiber.Map{"data": sunset})
+}
+
+// GetPriceChanges godoc
+// @Summary     List price changes with their progress
+// @Tags        admin
+// @Produce     json
+// @Success     200 {array} models.PriceChange
+// @Failure     500 {object} map[string]string
+// @Router      /api/admin/price-changes [get]
+// @Security    AdminToken
+func (h *PlanHandler) GetPriceChanges(c *fiber.Ctx) error {
+	changes, err := h.service.GetPriceChanges()
+	if err != nil {
+		return c.Status(500).JSON(fiber.Map{"error": err.Error()})
+	}
+	return c.JSON(fiber.Map{"data": changes})
+}
+
+// CreatePriceChange godoc
+// @Summary     Change the price paid by the existing subscribers of a plan
+// @Description Every live subscriber paying another price is sent a notice and pays new_price from their first renewal after notice_days (30 by default). Users in exempt_user_ids keep their price. The plan price paid by new subscribers is not changed. Plans with graduated or volume pricing are rejected.
+// @Tags        admin
+// @Accept      json
+// @Produce     json
+// @Param       input body PriceChangeInput true "Price change"
+// @Success     201 {object} models.PriceChange
+// @Failure     400 {object} map[string]string
+// @Failure     404 {object} map[string]string
+// @Failure     500 {object} map[string]string
+// @Router      /api/admin/price-changes [post]
+// @Security    AdminToken
+func (h *PlanHandler) CreatePriceChange(c *fiber.Ctx) error {
+	var input PriceChangeInput
+	if err := c.BodyParser(&input); err != nil {
+		return c.Status(400).JSON(fiber.Map{"error": "Invalid input"})
+	}
+	if err := utils.ValidateStruct(input); err != nil {
+		return c.Status(400).JSON(fiber.Map{"error": err.Error()})
+	}
+	noticeDays := 30
+	if input.NoticeDays != nil {
+		noticeDays = *input.NoticeDays
+	}
+
+	change, err := h.service.AnnouncePriceChange(models.PriceChange{
+		PlanID:     input.PlanID,
+		NewPrice:   input.NewPrice,
+		NoticeDays: noticeDays,
+	}, input.ExemptUserIDs)
+	if err != nil {
+		return c.Status(planErrorStatus(err)).JSON(fiber.Map{"error": err.Error()})
+	}
+	return c.Status(201).JSON(fiber.Map{"data": change})
+}
+
+// GetPriceChange godoc
+// @Summary     Track the rollout of a price change
+// @Tags        admin
+// @Produce     json
+// @Param       id path int true "Price change ID"
+// @Success     200 {object} models.PriceChange
+// @Failure     400 {object} map[string]string
+// @Failure     404 {object} map[string]string
+// @Failure     500 {object} map[string]string
+// @Router      /api/admin/price-changes/{id} [get]
+// @Security    AdminToken
+func (h *PlanHandler) GetPriceChange(c *fiber.Ctx) error {
+	changeId, err := c.ParamsInt("id")
+	if err != nil || changeId <= 0 {
+		return c.Status(400).JSON(fiber.Map{"error": "Invalid price change id"})
+	}
+
+	change, err := h.service.GetPriceChange(changeId)
+	if err != nil {
+		return c.Status(planErrorStatus(err)).JSON(fiber.Map{"error": err.Error()})
+	}
+	return c.JSON(fiber.Map{"data": change})
+}
+
+// ExemptFromPriceChange godoc
+// @Summary     Keep a subscriber on their current price
+// @Description Only works until the new price applies to the subscriber
+// @Tags        admin
+// @Produce     json
+// @Param       id     path int true "Price change ID"
+// @Param       userId path int true "User ID"
+// @Success     200 {object} models.PriceChangeSubscriber
+// @Failure     400 {object} map[string]string
+// @Failure     404 {object} map[string]string
+// @Failure     500 {object} map[string]string
+// @Router      /api/admin/price-changes/{id}/exemptions/{userId} [put]
+// @Security    AdminToken
+func (h *PlanHandler) ExemptFromPriceChange(c *fiber.Ctx) error {
+	changeId, err := c.ParamsInt("id")
+	if err != nil || changeId <= 0 {
+		return c.Status(400).JSON(fiber.Map{"error": "Invalid price change id"})
+	}
+	userId, err := c.ParamsInt("userId")
+	if err != nil || userId <= 0 {
+		return c.Status(400).JSON(fiber.Map{"error": "Invalid user id"})
+	}
+
+	record, err := h.service.ExemptFromPriceChange(changeId, userId)
+	if err != nil {
+		return c.Status(planErrorStatus(err)).JSON(fiber.Map{"error": err.Error()})
+	}
+	return c.JSON(fiber.Map{"data": record})
+}
+
+func parsePlanInput(c *fiber.Ctx) (models.Plan, error) {
+	var input PlanInput
+	if err := c.BodyParser(&input); err != nil {
+		return models.Plan{}, errors.New("Invalid input")
+	}
+	if err := utils.ValidateStruct(input); err != nil {
+		return models.Plan{}, err
+	}
+
+	if input.Features == nil {
+		input.Features = []string{}
+	}
+	features, err := json.Marshal(input.Features)
+	if err != nil {
+		return models.Plan{}, err
+	}
+
+	entitlements := input.Entitlements
+	if entitlements == nil {
+		entitlements = models.EntitlementSet{}
+	}
+	if input.Currency == "" {
+		input.Currency = "USD"
+	}
+	if input.PricingModel == "" {
+		input.PricingModel = models.PricingFlat
+	}
+	if input.PriceTiers == nil {
+		input.PriceTiers = []models.PriceTier{}
+	}
+
+	return models.Plan{
+		Code:           input.Code,
+		Name:           input.Name,
+		Price:          input.Price,
+		Currency:       input.Currency,
+		PricingModel:   input.PricingModel,
+		PriceTiers:     input.PriceTiers,
+		Features:       features,
+		Duration:       input.Duration,
+		IntervalUnit:   input.IntervalUnit,
+		IntervalCount:  max(input.IntervalCount, 1),
+		Entitlements:   entitlements,
+		TrialDays:      input.TrialDays,
+		AvailableFrom:  input.AvailableFrom,
+		AvailableUntil: input.AvailableUntil,
+		MaxSubscribers: input.MaxSubscribers,
+		MinQuantity:    max(input.MinQuantity, 1),
+		MaxQuantity:    input.MaxQuantity,
+	}, nil
+}
+
+func parsePlanFilter(c *fiber.Ctx) (models.PlanFilter, error) {
+	filter := models.PlanFilter{
+		Currency: c.Query("currency"),
+		Interval: models.IntervalUnit(c.Query("interval")),
+		Feature:  c.Query("feature"),
+	}
+	if filter.Interval != "" && !filter.Interval.Valid() {
+		return filter, errors.New("interval must be one of day, week, month, year")
+	}
+	for param, bound := range map[string]**float64{"min_price": &filter.MinPrice, "max_price": &filter.MaxPrice} {
+		raw := c.Query(param)
+		if raw == "" {
+			continue
+		}
+		value, err := strconv.ParseFloat(raw, 64)
+		if err != nil {
+			return filter, errors.New(param + " must be a number")
+		}
+		*bound = &value
+	}
+	return filter, nil
+}
+
+// resolveLocale picks the response locale from ?locale= or Accept-Language
+// and announces it in Content-Language.
+func (h *PlanHandler) resolveLocale(c *fiber.Ctx) (string, error) {
+	locale, err := h.service.ResolveLocale(c.Query("locale"), c.Get(fiber.HeaderAcceptLanguage))
+	if err != nil {
+		return "", err
+	}
+	c.Set(fiber.HeaderContentLanguage, locale)
+	c.Vary(fiber.HeaderAcceptLanguage)
+	return locale, nil
+}
+
+// planCacheControl lets shared caches keep the catalog unless it was
+// personalized for a signed-in user.
+func planCacheControl(c *fiber.Ctx, userId int) string {
+	c.Vary(fiber.HeaderAuthorization)
+	if userId > 0 {
+		return "private, max-age=60"
+	}
+	return "public, max-age=60"
+}
+
+// sendCacheable answers with the data built by load under a strong ETag. The
+// ETag is derived from the catalog version and what else the response
+// depends on (URL, locale and user), so a client that already holds it gets
+// 304 Not Modified before the catalog is loaded, localized or marshaled.
+func (h *PlanHandler) sendCacheable(c *fiber.Ctx, locale string, userId int, load func() (interface{}, error)) error {
+	c.Set(fiber.HeaderCacheControl, planCacheControl(c, userId))
+	// Without a version the response is still served, just not revalidated
+	if version, err := h.service.CatalogVersion(); err == nil {
+		sum := sha256.Sum256([]byte(version + "\n" + c.OriginalURL() + "\n" + locale + "\n" + strconv.Itoa(userId)))
+		c.Set(fiber.HeaderETag, `"`+hex.EncodeToString(sum[:])+`"`)
+		if c.Fresh() {
+			return c.SendStatus(fiber.StatusNotModified)
+		}
+	}
+
+	data, err := load()
+	if err != nil {
+		c.Response().Header.Del(fiber.HeaderETag)
+		c.Response().Header.Del(fiber.HeaderCacheControl)
+		return c.Status(planErrorStatus(err)).JSON(fiber.Map{"error": err.Error()})
+	}
+	return c.JSON(fiber.Map{"data": data})
+}
+
+func planErrorStatus(err error) int {
+	switch {
+	case errors.Is(err, repository.ErrInvalidPlan), errors.Is(err, repository.ErrInvalidEntitlement),
+		errors.Is(err, repository.ErrInvalidAddOn), errors.Is(err, services.ErrInvalidQuantity),
+		errors.Is(err, services.ErrInvalidPlanQuery), errors.Is(err, repository.ErrInvalidTransition),
+		errors.Is(err, repository.ErrInvalidExperiment), errors.Is(err, repository.ErrInvalidSunset),
+		errors.Is(err, repository.ErrInvalidPriceChange):
+		return 400
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		return 404
+	}
+	return 500
 }
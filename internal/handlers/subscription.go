@@ -1,12 +1,17 @@
 package handlers
 
 import (
+	"errors"
 	"log"
+	"time"
 
 	"github.com/Harshal292004/subscription-service/internal/middleware"
+	"github.com/Harshal292004/subscription-service/internal/models"
+	"github.com/Harshal292004/subscription-service/internal/repository"
 	"github.com/Harshal292004/subscription-service/internal/services"
 	"github.com/Harshal292004/subscription-service/internal/utils"
 	"github.com/gofiber/fiber/v2"
+	"gorm.io/gorm"
 )
 
 type SubscriptionHandler struct {
@@ -18,7 +23,46 @@ type UserIdInput struct {
 }
 
 type PlanIdInput struct {
-	PlanId int `json:"planId"`
+	PlanId   int `json:"planId"`
+	Quantity int `json:"quantity" validate:"gte=0"`
+}
+
+type PlanChangeInput struct {
+	PlanId int    `json:"planId"`
+	Timing string `json:"timing" validate:"omitempty,oneof=immediate period_end"`
+}
+
+type PauseInput struct {
+	ResumeAt *time.Time `json:"resume_at"`
+}
+
+type AutoRenewInput struct {
+	AutoRenew *bool `json:"auto_renew" validate:"required"`
+}
+
+type QuantityInput struct {
+	Quantity int `json:"quantity" validate:"gte=1"`
+}
+
+type SeatInput struct {
+	UserId int `json:"user_id" validate:"required,gte=1"`
+}
+
+type AddOnIdInput struct {
+	AddOnId  int `json:"addOnId" validate:"required"`
+	Quantity int `json:"quantity" validate:"gte=0"`
+}
+
+type CancellationInput struct {
+	Mode   string `query:"mode" validate:"omitempty,oneof=period_end immediate"`
+	Refund bool   `query:"refund"`
+}
+
+type MigrationJobInput struct {
+	FromPlanID uint `json:"from_plan_id" validate:"required"`
+	ToPlanID   uint `json:"to_plan_id" validate:"required"`
+	DryRun     bool `json:"dry_run"`
+	ChunkSize  int  `json:"chunk_size" validate:"omitempty,gte=1,lte=1000"`
 }
 
 func NewSubscriptionHandler(s *services.SubscriptionService) *SubscriptionHandler {
@@ -39,10 +83,40 @@ func RegisterSubscriptionRoutes(r fiber.Router, service *services.SubscriptionSe
 	r.Get("/subscription", h.GetSubscription)
 	r.Post("/subscription", h.PostSubscription)
 	r.Delete("/subscription", h.DeleteSubscription)
+	r.Delete("/subscription/cancellation", h.UndoCancellation)
+	r.Put("/subscription/auto-renew", h.SetAutoRenew)
+	r.Post("/subscription/pause", h.PauseSubscription)
+	r.Post("/subscription/resume", h.ResumeSubscription)
+	r.Get("/subscription/pending-change", h.GetPendingPlanChange)
+	r.Delete("/subscription/pending-change", h.DeletePendingPlanChange)
 	r.Put("/subscription", h.PutSubscription)
+	r.Put("/subscription/quantity", h.ChangeQuantity)
+	r.Get("/subscription/seats", h.GetSeatUsage)
+	r.Post("/subscription/seats", h.AssignSeat)
+	r.Delete("/subscription/seats/:userId", h.UnassignSeat)
+	r.Get("/history", h.GetSubscriptionHistory)
+	r.Post("/subscription/addons", h.AttachAddOn)
+	r.Delete("/subscription/addons/:addOnId", h.DetachAddOn)
+	r.Get("/entitlements", h.GetEntitlements)
 	log.Println("[RegisterSubscriptionRoutes] All subscription routes registered successfully")
 }
 
+// RegisterAdminSubscriptionRoutes godoc
+// @Summary     Run bulk plan migrations
+// @Tags        admin
+func RegisterAdminSubscriptionRoutes(r fiber.Router, service *services.SubscriptionService) {
+	h := &SubscriptionHandler{service}
+	r.Get("/migrations", h.GetMigrationJobs)
+	r.Post("/migrations", h.CreateMigrationJob)
+	r.Get("/migrations/:id", h.GetMigrationJob)
+	r.Get("/migrations/:id/items", h.GetMigrationJobItems)
+	r.Post("/migrations/:id/pause", h.PauseMigrationJob)
+	r.Post("/migrations/:id/resume", h.ResumeMigrationJob)
+	r.Post("/migrations/:id/cancel", h.CancelMigrationJob)
+	r.Get("/jobs/expiry", h.GetExpiryJobStatus)
+	r.Get("/subscriptions/:id/transitions", h.GetSubscriptionTransitions)
+}
+
 // GetSubscription godoc
 // @Summary     Get current subscription for a user
 // @Description Get subscription for authenticated user
@@ -98,9 +172,12 @@ func (h *SubscriptionHandler) GetSubscription(c *fiber.Ctx) error {
 // @Tags        subscriptions
 // @Accept      json
 // @Produce     json
-// @Param       input body PlanIdInput true "Plan ID input"
+// @Param       input body PlanIdInput true "Plan ID and number of seats, the plan minimum when omitted"
 // @Success     200 {object} models.Subscription
 // @Failure     400 {object} map[string]string
+// @Failure     404 {object} map[string]string
+// @Failure     409 {object} map[string]string
+// @Failure     422 {object} map[string]string
 // @Failure     500 {object} map[string]string
 // @Router		/api/subs/subscription [post]
 // @Security    BearerAuth
@@ -138,11 +215,11 @@ func (h *SubscriptionHandler) PostSubscription(c *fiber.Ctx) error {
 	log.Println("[PostSubscription] Input validation successful")
 	log.Printf("[PostSubscription] Calling service.PostSubscription for userID: %d, planId: %d", userID, planInput.PlanId)
 
-	sub, err := h.service.PostSubscription(userID, planInput.PlanId)
+	sub, err := h.service.PostSubscription(userID, planInput.PlanId, planInput.Quantity)
 	if err != nil {
 		log.Printf("[PostSubscription] Service returned error: %v", err)
-		log.Println("[PostSubscription] === Returning 500 error ===")
-		return c.Status(500).JSON(fiber.Map{"error": err.Error()})
+		log.Println("[PostSubscription] === Returning error response ===")
+		return c.Status(subscriptionErrorStatus(err)).JSON(fiber.Map{"error": err.Error()})
 	}
 
 	log.Printf("[PostSubscription] Successfully created subscription for userID: %d, planId: %d", userID, planInput.PlanId)
@@ -151,13 +228,17 @@ func (h *SubscriptionHandler) PostSubscription(c *fiber.Ctx) error {
 }
 
 // DeleteSubscription godoc
-// @Summary     Delete user subscription
-// @Description Delete subscription for authenticated user
+// @Summary     Cancel user subscription
+// @Description By default the subscription runs until the end of the paid period and is then cancelled. With mode=immediate it is cancelled now, and refund=true credits the unused part of the period. A cancelled subscription stays in the subscription history.
 // @Tags        subscriptions
 // @Accept      json
 // @Produce     json
-// @Success     200 {object} models.Subscription
+// @Param       mode   query    string false "period_end (default) or immediate"
+// @Param       refund query    bool   false "Refund the unused part of the period (immediate only)"
+// @Success     200 {object} models.SubscriptionCancellation
 // @Failure     400 {object} map[string]string
+// @Failure     404 {object} map[string]string
+// @Failure     409 {object} map[string]string
 // @Failure     500 {object} map[string]string
 // @Router      /api/subs/subscription [delete]
 // @Security    BearerAuth
@@ -173,29 +254,77 @@ func (h *SubscriptionHandler) DeleteSubscription(c *fiber.Ctx) error {
 	}
 
 	log.Printf("[DeleteSubscription] Extracted userID from context: %d", userID)
-	log.Printf("[DeleteSubscription] Calling service.DeleteSubscription for userID: %d", userID)
 
-	sub, err := h.service.DeleteSubscription(userID)
+	var input CancellationInput
+	if err := c.QueryParser(&input); err != nil {
+		log.Printf("[DeleteSubscription] Failed to parse query: %v", err)
+		return c.Status(400).JSON(fiber.Map{"error": "Invalid input"})
+	}
+	if err := utils.ValidateStruct(input); err != nil {
+		log.Printf("[DeleteSubscription] Struct validation failed: %v", err)
+		return c.Status(400).JSON(fiber.Map{"error": err.Error()})
+	}
+
+	log.Printf("[DeleteSubscription] Calling service.DeleteSubscription for userID: %d, mode: %q", userID, input.Mode)
+
+	cancellation, err := h.service.DeleteSubscription(userID, models.Cancellation{
+		Mode:   models.CancellationMode(input.Mode),
+		Refund: input.Refund,
+	})
 	if err != nil {
 		log.Printf("[DeleteSubscription] Service returned error: %v", err)
-		log.Println("[DeleteSubscription] === Returning 500 error ===")
-		return c.Status(500).JSON(fiber.Map{"error": err.Error()})
+		log.Println("[DeleteSubscription] === Returning error response ===")
+		return c.Status(subscriptionErrorStatus(err)).JSON(fiber.Map{"error": err.Error()})
 	}
 
-	log.Printf("[DeleteSubscription] Successfully deleted subscription for userID: %d", userID)
+	log.Printf("[DeleteSubscription] Successfully cancelled subscription for userID: %d", userID)
 	log.Println("[DeleteSubscription] === Returning successful response ===")
+	return c.JSON(fiber.Map{"data": cancellation})
+}
+
+// UndoCancellation godoc
+// @Summary     Undo a scheduled cancellation
+// @Description Keep the subscription running when it is scheduled to be cancelled at the end of its period
+// @Tags        subscriptions
+// @Produce     json
+// @Success     200 {object} models.Subscription
+// @Failure     400 {object} map[string]string
+// @Failure     404 {object} map[string]string
+// @Failure     409 {object} map[string]string
+// @Failure     500 {object} map[string]string
+// @Router      /api/subs/subscription/cancellation [delete]
+// @Security    BearerAuth
+func (h *SubscriptionHandler) UndoCancellation(c *fiber.Ctx) error {
+	log.Println("[UndoCancellation] === Starting UndoCancellation request ===")
+
+	userID, ok := c.Locals("userId").(int)
+	if !ok {
+		log.Println("[UndoCancellation] Failed to extract userID from context")
+		return c.Status(400).JSON(fiber.Map{"error": "Invalid user context"})
+	}
+
+	sub, err := h.service.UndoCancellation(userID)
+	if err != nil {
+		log.Printf("[UndoCancellation] Service returned error: %v", err)
+		return c.Status(subscriptionErrorStatus(err)).JSON(fiber.Map{"error": err.Error()})
+	}
+
+	log.Printf("[UndoCancellation] === Subscription of userID %d keeps running ===", userID)
 	return c.JSON(fiber.Map{"data": sub})
 }
 
 // PutSubscription godoc
 // @Summary     Update subscription plan for a user
-// @Description Provide newPlanId in request body to update subscription
+// @Description Provide newPlanId in request body to update subscription. The transition policy between the two plans decides whether the change applies now or at the end of the paid period (see pending_plan_id); forbidden changes are rejected with 422.
 // @Tags        subscriptions
 // @Accept      json
 // @Produce     json
-// @Param       input body PlanIdInput true "New plan ID input"
-// @Success     200 {object} models.Subscription
+// @Param       input body PlanChangeInput true "New plan ID, and timing=period_end to wait for the end of the paid period"
+// @Success     200 {object} models.PlanChange
 // @Failure     400 {object} map[string]string
+// @Failure     404 {object} map[string]string
+// @Failure     409 {object} map[string]string
+// @Failure     422 {object} map[string]string
 // @Failure     500 {object} map[string]string
 // @Router      /api/subs/subscription [put]
 // @Security    BearerAuth
@@ -213,7 +342,7 @@ func (h *SubscriptionHandler) PutSubscription(c *fiber.Ctx) error {
 	log.Printf("[PutSubscription] Extracted userID from context: %d", userID)
 
 	// Parse plan ID from request body
-	var planInput PlanIdInput
+	var planInput PlanChangeInput
 	log.Println("[PutSubscription] Parsing request body for new planId")
 	if err := c.BodyParser(&planInput); err != nil {
 		log.Printf("[PutSubscription] Failed to parse request body: %v", err)
@@ -233,14 +362,705 @@ func (h *SubscriptionHandler) PutSubscription(c *fiber.Ctx) error {
 	log.Println("[PutSubscription] Input validation successful")
 	log.Printf("[PutSubscription] Calling service.PutSubscription for userID: %d, new planId: %d", userID, planInput.PlanId)
 
-	sub, err := h.service.PutSubscription(userID, planInput.PlanId)
+	sub, err := h.service.PutSubscription(userID, planInput.PlanId, models.ChangeTiming(planInput.Timing))
 	if err != nil {
 		log.Printf("[PutSubscription] Service returned error: %v", err)
-		log.Println("[PutSubscription] === Returning 500 error ===")
-		return c.Status(500).JSON(fiber.Map{"error": err.Error()})
+		log.Println("[PutSubscription] === Returning error response ===")
+		return c.Status(subscriptionErrorStatus(err)).JSON(fiber.Map{"error": err.Error()})
 	}
 
 	log.Printf("[PutSubscription] Successfully updated subscription for userID: %d, new planId: %d", userID, planInput.PlanId)
 	log.Println("[PutSubscription] === Returning successful response ===")
 	return c.JSON(fiber.Map{"data": sub})
 }
+
+// SetAutoRenew godoc
+// @Summary     Turn automatic renewal on or off
+// @Description A subscription that does not renew automatically ends with its paid period
+// @Tags        subscriptions
+// @Accept      json
+// @Produce     json
+// @Param       input body AutoRenewInput true "Whether the subscription renews automatically"
+// @Success     200 {object} models.Subscription
+// @Failure     400 {object} map[string]string
+// @Failure     404 {object} map[string]string
+// @Failure     409 {object} map[string]string
+// @Failure     500 {object} map[string]string
+// @Router      /api/subs/subscription/auto-renew [put]
+// @Security    BearerAuth
+func (h *SubscriptionHandler) SetAutoRenew(c *fiber.Ctx) error {
+	log.Println("[SetAutoRenew] === Starting SetAutoRenew request ===")
+
+	userID, ok := c.Locals("userId").(int)
+	if !ok {
+		log.Println("[SetAutoRenew] Failed to extract userID from context")
+		return c.Status(400).JSON(fiber.Map{"error": "Invalid user context"})
+	}
+
+	var input AutoRenewInput
+	if err := c.BodyParser(&input); err != nil {
+		log.Printf("[SetAutoRenew] Failed to parse request body: %v", err)
+		return c.Status(400).JSON(fiber.Map{"error": "Invalid input"})
+	}
+	if err := utils.ValidateStruct(input); err != nil {
+		log.Printf("[SetAutoRenew] Struct validation failed: %v", err)
+		return c.Status(400).JSON(fiber.Map{"error": err.Error()})
+	}
+
+	sub, err := h.service.SetAutoRenew(userID, *input.AutoRenew)
+	if err != nil {
+		log.Printf("[SetAutoRenew] Service returned error: %v", err)
+		return c.Status(subscriptionErrorStatus(err)).JSON(fiber.Map{"error": err.Error()})
+	}
+
+	log.Printf("[SetAutoRenew] === Auto renew set to %v for userID: %d ===", *input.AutoRenew, userID)
+	return c.JSON(fiber.Map{"data": sub})
+}
+
+// PauseSubscription godoc
+// @Summary     Pause the subscription
+// @Description Put the active subscription on hold, optionally until resume_at. A paused subscription grants no entitlements and its remaining paid time is kept for when it resumes.
+// @Tags        subscriptions
+// @Accept      json
+// @Produce     json
+// @Param       input body PauseInput false "Date to resume on"
+// @Success     200 {object} models.Subscription
+// @Failure     400 {object} map[string]string
+// @Failure     404 {object} map[string]string
+// @Failure     409 {object} map[string]string
+// @Failure     500 {object} map[string]string
+// @Router      /api/subs/subscription/pause [post]
+// @Security    BearerAuth
+func (h *SubscriptionHandler) PauseSubscription(c *fiber.Ctx) error {
+	log.Println("[PauseSubscription] === Starting PauseSubscription request ===")
+
+	userID, ok := c.Locals("userId").(int)
+	if !ok {
+		log.Println("[PauseSubscription] Failed to extract userID from context")
+		return c.Status(400).JSON(fiber.Map{"error": "Invalid user context"})
+	}
+
+	var input PauseInput
+	if len(c.Body()) > 0 {
+		if err := c.BodyParser(&input); err != nil {
+			log.Printf("[PauseSubscription] Failed to parse request body: %v", err)
+			return c.Status(400).JSON(fiber.Map{"error": "Invalid input"})
+		}
+	}
+
+	sub, err := h.service.PauseSubscription(userID, input.ResumeAt)
+	if err != nil {
+		log.Printf("[PauseSubscription] Service returned error: %v", err)
+		return c.Status(subscriptionErrorStatus(err)).JSON(fiber.Map{"error": err.Error()})
+	}
+
+	log.Printf("[PauseSubscription] === Paused subscription for userID: %d ===", userID)
+	return c.JSON(fiber.Map{"data": sub})
+}
+
+// ResumeSubscription godoc
+// @Summary     Resume the subscription
+// @Description End the pause; the paid period is pushed back by the time spent paused
+// @Tags        subscriptions
+// @Produce     json
+// @Success     200 {object} models.Subscription
+// @Failure     400 {object} map[string]string
+// @Failure     404 {object} map[string]string
+// @Failure     409 {object} map[string]string
+// @Failure     500 {object} map[string]string
+// @Router      /api/subs/subscription/resume [post]
+// @Security    BearerAuth
+func (h *SubscriptionHandler) ResumeSubscription(c *fiber.Ctx) error {
+	log.Println("[ResumeSubscription] === Starting ResumeSubscription request ===")
+
+	userID, ok := c.Locals("userId").(int)
+	if !ok {
+		log.Println("[ResumeSubscription] Failed to extract userID from context")
+		return c.Status(400).JSON(fiber.Map{"error": "Invalid user context"})
+	}
+
+	sub, err := h.service.ResumeSubscription(userID)
+	if err != nil {
+		log.Printf("[ResumeSubscription] Service returned error: %v", err)
+		return c.Status(subscriptionErrorStatus(err)).JSON(fiber.Map{"error": err.Error()})
+	}
+
+	log.Printf("[ResumeSubscription] === Resumed subscription for userID: %d ===", userID)
+	return c.JSON(fiber.Map{"data": sub})
+}
+
+// GetPendingPlanChange godoc
+// @Summary     Get the scheduled plan change
+// @Description The plan the current subscription moves to when it renews, and when
+// @Tags        subscriptions
+// @Produce     json
+// @Success     200 {object} models.PendingPlanChange
+// @Failure     400 {object} map[string]string
+// @Failure     404 {object} map[string]string
+// @Failure     500 {object} map[string]string
+// @Router      /api/subs/subscription/pending-change [get]
+// @Security    BearerAuth
+func (h *SubscriptionHandler) GetPendingPlanChange(c *fiber.Ctx) error {
+	log.Println("[GetPendingPlanChange] === Starting GetPendingPlanChange request ===")
+
+	userID, ok := c.Locals("userId").(int)
+	if !ok {
+		log.Println("[GetPendingPlanChange] Failed to extract userID from context")
+		return c.Status(400).JSON(fiber.Map{"error": "Invalid user context"})
+	}
+
+	change, err := h.service.GetPendingPlanChange(userID)
+	if err != nil {
+		log.Printf("[GetPendingPlanChange] Service returned error: %v", err)
+		return c.Status(subscriptionErrorStatus(err)).JSON(fiber.Map{"error": err.Error()})
+	}
+
+	log.Printf("[GetPendingPlanChange] === Returning pending change for userID: %d ===", userID)
+	return c.JSON(fiber.Map{"data": change})
+}
+
+// DeletePendingPlanChange godoc
+// @Summary     Cancel the scheduled plan change
+// @Description The current subscription renews on its current plan instead
+// @Tags        subscriptions
+// @Produce     json
+// @Success     200 {object} models.Subscription
+// @Failure     400 {object} map[string]string
+// @Failure     404 {object} map[string]string
+// @Failure     500 {object} map[string]string
+// @Router      /api/subs/subscription/pending-change [delete]
+// @Security    BearerAuth
+func (h *SubscriptionHandler) DeletePendingPlanChange(c *fiber.Ctx) error {
+	log.Println("[DeletePendingPlanChange] === Starting DeletePendingPlanChange request ===")
+
+	userID, ok := c.Locals("userId").(int)
+	if !ok {
+		log.Println("[DeletePendingPlanChange] Failed to extract userID from context")
+		return c.Status(400).JSON(fiber.Map{"error": "Invalid user context"})
+	}
+
+	sub, err := h.service.DeletePendingPlanChange(userID)
+	if err != nil {
+		log.Printf("[DeletePendingPlanChange] Service returned error: %v", err)
+		return c.Status(subscriptionErrorStatus(err)).JSON(fiber.Map{"error": err.Error()})
+	}
+
+	log.Printf("[DeletePendingPlanChange] === Dropped pending change for userID: %d ===", userID)
+	return c.JSON(fiber.Map{"data": sub})
+}
+
+// GetSubscriptionHistory godoc
+// @Summary     List every subscription of a user
+// @Description The current subscription and all past ones, most recent first
+// @Tags        subscriptions
+// @Produce     json
+// @Success     200 {array}  models.Subscription
+// @Failure     400 {object} map[string]string
+// @Failure     500 {object} map[string]string
+// @Router      /api/subs/history [get]
+// @Security    BearerAuth
+func (h *SubscriptionHandler) GetSubscriptionHistory(c *fiber.Ctx) error {
+	log.Println("[GetSubscriptionHistory] === Starting GetSubscriptionHistory request ===")
+
+	userID, ok := c.Locals("userId").(int)
+	if !ok {
+		log.Println("[GetSubscriptionHistory] Failed to extract userID from context")
+		return c.Status(400).JSON(fiber.Map{"error": "Invalid user context"})
+	}
+
+	subs, err := h.service.GetSubscriptionHistory(userID)
+	if err != nil {
+		log.Printf("[GetSubscriptionHistory] Service returned error: %v", err)
+		return c.Status(500).JSON(fiber.Map{"error": err.Error()})
+	}
+
+	log.Printf("[GetSubscriptionHistory] === Returning %d subscriptions for userID: %d ===", len(subs), userID)
+	return c.JSON(fiber.Map{"data": subs})
+}
+
+// GetEntitlements godoc
+// @Summary     Get effective entitlements for a user
+// @Description Entitlements granted by the active subscription, with defaults for everything else
+// @Tags        subscriptions
+// @Produce     json
+// @Success     200 {object} models.EntitlementSet
+// @Failure     400 {object} map[string]string
+// @Failure     500 {object} map[string]string
+// @Router      /api/subs/entitlements [get]
+// @Security    BearerAuth
+func (h *SubscriptionHandler) GetEntitlements(c *fiber.Ctx) error {
+	log.Println("[GetEntitlements] === Starting GetEntitlements request ===")
+
+	userID, ok := c.Locals("userId").(int)
+	if !ok {
+		log.Println("[GetEntitlements] Failed to extract userID from context")
+		return c.Status(400).JSON(fiber.Map{"error": "Invalid user context"})
+	}
+
+	entitlements, err := h.service.GetEntitlements(userID)
+	if err != nil {
+		log.Printf("[GetEntitlements] Service returned error: %v", err)
+		return c.Status(500).JSON(fiber.Map{"error": err.Error()})
+	}
+
+	log.Printf("[GetEntitlements] === Returning %d entitlements for userID: %d ===", len(entitlements), userID)
+	return c.JSON(fiber.Map{"data": entitlements})
+}
+
+// AttachAddOn godoc
+// @Summary     Attach an add-on to the current subscription
+// @Description Attaching an add-on that is already on the subscription changes its quantity
+// @Tags        subscriptions
+// @Accept      json
+// @Produce     json
+// @Param       input body AddOnIdInput true "Add-on ID and quantity (defaults to 1)"
+// @Success     200 {object} models.Subscription
+// @Failure     400 {object} map[string]string
+// @Failure     404 {object} map[string]string
+// @Failure     409 {object} map[string]string
+// @Failure     422 {object} map[string]string
+// @Failure     500 {object} map[string]string
+// @Router      /api/subs/subscription/addons [post]
+// @Security    BearerAuth
+func (h *SubscriptionHandler) AttachAddOn(c *fiber.Ctx) error {
+	log.Println("[AttachAddOn] === Starting AttachAddOn request ===")
+
+	userID, ok := c.Locals("userId").(int)
+	if !ok {
+		log.Println("[AttachAddOn] Failed to extract userID from context")
+		return c.Status(400).JSON(fiber.Map{"error": "Invalid user context"})
+	}
+
+	var input AddOnIdInput
+	if err := c.BodyParser(&input); err != nil {
+		log.Printf("[AttachAddOn] Failed to parse request body: %v", err)
+		return c.Status(400).JSON(fiber.Map{"error": "Invalid input"})
+	}
+	if err := utils.ValidateStruct(input); err != nil {
+		log.Printf("[AttachAddOn] Struct validation failed: %v", err)
+		return c.Status(400).JSON(fiber.Map{"error": err.Error()})
+	}
+	if input.Quantity == 0 {
+		input.Quantity = 1
+	}
+
+	sub, err := h.service.AttachAddOn(userID, input.AddOnId, input.Quantity)
+	if err != nil {
+		log.Printf("[AttachAddOn] Service returned error: %v", err)
+		return c.Status(subscriptionErrorStatus(err)).JSON(fiber.Map{"error": err.Error()})
+	}
+
+	log.Printf("[AttachAddOn] === Attached add-on %d for userID: %d ===", input.AddOnId, userID)
+	return c.JSON(fiber.Map{"data": sub})
+}
+
+// DetachAddOn godoc
+// @Summary     Detach an add-on from the current subscription
+// @Tags        subscriptions
+// @Produce     json
+// @Param       addOnId path int true "Add-on ID"
+// @Success     200 {object} models.Subscription
+// @Failure     400 {object} map[string]string
+// @Failure     404 {object} map[string]string
+// @Failure     500 {object} map[string]string
+// @Router      /api/subs/subscription/addons/{addOnId} [delete]
+// @Security    BearerAuth
+func (h *SubscriptionHandler) DetachAddOn(c *fiber.Ctx) error {
+	log.Println("[DetachAddOn] === Starting DetachAddOn request ===")
+
+	userID, ok := c.Locals("userId").(int)
+	if !ok {
+		log.Println("[DetachAddOn] Failed to extract userID from context")
+		return c.Status(400).JSON(fiber.Map{"error": "Invalid user context"})
+	}
+
+	addOnID, err := c.ParamsInt("addOnId")
+	if err != nil || addOnID <= 0 {
+		log.Printf("[DetachAddOn] Invalid add-on id: %s", c.Params("addOnId"))
+		return c.Status(400).JSON(fiber.Map{"error": "Invalid add-on id"})
+	}
+
+	sub, err := h.service.DetachAddOn(userID, addOnID)
+	if err != nil {
+		log.Printf("[DetachAddOn] Service returned error: %v", err)
+		return c.Status(subscriptionErrorStatus(err)).JSON(fiber.Map{"error": err.Error()})
+	}
+
+	log.Printf("[DetachAddOn] === Detached add-on %d for userID: %d ===", addOnID, userID)
+	return c.JSON(fiber.Map{"data": sub})
+}
+
+// ChangeQuantity godoc
+// @Summary     Change the number of seats on the current subscription
+// @Description Added seats are charged and removed seats credited for the rest of the current period. The count must stay within the plan's bounds and cannot drop below the seats assigned.
+// @Tags        subscriptions
+// @Accept      json
+// @Produce     json
+// @Param       input body QuantityInput true "New number of seats"
+// @Success     200 {object} models.QuantityChange
+// @Failure     400 {object} map[string]string
+// @Failure     404 {object} map[string]string
+// @Failure     409 {object} map[string]string
+// @Failure     422 {object} map[string]string
+// @Failure     500 {object} map[string]string
+// @Router      /api/subs/subscription/quantity [put]
+// @Security    BearerAuth
+func (h *SubscriptionHandler) ChangeQuantity(c *fiber.Ctx) error {
+	log.Println("[ChangeQuantity] === Starting ChangeQuantity request ===")
+
+	userID, ok := c.Locals("userId").(int)
+	if !ok {
+		log.Println("[ChangeQuantity] Failed to extract userID from context")
+		return c.Status(400).JSON(fiber.Map{"error": "Invalid user context"})
+	}
+
+	var input QuantityInput
+	if err := c.BodyParser(&input); err != nil {
+		log.Printf("[ChangeQuantity] Failed to parse request body: %v", err)
+		return c.Status(400).JSON(fiber.Map{"error": "Invalid input"})
+	}
+	if err := utils.ValidateStruct(input); err != nil {
+		log.Printf("[ChangeQuantity] Struct validation failed: %v", err)
+		return c.Status(400).JSON(fiber.Map{"error": err.Error()})
+	}
+
+	change, err := h.service.ChangeQuantity(userID, input.Quantity)
+	if err != nil {
+		log.Printf("[ChangeQuantity] Service returned error: %v", err)
+		return c.Status(subscriptionErrorStatus(err)).JSON(fiber.Map{"error": err.Error()})
+	}
+
+	log.Printf("[ChangeQuantity] === Set %d seats for userID: %d ===", change.Quantity, userID)
+	return c.JSON(fiber.Map{"data": change})
+}
+
+// GetSeatUsage godoc
+// @Summary     Report seat usage on the current subscription
+// @Tags        subscriptions
+// @Produce     json
+// @Success     200 {object} models.SeatUsage
+// @Failure     400 {object} map[string]string
+// @Failure     404 {object} map[string]string
+// @Failure     500 {object} map[string]string
+// @Router      /api/subs/subscription/seats [get]
+// @Security    BearerAuth
+func (h *SubscriptionHandler) GetSeatUsage(c *fiber.Ctx) error {
+	userID, ok := c.Locals("userId").(int)
+	if !ok {
+		return c.Status(400).JSON(fiber.Map{"error": "Invalid user context"})
+	}
+
+	usage, err := h.service.GetSeatUsage(userID)
+	if err != nil {
+		log.Printf("[GetSeatUsage] Service returned error: %v", err)
+		return c.Status(subscriptionErrorStatus(err)).JSON(fiber.Map{"error": err.Error()})
+	}
+	return c.JSON(fiber.Map{"data": usage})
+}
+
+// AssignSeat godoc
+// @Summary     Assign a seat on the current subscription to a user
+// @Description Refused with 409 when every seat bought is already assigned
+// @Tags        subscriptions
+// @Accept      json
+// @Produce     json
+// @Param       input body SeatInput true "User to give the seat to"
+// @Success     200 {object} models.SeatUsage
+// @Failure     400 {object} map[string]string
+// @Failure     404 {object} map[string]string
+// @Failure     409 {object} map[string]string
+// @Failure     500 {object} map[string]string
+// @Router      /api/subs/subscription/seats [post]
+// @Security    BearerAuth
+func (h *SubscriptionHandler) AssignSeat(c *fiber.Ctx) error {
+	log.Println("[AssignSeat] === Starting AssignSeat request ===")
+
+	userID, ok := c.Locals("userId").(int)
+	if !ok {
+		log.Println("[AssignSeat] Failed to extract userID from context")
+		return c.Status(400).JSON(fiber.Map{"error": "Invalid user context"})
+	}
+
+	var input SeatInput
+	if err := c.BodyParser(&input); err != nil {
+		log.Printf("[AssignSeat] Failed to parse request body: %v", err)
+		return c.Status(400).JSON(fiber.Map{"error": "Invalid input"})
+	}
+	if err := utils.ValidateStruct(input); err != nil {
+		log.Printf("[AssignSeat] Struct validation failed: %v", err)
+		return c.Status(400).JSON(fiber.Map{"error": err.Error()})
+	}
+
+	usage, err := h.service.AssignSeat(userID, input.UserId)
+	if err != nil {
+		log.Printf("[AssignSeat] Service returned error: %v", err)
+		return c.Status(subscriptionErrorStatus(err)).JSON(fiber.Map{"error": err.Error()})
+	}
+
+	log.Printf("[AssignSeat] === Assigned a seat to user %d for userID: %d ===", input.UserId, userID)
+	return c.JSON(fiber.Map{"data": usage})
+}
+
+// UnassignSeat godoc
+// @Summary     Free a seat on the current subscription
+// @Tags        subscriptions
+// @Produce     json
+// @Param       userId path int true "User holding the seat"
+// @Success     200 {object} models.SeatUsage
+// @Failure     400 {object} map[string]string
+// @Failure     404 {object} map[string]string
+// @Failure     500 {object} map[string]string
+// @Router      /api/subs/subscription/seats/{userId} [delete]
+// @Security    BearerAuth
+func (h *SubscriptionHandler) UnassignSeat(c *fiber.Ctx) error {
+	log.Println("[UnassignSeat] === Starting UnassignSeat request ===")
+
+	userID, ok := c.Locals("userId").(int)
+	if !ok {
+		log.Println("[UnassignSeat] Failed to extract userID from context")
+		return c.Status(400).JSON(fiber.Map{"error": "Invalid user context"})
+	}
+
+	memberID, err := c.ParamsInt("userId")
+	if err != nil || memberID <= 0 {
+		log.Printf("[UnassignSeat] Invalid user id: %s", c.Params("userId"))
+		return c.Status(400).JSON(fiber.Map{"error": "Invalid user id"})
+	}
+
+	usage, err := h.service.UnassignSeat(userID, memberID)
+	if err != nil {
+		log.Printf("[UnassignSeat] Service returned error: %v", err)
+		return c.Status(subscriptionErrorStatus(err)).JSON(fiber.Map{"error": err.Error()})
+	}
+
+	log.Printf("[UnassignSeat] === Freed the seat of user %d for userID: %d ===", memberID, userID)
+	return c.JSON(fiber.Map{"data": usage})
+}
+
+// GetMigrationJobs godoc
+// @Summary     List bulk plan migrations
+// @Tags        admin
+// @Produce     json
+// @Success     200 {array} models.MigrationJob
+// @Failure     500 {object} map[string]string
+// @Router      /api/admin/migrations [get]
+// @Security    AdminToken
+func (h *SubscriptionHandler) GetMigrationJobs(c *fiber.Ctx) error {
+	jobs, err := h.service.GetMigrationJobs()
+	if err != nil {
+		return c.Status(500).JSON(fiber.Map{"error": err.Error()})
+	}
+	return c.JSON(fiber.Map{"data": jobs})
+}
+
+// CreateMigrationJob godoc
+// @Summary     Move every subscription on a plan to another plan
+// @Description Subscriptions are moved right away, chunk_size at a time (100 by default) in one transaction per chunk. Subscriptions keep their billing period when both plans bill on the same interval, pay the target plan price and lose add-ons it does not offer. Trials keep running until they end and paused subscriptions keep their period. Past due subscriptions are skipped. A dry run goes through every subscription and rolls each one back, reporting the ones a real run would move as would_migrate.
+// @Tags        admin
+// @Accept      json
+// @Produce     json
+// @Param       input body MigrationJobInput true "Migration job"
+// @Success     201 {object} models.MigrationJob
+// @Failure     400 {object} map[string]string
+// @Failure     404 {object} map[string]string
+// @Failure     500 {object} map[string]string
+// @Router      /api/admin/migrations [post]
+// @Security    AdminToken
+func (h *SubscriptionHandler) CreateMigrationJob(c *fiber.Ctx) error {
+	var input MigrationJobInput
+	if err := c.BodyParser(&input); err != nil {
+		return c.Status(400).JSON(fiber.Map{"error": "Invalid input"})
+	}
+	if err := utils.ValidateStruct(input); err != nil {
+		return c.Status(400).JSON(fiber.Map{"error": err.Error()})
+	}
+	if input.ChunkSize == 0 {
+		input.ChunkSize = 100
+	}
+
+	job, err := h.service.CreateMigrationJob(models.MigrationJob{
+		FromPlanID: input.FromPlanID,
+		ToPlanID:   input.ToPlanID,
+		DryRun:     input.DryRun,
+		ChunkSize:  input.ChunkSize,
+	})
+	if err != nil {
+		return c.Status(subscriptionErrorStatus(err)).JSON(fiber.Map{"error": err.Error()})
+	}
+	return c.Status(201).JSON(fiber.Map{"data": job})
+}
+
+// GetMigrationJob godoc
+// @Summary     Report the progress of a bulk plan migration
+// @Tags        admin
+// @Produce     json
+// @Param       id path int true "Migration job ID"
+// @Success     200 {object} models.MigrationJob
+// @Failure     400 {object} map[string]string
+// @Failure     404 {object} map[string]string
+// @Failure     500 {object} map[string]string
+// @Router      /api/admin/migrations/{id} [get]
+// @Security    AdminToken
+func (h *SubscriptionHandler) GetMigrationJob(c *fiber.Ctx) error {
+	jobId, err := c.ParamsInt("id")
+	if err != nil || jobId <= 0 {
+		return c.Status(400).JSON(fiber.Map{"error": "Invalid migration job id"})
+	}
+
+	job, err := h.service.GetMigrationJob(jobId)
+	if err != nil {
+		return c.Status(subscriptionErrorStatus(err)).JSON(fiber.Map{"error": err.Error()})
+	}
+	return c.JSON(fiber.Map{"data": job})
+}
+
+// GetMigrationJobItems godoc
+// @Summary     List the outcome per subscriber of a bulk plan migration
+// @Tags        admin
+// @Produce     json
+// @Param       id     path  int    true  "Migration job ID"
+// @Param       status query string false "Only items with this status (pending, migrated, would_migrate, skipped, failed)"
+// @Success     200 {array} models.MigrationJobItem
+// @Failure     400 {object} map[string]string
+// @Failure     404 {object} map[string]string
+// @Failure     500 {object} map[string]string
+// @Router      /api/admin/migrations/{id}/items [get]
+// @Security    AdminToken
+func (h *SubscriptionHandler) GetMigrationJobItems(c *fiber.Ctx) error {
+	jobId, err := c.ParamsInt("id")
+	if err != nil || jobId <= 0 {
+		return c.Status(400).JSON(fiber.Map{"error": "Invalid migration job id"})
+	}
+
+	items, err := h.service.GetMigrationJobItems(jobId, models.MigrationItemStatus(c.Query("status")))
+	if err != nil {
+		return c.Status(subscriptionErrorStatus(err)).JSON(fiber.Map{"error": err.Error()})
+	}
+	return c.JSON(fiber.Map{"data": items})
+}
+
+// PauseMigrationJob godoc
+// @Summary     Pause a bulk plan migration after the current chunk
+// @Tags        admin
+// @Produce     json
+// @Param       id path int true "Migration job ID"
+// @Success     200 {object} models.MigrationJob
+// @Failure     400 {object} map[string]string
+// @Failure     404 {object} map[string]string
+// @Failure     409 {object} map[string]string
+// @Failure     500 {object} map[string]string
+// @Router      /api/admin/migrations/{id}/pause [post]
+// @Security    AdminToken
+func (h *SubscriptionHandler) PauseMigrationJob(c *fiber.Ctx) error {
+	return h.setMigrationJobStatus(c, models.MigrationPaused)
+}
+
+// ResumeMigrationJob godoc
+// @Summary     Resume a paused bulk plan migration
+// @Tags        admin
+// @Produce     json
+// @Param       id path int true "Migration job ID"
+// @Success     200 {object} models.MigrationJob
+// @Failure     400 {object} map[string]string
+// @Failure     404 {object} map[string]string
+// @Failure     409 {object} map[string]string
+// @Failure     500 {object} map[string]string
+// @Router      /api/admin/migrations/{id}/resume [post]
+// @Security    AdminToken
+func (h *SubscriptionHandler) ResumeMigrationJob(c *fiber.Ctx) error {
+	return h.setMigrationJobStatus(c, models.MigrationRunning)
+}
+
+// CancelMigrationJob godoc
+// @Summary     Cancel a bulk plan migration
+// @Description Subscriptions already moved stay on the target plan; the others are left pending
+// @Tags        admin
+// @Produce     json
+// @Param       id path int true "Migration job ID"
+// @Success     200 {object} models.MigrationJob
+// @Failure     400 {object} map[string]string
+// @Failure     404 {object} map[string]string
+// @Failure     409 {object} map[string]string
+// @Failure     500 {object} map[string]string
+// @Router      /api/admin/migrations/{id}/cancel [post]
+// @Security    AdminToken
+func (h *SubscriptionHandler) CancelMigrationJob(c *fiber.Ctx) error {
+	return h.setMigrationJobStatus(c, models.MigrationCancelled)
+}
+
+func (h *SubscriptionHandler) setMigrationJobStatus(c *fiber.Ctx, status models.MigrationJobStatus) error {
+	jobId, err := c.ParamsInt("id")
+	if err != nil || jobId <= 0 {
+		return c.Status(400).JSON(fiber.Map{"error": "Invalid migration job id"})
+	}
+
+	job, err := h.service.SetMigrationJobStatus(jobId, status)
+	if err != nil {
+		return c.Status(subscriptionErrorStatus(err)).JSON(fiber.Map{"error": err.Error()})
+	}
+	return c.JSON(fiber.Map{"data": job})
+}
+
+// GetSubscriptionTransitions godoc
+// @Summary     List the status transitions of a subscription
+// @Description Every status change of the subscription, oldest first, with who made it and why
+// @Tags        admin
+// @Produce     json
+// @Param       id path int true "Subscription ID"
+// @Success     200 {array}  models.StatusTransition
+// @Failure     400 {object} map[string]string
+// @Failure     404 {object} map[string]string
+// @Failure     500 {object} map[string]string
+// @Router      /api/admin/subscriptions/{id}/transitions [get]
+// @Security    AdminToken
+func (h *SubscriptionHandler) GetSubscriptionTransitions(c *fiber.Ctx) error {
+	subscriptionId, err := c.ParamsInt("id")
+	if err != nil || subscriptionId <= 0 {
+		return c.Status(400).JSON(fiber.Map{"error": "Invalid subscription id"})
+	}
+
+	transitions, err := h.service.GetSubscriptionTransitions(subscriptionId)
+	if err != nil {
+		return c.Status(subscriptionErrorStatus(err)).JSON(fiber.Map{"error": err.Error()})
+	}
+	return c.JSON(fiber.Map{"data": transitions})
+}
+
+// GetExpiryJobStatus godoc
+// @Summary     Get the last run of the expiry sweeper
+// @Description When the job last ran, how many subscriptions it expired and the error it stopped on, if any
+// @Tags        admin
+// @Produce     json
+// @Success     200 {object} models.JobRun
+// @Failure     404 {object} map[string]string
+// @Failure     500 {object} map[string]string
+// @Router      /api/admin/jobs/expiry [get]
+// @Security    AdminToken
+func (h *SubscriptionHandler) GetExpiryJobStatus(c *fiber.Ctx) error {
+	run, err := h.service.GetExpiryJobStatus()
+	if err != nil {
+		return c.Status(subscriptionErrorStatus(err)).JSON(fiber.Map{"error": err.Error()})
+	}
+	return c.JSON(fiber.Map{"data": run})
+}
+
+func subscriptionErrorStatus(err error) int {
+	switch {
+	case errors.Is(err, repository.ErrInvalidMigrationJob), errors.Is(err, repository.ErrInvalidCancellation),
+		errors.Is(err, repository.ErrInvalidPause):
+		return 400
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		return 404
+	case errors.Is(err, repository.ErrSubscriptionNotActive), errors.Is(err, repository.ErrMigrationJobState),
+		errors.Is(err, repository.ErrAlreadySubscribed), errors.Is(err, repository.ErrNoPendingCancellation),
+		errors.Is(err, repository.ErrCancellationPending), errors.Is(err, repository.ErrSubscriptionNotPaused),
+		errors.Is(err, repository.ErrStatusTransition), errors.Is(err, repository.ErrSeatsInUse),
+		errors.Is(err, repository.ErrNoSeatAvailable):
+		return 409
+	case errors.Is(err, repository.ErrAddOnNotCompatible), errors.Is(err, repository.ErrPlanUnavailable),
+		errors.Is(err, repository.ErrTransitionNotAllowed), errors.Is(err, repository.ErrPlanSoldOut),
+		errors.Is(err, repository.ErrInvalidQuantity):
+		return 422
+	}
+	return 500
+}
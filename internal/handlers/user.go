@@ -1,11 +1,14 @@
 package handlers
 
 import (
+	"errors"
 	"log"
 
+	"github.com/Harshal292004/subscription-service/internal/models"
 	"github.com/Harshal292004/subscription-service/internal/services"
 	"github.com/Harshal292004/subscription-service/internal/utils"
 	"github.com/gofiber/fiber/v2"
+	"gorm.io/gorm"
 )
 
 type UserHandler struct {
@@ -22,6 +25,16 @@ func RegisterUserRoutes(r fiber.Router, service *services.UserService) {
 	log.Println("[RegisterUserRoutes] User routes registered successfully")
 }
 
+// RegisterAdminUserRoutes godoc
+// @Summary     Manage organizations
+// @Tags        admin
+func RegisterAdminUserRoutes(r fiber.Router, service *services.UserService) {
+	h := &UserHandler{service}
+	r.Post("/organizations", h.CreateOrganization)
+	r.Put("/organizations/:id/members/:userId", h.AddOrganizationMember)
+	r.Delete("/organizations/:id/members/:userId", h.RemoveOrganizationMember)
+}
+
 type RegisterInput struct {
 	Name     string `json:"name" validate:"required,min=3"`
 	Password string `json:"password" validate:"required,min=6"`
@@ -71,3 +84,104 @@ func (h *UserHandler) Register(c *fiber.Ctx) error {
 	log.Println("[Register] === Returning successful response ===")
 	return c.JSON(fiber.Map{"token": token})
 }
+
+type OrganizationInput struct {
+	Name string `json:"name" validate:"required,max=100"`
+}
+
+// CreateOrganization godoc
+// @Summary     Create an organization
+// @Description Private plans can be offered to all members of an organization
+// @Tags        admin
+// @Accept      json
+// @Produce     json
+// @Param       input body OrganizationInput true "Organization"
+// @Success     201 {object} models.Organization
+// @Failure     400 {object} map[string]string
+// @Failure     500 {object} map[string]string
+// @Router      /api/admin/organizations [post]
+// @Security    AdminToken
+func (h *UserHandler) CreateOrganization(c *fiber.Ctx) error {
+	var input OrganizationInput
+	if err := c.BodyParser(&input); err != nil {
+		return c.Status(400).JSON(fiber.Map{"error": "Invalid input"})
+	}
+	if err := utils.ValidateStruct(input); err != nil {
+		return c.Status(400).JSON(fiber.Map{"error": err.Error()})
+	}
+
+	org, err := h.service.CreateOrganization(models.Organization{Name: input.Name})
+	if err != nil {
+		return c.Status(500).JSON(fiber.Map{"error": err.Error()})
+	}
+	return c.Status(201).JSON(fiber.Map{"data": org})
+}
+
+// AddOrganizationMember godoc
+// @Summary     Add a user to an organization
+// @Description A user belongs to at most one organization; adding them to another one moves them
+// @Tags        admin
+// @Produce     json
+// @Param       id     path int true "Organization ID"
+// @Param       userId path int true "User ID"
+// @Success     200 {object} models.User
+// @Failure     400 {object} map[string]string
+// @Failure     404 {object} map[string]string
+// @Failure     500 {object} map[string]string
+// @Router      /api/admin/organizations/{id}/members/{userId} [put]
+// @Security    AdminToken
+func (h *UserHandler) AddOrganizationMember(c *fiber.Ctx) error {
+	orgId, userId, err := parseMemberParams(c)
+	if err != nil {
+		return c.Status(400).JSON(fiber.Map{"error": err.Error()})
+	}
+
+	user, err := h.service.AddOrganizationMember(orgId, userId)
+	if err != nil {
+		return c.Status(userErrorStatus(err)).JSON(fiber.Map{"error": err.Error()})
+	}
+	return c.JSON(fiber.Map{"data": user})
+}
+
+// RemoveOrganizationMember godoc
+// @Summary     Remove a user from an organization
+// @Description Subscriptions already bought on the organization's private plans are kept
+// @Tags        admin
+// @Param       id     path int true "Organization ID"
+// @Param       userId path int true "User ID"
+// @Success     204
+// @Failure     400 {object} map[string]string
+// @Failure     404 {object} map[string]string
+// @Failure     500 {object} map[string]string
+// @Router      /api/admin/organizations/{id}/members/{userId} [delete]
+// @Security    AdminToken
+func (h *UserHandler) RemoveOrganizationMember(c *fiber.Ctx) error {
+	orgId, userId, err := parseMemberParams(c)
+	if err != nil {
+		return c.Status(400).JSON(fiber.Map{"error": err.Error()})
+	}
+
+	if err := h.service.RemoveOrganizationMember(orgId, userId); err != nil {
+		return c.Status(userErrorStatus(err)).JSON(fiber.Map{"error": err.Error()})
+	}
+	return c.SendStatus(204)
+}
+
+func parseMemberParams(c *fiber.Ctx) (int, int, error) {
+	orgId, err := c.ParamsInt("id")
+	if err != nil || orgId <= 0 {
+		return 0, 0, errors.New("Invalid organization id")
+	}
+	userId, err := c.ParamsInt("userId")
+	if err != nil || userId <= 0 {
+		return 0, 0, errors.New("Invalid user id")
+	}
+	return orgId, userId, nil
+}
+
+func userErrorStatus(err error) int {
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return 404
+	}
+	return 500
+}
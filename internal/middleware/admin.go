@@ -0,0 +1,32 @@
+package middleware
+
+import (
+	"crypto/subtle"
+	"log"
+	"os"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// AdminMiddleware guards operator endpoints with the shared ADMIN_TOKEN,
+// sent in the X-Admin-Token header. The admin API is disabled when the
+// token is not configured.
+func AdminMiddleware() fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		log.Printf("[AdminMiddleware] === Checking admin token for path: %s ===", c.Path())
+
+		adminToken := os.Getenv("ADMIN_TOKEN")
+		if adminToken == "" {
+			log.Println("[AdminMiddleware] ADMIN_TOKEN not set, admin API disabled")
+			return c.Status(fiber.StatusForbidden).JSON(fiber.Map{"error": "admin API disabled"})
+		}
+
+		token := c.Get("X-Admin-Token")
+		if subtle.ConstantTimeCompare([]byte(token), []byte(adminToken)) != 1 {
+			log.Println("[AdminMiddleware] Admin token missing or invalid")
+			return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{"error": "invalid admin token"})
+		}
+
+		return c.Next()
+	}
+}
@@ -53,3 +53,30 @@ func AuthMiddleware() fiber.Handler {
 		return c.Next()
 	}
 }
+
+// OptionalAuthMiddleware identifies the user when a bearer token is sent, so
+// public endpoints can personalize their response, and lets anonymous
+// requests through. An invalid token is still rejected.
+func OptionalAuthMiddleware() fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		authHeader := c.Get("Authorization")
+		if authHeader == "" {
+			return c.Next()
+		}
+
+		if !strings.HasPrefix(authHeader, "Bearer ") {
+			log.Println("[OptionalAuthMiddleware] Authorization header doesn't start with 'Bearer '")
+			return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{"error": "invalid token"})
+		}
+
+		userID, err := utils.ValidateSession(strings.TrimPrefix(authHeader, "Bearer "))
+		if err != nil {
+			log.Printf("[OptionalAuthMiddleware] Token validation failed: %v", err)
+			return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{"error": "invalid token"})
+		}
+
+		log.Printf("[OptionalAuthMiddleware] Request identified as userID: %d", userID)
+		c.Locals("userId", int(userID))
+		return c.Next()
+	}
+}
@@ -0,0 +1,58 @@
+package models
+
+import (
+	"errors"
+	"time"
+)
+
+// AddOn is an extra that can be bought on top of a base plan.
+type AddOn struct {
+	ID            uint           `gorm:"primaryKey" json:"id"`
+	Code          string         `gorm:"size:100;not null;unique" json:"code"`
+	Name          string         `gorm:"size:100;not null" json:"name"`
+	Price         float64        `gorm:"not null" json:"price"`
+	Entitlements  EntitlementSet `gorm:"type:jsonb;not null" json:"entitlements" swaggertype:"object"`
+	Compatibility []PlanAddOn    `gorm:"foreignKey:AddOnID" json:"compatibility"`
+	CreatedAt     time.Time      `json:"created_at"`
+	UpdatedAt     time.Time      `json:"updated_at"`
+}
+
+// PlanAddOn makes an add-on available on a base plan, up to MaxQuantity
+// units per subscription.
+type PlanAddOn struct {
+	PlanID      uint `gorm:"primaryKey" json:"plan_id"`
+	AddOnID     uint `gorm:"primaryKey" json:"-"`
+	MaxQuantity int  `gorm:"not null;default:1" json:"max_quantity"`
+}
+
+type SubscriptionAddOn struct {
+	ID             uint      `gorm:"primaryKey" json:"id"`
+	SubscriptionID uint      `gorm:"not null" json:"subscription_id"`
+	AddOnID        uint      `gorm:"not null" json:"add_on_id"`
+	Quantity       int       `gorm:"not null" json:"quantity"`
+	CreatedAt      time.Time `json:"created_at"`
+	UpdatedAt      time.Time `json:"updated_at"`
+	AddOn          *AddOn    `gorm:"foreignKey:AddOnID" json:"add_on,omitempty"`
+}
+
+func (a AddOn) Validate(defs []EntitlementDefinition) error {
+	if a.Price < 0 {
+		return errors.New("price must not be negative")
+	}
+	for _, rule := range a.Compatibility {
+		if rule.MaxQuantity < 1 {
+			return errors.New("max_quantity must be at least 1")
+		}
+	}
+	return ValidateEntitlements(defs, a.Entitlements)
+}
+
+// Rule returns the compatibility rule of the add-on for a base plan.
+func (a AddOn) Rule(planId uint) (PlanAddOn, bool) {
+	for _, rule := range a.Compatibility {
+		if rule.PlanID == planId {
+			return rule, true
+		}
+	}
+	return PlanAddOn{}, false
+}
@@ -0,0 +1,74 @@
+package models
+
+import (
+	"database/sql/driver"
+	"fmt"
+	"time"
+)
+
+type IntervalUnit string
+
+const (
+	IntervalDay   IntervalUnit = "day"
+	IntervalWeek  IntervalUnit = "week"
+	IntervalMonth IntervalUnit = "month"
+	IntervalYear  IntervalUnit = "year"
+)
+
+// Value stores an empty unit as NULL so plans without a calendar interval
+// keep falling back to duration_days.
+func (u IntervalUnit) Value() (driver.Value, error) {
+	if u == "" {
+		return nil, nil
+	}
+	return string(u), nil
+}
+
+func (u *IntervalUnit) Scan(value interface{}) error {
+	switch v := value.(type) {
+	case nil:
+		*u = ""
+	case string:
+		*u = IntervalUnit(v)
+	case []byte:
+		*u = IntervalUnit(v)
+	default:
+		return fmt.Errorf("cannot scan %T into IntervalUnit", value)
+	}
+	return nil
+}
+
+func (u IntervalUnit) Valid() bool {
+	switch u {
+	case IntervalDay, IntervalWeek, IntervalMonth, IntervalYear:
+		return true
+	}
+	return false
+}
+
+// AddInterval advances anchor by n intervals of count units. Month and year
+// intervals are always computed from the anchor, not from the previous period
+// end, and are clamped to the last day of shorter months: an anchor of Jan 31
+// yields Feb 28, then Mar 31.
+func AddInterval(anchor time.Time, unit IntervalUnit, count, n int) time.Time {
+	switch unit {
+	case IntervalDay:
+		return anchor.AddDate(0, 0, count*n)
+	case IntervalWeek:
+		return anchor.AddDate(0, 0, 7*count*n)
+	case IntervalMonth:
+		return addMonthsClamped(anchor, count*n)
+	case IntervalYear:
+		return addMonthsClamped(anchor, 12*count*n)
+	}
+	return anchor
+}
+
+func addMonthsClamped(t time.Time, months int) time.Time {
+	year, month, day := t.Date()
+	first := time.Date(year, month+time.Month(months), 1, 0, 0, 0, 0, t.Location())
+	if last := first.AddDate(0, 1, -1).Day(); day > last {
+		day = last
+	}
+	return time.Date(first.Year(), first.Month(), day, t.Hour(), t.Minute(), t.Second(), t.Nanosecond(), t.Location())
+}
@@ -0,0 +1,68 @@
+package models
+
+import (
+	"testing"
+	"time"
+)
+
+func date(year int, month time.Month, day int) time.Time {
+	return time.Date(year, month, day, 9, 30, 0, 0, time.UTC)
+}
+
+func TestAddInterval(t *testing.T) {
+	tests := []struct {
+		name   string
+		anchor time.Time
+		unit   IntervalUnit
+		count  int
+		n      int
+		want   time.Time
+	}{
+		{"day", date(2025, time.January, 30), IntervalDay, 3, 1, date(2025, time.February, 2)},
+		{"week", date(2025, time.January, 1), IntervalWeek, 2, 2, date(2025, time.January, 29)},
+		{"month end clamps to february", date(2025, time.January, 31), IntervalMonth, 1, 1, date(2025, time.February, 28)},
+		{"month end returns to the 31st", date(2025, time.January, 31), IntervalMonth, 1, 2, date(2025, time.March, 31)},
+		{"month end clamps to april", date(2025, time.January, 31), IntervalMonth, 1, 3, date(2025, time.April, 30)},
+		{"leap february", date(2024, time.January, 31), IntervalMonth, 1, 1, date(2024, time.February, 29)},
+		{"quarterly", date(2025, time.November, 30), IntervalMonth, 3, 1, date(2026, time.February, 28)},
+		{"year from leap day", date(2024, time.February, 29), IntervalYear, 1, 1, date(2025, time.February, 28)},
+		{"year back to leap day", date(2024, time.February, 29), IntervalYear, 1, 4, date(2028, time.February, 29)},
+		{"zero periods", date(2025, time.January, 31), IntervalMonth, 1, 0, date(2025, time.January, 31)},
+		{"unknown unit", date(2025, time.January, 31), IntervalUnit("fortnight"), 1, 1, date(2025, time.January, 31)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := AddInterval(tt.anchor, tt.unit, tt.count, tt.n); !got.Equal(tt.want) {
+				t.Errorf("AddInterval() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestNextPeriodEnd(t *testing.T) {
+	plan := Plan{IntervalUnit: IntervalMonth, IntervalCount: 1}
+	anchor := date(2025, time.January, 31)
+	tests := []struct {
+		name  string
+		after time.Time
+		want  time.Time
+	}{
+		{"before the first boundary", date(2025, time.February, 10), date(2025, time.February, 28)},
+		{"on a boundary moves to the next", date(2025, time.February, 28), date(2025, time.March, 31)},
+		{"several periods later", date(2025, time.May, 1), date(2025, time.May, 31)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := plan.NextPeriodEnd(anchor, tt.after); !got.Equal(tt.want) {
+				t.Errorf("NextPeriodEnd() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestPlanIntervalFallsBackToDuration(t *testing.T) {
+	plan := Plan{Duration: 30}
+	if got, want := plan.PeriodEnd(date(2025, time.January, 31), 1), date(2025, time.March, 2); !got.Equal(want) {
+		t.Errorf("PeriodEnd() = %v, want %v", got, want)
+	}
+}
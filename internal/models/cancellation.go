@@ -0,0 +1,70 @@
+package models
+
+import (
+	"errors"
+	"time"
+)
+
+type CancellationMode string
+
+const (
+	CancelAtPeriodEnd CancellationMode = "period_end"
+	CancelImmediately CancellationMode = "immediate"
+)
+
+// Cancellation is a user's request to cancel their subscription. By default
+// access continues until the end of the paid period; an immediate
+// cancellation ends access now and may refund the unused part of the period.
+type Cancellation struct {
+	Mode   CancellationMode `json:"mode"`
+	Refund bool             `json:"refund"`
+}
+
+// Validate checks that a refund is only asked for with an immediate
+// cancellation, since a period-end cancellation uses the whole period.
+func (c Cancellation) Validate() error {
+	switch c.Mode {
+	case "", CancelAtPeriodEnd:
+		if c.Refund {
+			return errors.New("refund is only available with an immediate cancellation")
+		}
+	case CancelImmediately:
+	default:
+		return errors.New("mode must be period_end or immediate")
+	}
+	return nil
+}
+
+// SubscriptionCancellation is the outcome of a cancellation: the updated
+// subscription and the refund credited, if any.
+type SubscriptionCancellation struct {
+	Subscription
+	Refund *BillingLineItem `json:"refund,omitempty"`
+}
+
+func (c Cancellation) Immediate() bool {
+	return c.Mode == CancelImmediately
+}
+
+// ScheduleCancellation keeps the subscription running until the end of its
+// paid period, then cancels it. Any scheduled plan change is dropped.
+func (s *Subscription) ScheduleCancellation(now time.Time) {
+	s.CancelAtPeriodEnd = true
+	s.CancelledAt = &now
+	s.ClearPendingChange()
+}
+
+// UndoCancellation keeps a subscription scheduled for cancellation running.
+func (s *Subscription) UndoCancellation() {
+	s.CancelAtPeriodEnd = false
+	s.CancelledAt = nil
+}
+
+// UnusedAmount is the part of the amount paid on the plan for the current
+// period that has not been used yet. Nothing is owed back for a trial.
+func (s Subscription) UnusedAmount(plan Plan, now time.Time, g ProrationGranularity) float64 {
+	if s.Status == Trialing {
+		return 0
+	}
+	return roundCents(s.PeriodAmount(plan) * s.RemainingFraction(now, g))
+}
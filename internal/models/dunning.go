@@ -0,0 +1,95 @@
+package models
+
+import (
+	"errors"
+	"fmt"
+	"time"
+)
+
+// DunningPolicy is how a failed renewal payment is chased. The subscription
+// stays PAST_DUE, keeping its entitlements, for GraceDays after the first
+// failure; payment is retried RetryDays after it (retries past the grace
+// period are dropped). If the grace period runs out unpaid, the
+// subscription moves to FinalStatus.
+type DunningPolicy struct {
+	GraceDays   int
+	RetryDays   []int
+	FinalStatus SubscriptionStatus
+}
+
+// Validate checks that the policy has a grace period, ordered retries and
+// ends in EXPIRED or CANCELLED.
+func (p DunningPolicy) Validate() error {
+	if p.GraceDays < 0 {
+		return errors.New("grace period must not be negative")
+	}
+	for i, day := range p.RetryDays {
+		if day <= 0 || (i > 0 && day <= p.RetryDays[i-1]) {
+			return errors.New("retry days must be positive and increasing")
+		}
+	}
+	if p.FinalStatus != Expired && p.FinalStatus != Cancelled {
+		return fmt.Errorf("final status must be %s or %s", Expired, Cancelled)
+	}
+	return nil
+}
+
+// nextRetry returns when to retry after the given number of failed retries,
+// or nil when none are left within the grace period.
+func (p DunningPolicy) nextRetry(since time.Time, retries int) *time.Time {
+	if retries >= len(p.RetryDays) || p.RetryDays[retries] > p.GraceDays {
+		return nil
+	}
+	at := since.AddDate(0, 0, p.RetryDays[retries])
+	return &at
+}
+
+// MarkPastDue starts dunning after the renewal payment failed.
+func (s *Subscription) MarkPastDue(now time.Time, p DunningPolicy) error {
+	if err := s.Transition(PastDue, ActorSystem, "renewal payment failed", now); err != nil {
+		return err
+	}
+	graceEnds := now.AddDate(0, 0, p.GraceDays)
+	s.PastDueSince = &now
+	s.GraceEndsAt = &graceEnds
+	s.PaymentRetries = 0
+	s.NextRetryAt = p.nextRetry(now, 0)
+	return nil
+}
+
+// RetryFailed schedules the next retry after another failed payment.
+func (s *Subscription) RetryFailed(p DunningPolicy) {
+	s.PaymentRetries++
+	s.NextRetryAt = p.nextRetry(*s.PastDueSince, s.PaymentRetries)
+}
+
+// Recover ends dunning once the overdue renewal is paid.
+func (s *Subscription) Recover(now time.Time) error {
+	if err := s.Transition(Active, ActorSystem, "overdue payment collected", now); err != nil {
+		return err
+	}
+	s.clearDunning()
+	return nil
+}
+
+// EndDunning gives up on the overdue payment at the end of the grace period,
+// expiring or cancelling the subscription as the policy says.
+func (s *Subscription) EndDunning(now time.Time, p DunningPolicy) error {
+	const reason = "payment not collected within the grace period"
+	if p.FinalStatus == Cancelled {
+		if err := s.Cancel(now, ActorSystem, reason); err != nil {
+			return err
+		}
+	} else if err := s.Transition(Expired, ActorSystem, reason, now); err != nil {
+		return err
+	}
+	s.NextRetryAt = nil
+	return nil
+}
+
+func (s *Subscription) clearDunning() {
+	s.PastDueSince = nil
+	s.GraceEndsAt = nil
+	s.NextRetryAt = nil
+	s.PaymentRetries = 0
+}
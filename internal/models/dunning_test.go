@@ -0,0 +1,129 @@
+package models
+
+import (
+	"testing"
+	"time"
+)
+
+func TestDunningPolicyValidate(t *testing.T) {
+	tests := []struct {
+		name    string
+		policy  DunningPolicy
+		wantErr bool
+	}{
+		{"expire after retries", DunningPolicy{GraceDays: 7, RetryDays: []int{1, 3, 5}, FinalStatus: Expired}, false},
+		{"cancel without retries", DunningPolicy{GraceDays: 0, FinalStatus: Cancelled}, false},
+		{"negative grace period", DunningPolicy{GraceDays: -1, FinalStatus: Expired}, true},
+		{"retry on the day of failure", DunningPolicy{GraceDays: 7, RetryDays: []int{0, 3}, FinalStatus: Expired}, true},
+		{"retries out of order", DunningPolicy{GraceDays: 7, RetryDays: []int{3, 3}, FinalStatus: Expired}, true},
+		{"ends active", DunningPolicy{GraceDays: 7, FinalStatus: Active}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := tt.policy.Validate(); (err != nil) != tt.wantErr {
+				t.Errorf("Validate() error = %v, want error %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestDunningSchedule(t *testing.T) {
+	policy := DunningPolicy{GraceDays: 7, RetryDays: []int{1, 3, 7, 9}, FinalStatus: Expired}
+	failedAt := date(2025, time.March, 1)
+	sub := Subscription{Status: Active}
+	if err := sub.MarkPastDue(failedAt, policy); err != nil {
+		t.Fatal(err)
+	}
+	if sub.Status != PastDue || !sub.PastDueSince.Equal(failedAt) || !sub.GraceEndsAt.Equal(date(2025, time.March, 8)) {
+		t.Fatalf("after the first failure: status %s, past due since %v, grace ends %v", sub.Status, sub.PastDueSince, sub.GraceEndsAt)
+	}
+	if sub.NextRetryAt == nil || !sub.NextRetryAt.Equal(date(2025, time.March, 2)) {
+		t.Errorf("first retry at %v, want %v", sub.NextRetryAt, date(2025, time.March, 2))
+	}
+
+	tests := []struct {
+		name string
+		want *time.Time
+	}{
+		{"second retry", timePtr(date(2025, time.March, 4))},
+		{"retry on the last day of grace", timePtr(date(2025, time.March, 8))},
+		{"retry after the grace period is dropped", nil},
+		{"no retries left", nil},
+	}
+	for i, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sub.RetryFailed(policy)
+			if sub.PaymentRetries != i+1 {
+				t.Errorf("PaymentRetries = %d, want %d", sub.PaymentRetries, i+1)
+			}
+			if (sub.NextRetryAt == nil) != (tt.want == nil) || (tt.want != nil && !sub.NextRetryAt.Equal(*tt.want)) {
+				t.Errorf("NextRetryAt = %v, want %v", sub.NextRetryAt, tt.want)
+			}
+		})
+	}
+}
+
+func TestEndOfDunning(t *testing.T) {
+	policy := DunningPolicy{GraceDays: 7, RetryDays: []int{1}, FinalStatus: Expired}
+	failedAt := date(2025, time.March, 1)
+	pastDue := func() Subscription {
+		sub := Subscription{Status: Active, IsCurrent: true, EndDate: failedAt}
+		if err := sub.MarkPastDue(failedAt, policy); err != nil {
+			t.Fatal(err)
+		}
+		return sub
+	}
+
+	recovered := pastDue()
+	if err := recovered.Recover(date(2025, time.March, 2)); err != nil {
+		t.Fatal(err)
+	}
+	if recovered.Status != Active || recovered.PastDueSince != nil || recovered.GraceEndsAt != nil ||
+		recovered.NextRetryAt != nil || recovered.PaymentRetries != 0 {
+		t.Errorf("recovered = %+v, want ACTIVE with dunning cleared", recovered)
+	}
+
+	tests := []struct {
+		final       SubscriptionStatus
+		wantCurrent bool
+	}{
+		{Expired, true},
+		{Cancelled, false},
+	}
+	for _, tt := range tests {
+		t.Run(string(tt.final), func(t *testing.T) {
+			sub := pastDue()
+			policy := policy
+			policy.FinalStatus = tt.final
+			if err := sub.EndDunning(date(2025, time.March, 8), policy); err != nil {
+				t.Fatal(err)
+			}
+			if sub.Status != tt.final || sub.IsCurrent != tt.wantCurrent || sub.NextRetryAt != nil {
+				t.Errorf("status %s, current %v, next retry %v; want %s, current %v, no retry",
+					sub.Status, sub.IsCurrent, sub.NextRetryAt, tt.final, tt.wantCurrent)
+			}
+		})
+	}
+}
+
+func TestChangePlanPastDue(t *testing.T) {
+	failedAt := date(2025, time.March, 1)
+	sub := Subscription{Status: Active, IsCurrent: true, EndDate: failedAt}
+	if err := sub.MarkPastDue(failedAt, DunningPolicy{GraceDays: 7, FinalStatus: Expired}); err != nil {
+		t.Fatal(err)
+	}
+	from, to := Plan{ID: 1, Duration: 30}, Plan{ID: 2, Duration: 30}
+	now := failedAt.AddDate(0, 0, 2)
+
+	if err := sub.ChangePlan(from, to, PlanTransition{Prorate: false}, now, ActorUser); err == nil {
+		t.Fatal("ChangePlan() on a past due subscription succeeded")
+	}
+	if err := (MigrationJob{}).Migrate(&sub, from, to, now); err == nil {
+		t.Fatal("Migrate() on a past due subscription succeeded")
+	}
+	if sub.Status != PastDue || sub.PlanID != 0 || sub.GraceEndsAt == nil {
+		t.Errorf("sub = %+v, want it left past due", sub)
+	}
+}
+
+func timePtr(t time.Time) *time.Time { return &t }
@@ -0,0 +1,191 @@
+package models
+
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"fmt"
+	"math"
+	"slices"
+	"time"
+
+	"gorm.io/datatypes"
+)
+
+type EntitlementType string
+
+const (
+	EntitlementBoolean EntitlementType = "boolean"
+	EntitlementLimit   EntitlementType = "limit"
+	EntitlementEnum    EntitlementType = "enum"
+)
+
+// Unlimited is the limit value granting unrestricted usage.
+const Unlimited = -1
+
+// EntitlementDefinition is the schema entry for one entitlement key. Enum
+// values are ordered from the lowest to the highest tier.
+type EntitlementDefinition struct {
+	Key           string                      `gorm:"primaryKey;size:100" json:"key" validate:"required,max=100"`
+	Type          EntitlementType             `gorm:"size:20;not null" json:"type" validate:"required,oneof=boolean limit enum"`
+	AllowedValues datatypes.JSONSlice[string] `gorm:"type:jsonb;not null" json:"allowed_values,omitempty" swaggertype:"array,string"`
+	Description   string                      `gorm:"not null" json:"description"`
+	CreatedAt     time.Time                   `json:"created_at"`
+	UpdatedAt     time.Time                   `json:"updated_at"`
+}
+
+// EntitlementSet maps entitlement keys to their values: a bool for boolean
+// entitlements, a whole number for limits and a string for enums.
+type EntitlementSet map[string]interface{}
+
+func (s EntitlementSet) Value() (driver.Value, error) {
+	if s == nil {
+		return "{}", nil
+	}
+	data, err := json.Marshal(s)
+	return string(data), err
+}
+
+func (s *EntitlementSet) Scan(value interface{}) error {
+	var data []byte
+	switch v := value.(type) {
+	case nil:
+		*s = EntitlementSet{}
+		return nil
+	case []byte:
+		data = v
+	case string:
+		data = []byte(v)
+	default:
+		return fmt.Errorf("cannot scan %T into EntitlementSet", value)
+	}
+	return json.Unmarshal(data, s)
+}
+
+func (d EntitlementDefinition) Validate() error {
+	switch d.Type {
+	case EntitlementBoolean, EntitlementLimit:
+		if len(d.AllowedValues) > 0 {
+			return fmt.Errorf("entitlement %q: allowed_values only apply to enum entitlements", d.Key)
+		}
+	case EntitlementEnum:
+		if len(d.AllowedValues) == 0 {
+			return fmt.Errorf("entitlement %q: enum entitlements need allowed_values", d.Key)
+		}
+	default:
+		return fmt.Errorf("entitlement %q: unknown type %q", d.Key, d.Type)
+	}
+	return nil
+}
+
+// Default is the value of the entitlement for users who are not granted it.
+func (d EntitlementDefinition) Default() interface{} {
+	switch d.Type {
+	case EntitlementBoolean:
+		return false
+	case EntitlementLimit:
+		return 0
+	case EntitlementEnum:
+		if len(d.AllowedValues) > 0 {
+			return d.AllowedValues[0]
+		}
+	}
+	return nil
+}
+
+// CheckValue reports whether value is a legal value for the entitlement.
+func (d EntitlementDefinition) CheckValue(value interface{}) error {
+	switch d.Type {
+	case EntitlementBoolean:
+		if _, ok := value.(bool); !ok {
+			return fmt.Errorf("entitlement %q must be a boolean", d.Key)
+		}
+	case EntitlementLimit:
+		n, ok := limitValue(value)
+		if !ok || n < Unlimited {
+			return fmt.Errorf("entitlement %q must be a whole number >= 0, or %d for unlimited", d.Key, Unlimited)
+		}
+	case EntitlementEnum:
+		s, ok := value.(string)
+		if !ok || !slices.Contains(d.AllowedValues, s) {
+			return fmt.Errorf("entitlement %q must be one of %v", d.Key, []string(d.AllowedValues))
+		}
+	default:
+		return fmt.Errorf("entitlement %q has unknown type %q", d.Key, d.Type)
+	}
+	return nil
+}
+
+// ValidateEntitlements checks every value in set against the schema.
+func ValidateEntitlements(defs []EntitlementDefinition, set EntitlementSet) error {
+	byKey := make(map[string]EntitlementDefinition, len(defs))
+	for _, d := range defs {
+		byKey[d.Key] = d
+	}
+	for key, value := range set {
+		d, ok := byKey[key]
+		if !ok {
+			return fmt.Errorf("unknown entitlement %q", key)
+		}
+		if err := d.CheckValue(value); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// EffectiveEntitlements resolves every key of the schema to the granted
+// value, or to the default when the key is not granted.
+func EffectiveEntitlements(defs []EntitlementDefinition, granted EntitlementSet) EntitlementSet {
+	effective := make(EntitlementSet, len(defs))
+	for _, d := range defs {
+		effective[d.Key] = d.Default()
+		if value, ok := granted[d.Key]; ok && d.CheckValue(value) == nil {
+			effective[d.Key] = value
+		}
+	}
+	return effective
+}
+
+// Grant layers extra entitlements, bought quantity times, onto an effective
+// set: flags are switched on, limits grow by the extra amount per unit and
+// enums move up to the extra tier when it is higher.
+func (s EntitlementSet) Grant(defs []EntitlementDefinition, extra EntitlementSet, quantity int) {
+	for _, d := range defs {
+		value, ok := extra[d.Key]
+		if !ok || d.CheckValue(value) != nil {
+			continue
+		}
+		switch d.Type {
+		case EntitlementBoolean:
+			if value.(bool) {
+				s[d.Key] = true
+			}
+		case EntitlementLimit:
+			current, _ := limitValue(s[d.Key])
+			amount, _ := limitValue(value)
+			if current == Unlimited || amount == Unlimited {
+				s[d.Key] = Unlimited
+			} else {
+				s[d.Key] = current + amount*quantity
+			}
+		case EntitlementEnum:
+			current, _ := s[d.Key].(string)
+			if slices.Index(d.AllowedValues, value.(string)) > slices.Index(d.AllowedValues, current) {
+				s[d.Key] = value
+			}
+		}
+	}
+}
+
+func limitValue(value interface{}) (int, bool) {
+	switch v := value.(type) {
+	case int:
+		return v, true
+	case float64:
+		if v != math.Trunc(v) {
+			return 0, false
+		}
+		return int(v), true
+	}
+	return 0, false
+}
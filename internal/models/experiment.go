@@ -0,0 +1,99 @@
+package models
+
+import (
+	"errors"
+	"fmt"
+	"hash/fnv"
+	"time"
+)
+
+// Experiment tests alternative prices of one plan. Users are split between
+// the variants by weight; a running experiment has no EndedAt.
+type Experiment struct {
+	ID        uint                `gorm:"primaryKey" json:"id"`
+	Name      string              `gorm:"size:100;not null" json:"name"`
+	PlanID    uint                `gorm:"not null" json:"plan_id"`
+	StartedAt time.Time           `gorm:"not null" json:"started_at"`
+	EndedAt   *time.Time          `json:"ended_at,omitempty"`
+	Variants  []ExperimentVariant `gorm:"foreignKey:ExperimentID" json:"variants"`
+	CreatedAt time.Time           `json:"created_at"`
+	UpdatedAt time.Time           `json:"updated_at"`
+}
+
+type ExperimentVariant struct {
+	ID           uint    `gorm:"primaryKey" json:"id"`
+	ExperimentID uint    `gorm:"not null" json:"-"`
+	Name         string  `gorm:"size:100;not null" json:"name"`
+	Price        float64 `gorm:"not null" json:"price"`
+	Weight       int     `gorm:"not null" json:"weight"`
+}
+
+// ExperimentAssignment records the variant a user was shown and whether they
+// went on to subscribe at that price.
+type ExperimentAssignment struct {
+	ExperimentID uint       `gorm:"primaryKey" json:"experiment_id"`
+	UserID       uint       `gorm:"primaryKey" json:"user_id"`
+	VariantID    uint       `gorm:"not null" json:"variant_id"`
+	AssignedAt   time.Time  `gorm:"not null" json:"assigned_at"`
+	ConvertedAt  *time.Time `json:"converted_at,omitempty"`
+}
+
+// VariantResult is the outcome of one variant in an experiment report.
+type VariantResult struct {
+	VariantID      uint    `json:"variant_id"`
+	Name           string  `json:"name"`
+	Price          float64 `json:"price"`
+	Assigned       int     `json:"assigned"`
+	Converted      int     `json:"converted"`
+	ConversionRate float64 `json:"conversion_rate"`
+}
+
+// Validate checks the variants of an experiment on a plan. Variant prices
+// replace the plan price, so tiered plans cannot be tested this way.
+func (e Experiment) Validate(plan Plan) error {
+	if plan.PricingModel != PricingFlat && plan.PricingModel != PricingPerUnit {
+		return fmt.Errorf("plan %d uses %s pricing; only flat and per_unit prices can be tested", plan.ID, plan.PricingModel)
+	}
+	if len(e.Variants) < 2 {
+		return errors.New("an experiment needs at least two variants")
+	}
+	names := map[string]bool{}
+	for _, v := range e.Variants {
+		if v.Name == "" || names[v.Name] {
+			return fmt.Errorf("variant names must be set and unique, got %q", v.Name)
+		}
+		names[v.Name] = true
+		if v.Price < 0 {
+			return fmt.Errorf("variant %s: price must not be negative", v.Name)
+		}
+		if v.Weight < 1 {
+			return fmt.Errorf("variant %s: weight must be at least 1", v.Name)
+		}
+	}
+	return nil
+}
+
+// Running reports whether users are still being assigned to variants.
+func (e Experiment) Running() bool {
+	return e.EndedAt == nil
+}
+
+// Assign picks the variant of a user. The choice hashes the experiment and
+// user IDs, so a user keeps seeing the same price on every request and every
+// instance without storing anything first.
+func (e Experiment) Assign(userId uint) ExperimentVariant {
+	total := 0
+	for _, v := range e.Variants {
+		total += v.Weight
+	}
+	h := fnv.New32a()
+	fmt.Fprintf(h, "%d:%d", e.ID, userId)
+	bucket := int(h.Sum32() % uint32(total))
+	for _, v := range e.Variants {
+		if bucket < v.Weight {
+			return v
+		}
+		bucket -= v.Weight
+	}
+	return e.Variants[len(e.Variants)-1]
+}
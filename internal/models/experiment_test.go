@@ -0,0 +1,64 @@
+package models
+
+import "testing"
+
+func TestExperimentAssign(t *testing.T) {
+	experiment := Experiment{ID: 4, Variants: []ExperimentVariant{
+		{ID: 1, Name: "control", Price: 10, Weight: 3},
+		{ID: 2, Name: "higher", Price: 12, Weight: 1},
+	}}
+
+	counts := map[uint]int{}
+	for userId := uint(1); userId <= 4000; userId++ {
+		variant := experiment.Assign(userId)
+		for i := 0; i < 3; i++ {
+			if again := experiment.Assign(userId); again.ID != variant.ID {
+				t.Fatalf("user %d got variant %d, then %d", userId, variant.ID, again.ID)
+			}
+		}
+		counts[variant.ID]++
+	}
+	// 3:1 weights put about 3000 users on control; allow a few percent.
+	if counts[1] < 2850 || counts[1] > 3150 || counts[1]+counts[2] != 4000 {
+		t.Errorf("assignments = %v, want about 3000 control and 1000 higher", counts)
+	}
+
+	other := experiment
+	other.ID = 5
+	moved := 0
+	for userId := uint(1); userId <= 100; userId++ {
+		if other.Assign(userId).ID != experiment.Assign(userId).ID {
+			moved++
+		}
+	}
+	if moved == 0 {
+		t.Error("every user got the same variant in another experiment, want an independent split")
+	}
+}
+
+func TestExperimentValidate(t *testing.T) {
+	flat := Plan{ID: 2, PricingModel: PricingFlat}
+	variants := []ExperimentVariant{{Name: "a", Price: 10, Weight: 1}, {Name: "b", Price: 12, Weight: 1}}
+	tests := []struct {
+		name     string
+		plan     Plan
+		variants []ExperimentVariant
+		wantErr  bool
+	}{
+		{"two variants", flat, variants, false},
+		{"per unit plan", Plan{ID: 2, PricingModel: PricingPerUnit}, variants, false},
+		{"graduated plan", Plan{ID: 2, PricingModel: PricingGraduated}, variants, true},
+		{"one variant", flat, variants[:1], true},
+		{"duplicate names", flat, []ExperimentVariant{variants[0], variants[0]}, true},
+		{"negative price", flat, []ExperimentVariant{variants[0], {Name: "b", Price: -1, Weight: 1}}, true},
+		{"zero weight", flat, []ExperimentVariant{variants[0], {Name: "b", Price: 12}}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := Experiment{Variants: tt.variants}.Validate(tt.plan)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("Validate() error = %v, want error %v", err, tt.wantErr)
+			}
+		})
+	}
+}
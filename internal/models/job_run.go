@@ -0,0 +1,14 @@
+package models
+
+import "time"
+
+// JobRun is the outcome of the last run of a background job, kept so
+// operators can tell whether the job is running and keeping up.
+type JobRun struct {
+	Job        string     `json:"job"`
+	StartedAt  time.Time  `json:"started_at"`
+	FinishedAt *time.Time `json:"finished_at,omitempty"`
+	Processed  int        `json:"processed"`
+	Batches    int        `json:"batches"`
+	Error      string     `json:"error,omitempty"`
+}
@@ -0,0 +1,44 @@
+package models
+
+import (
+	"fmt"
+	"time"
+)
+
+type LineItemKind string
+
+const (
+	LineItemRefund          LineItemKind = "refund"
+	LineItemProrationCredit LineItemKind = "proration_credit"
+	LineItemProrationCharge LineItemKind = "proration_charge"
+)
+
+// BillingLineItem is an amount charged or credited to a user outside of the
+// regular renewal charge. Credits are negative.
+type BillingLineItem struct {
+	ID             uint         `gorm:"primaryKey" json:"id"`
+	UserID         uint         `gorm:"not null" json:"user_id"`
+	SubscriptionID uint         `gorm:"not null" json:"subscription_id"`
+	Kind           LineItemKind `gorm:"size:50;not null" json:"kind"`
+	Description    string       `gorm:"not null" json:"description"`
+	Amount         float64      `gorm:"not null" json:"amount"`
+	Currency       string       `gorm:"size:3;not null" json:"currency"`
+	PeriodStart    time.Time    `gorm:"not null" json:"period_start"`
+	PeriodEnd      time.Time    `gorm:"not null" json:"period_end"`
+	CreatedAt      time.Time    `json:"created_at"`
+}
+
+// RefundLine credits the unused part of the current period of a subscription
+// cancelled at now.
+func RefundLine(sub Subscription, plan Plan, now time.Time, g ProrationGranularity) BillingLineItem {
+	return BillingLineItem{
+		UserID:         sub.UserID,
+		SubscriptionID: sub.ID,
+		Kind:           LineItemRefund,
+		Description:    fmt.Sprintf("Refund for unused time on %s", plan.Name),
+		Amount:         -sub.UnusedAmount(plan, now, g),
+		Currency:       plan.Currency,
+		PeriodStart:    now,
+		PeriodEnd:      sub.EndDate,
+	}
+}
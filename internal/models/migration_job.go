@@ -0,0 +1,109 @@
+package models
+
+import (
+	"errors"
+	"time"
+)
+
+type MigrationJobStatus string
+
+const (
+	MigrationPending   MigrationJobStatus = "pending"
+	MigrationRunning   MigrationJobStatus = "running"
+	MigrationPaused    MigrationJobStatus = "paused"
+	MigrationCompleted MigrationJobStatus = "completed"
+	MigrationCancelled MigrationJobStatus = "cancelled"
+)
+
+type MigrationItemStatus string
+
+const (
+	MigrationItemPending      MigrationItemStatus = "pending"
+	MigrationItemMigrated     MigrationItemStatus = "migrated"
+	MigrationItemWouldMigrate MigrationItemStatus = "would_migrate"
+	MigrationItemSkipped      MigrationItemStatus = "skipped"
+	MigrationItemFailed       MigrationItemStatus = "failed"
+)
+
+// MigrationJob moves every subscription on one plan to another right away,
+// a chunk at a time. The subscriptions are listed as items when the job is
+// created. A dry run goes through every item and rolls each one back; items
+// that a real run would move are reported as would_migrate.
+type MigrationJob struct {
+	ID         uint                  `gorm:"primaryKey" json:"id"`
+	FromPlanID uint                  `gorm:"not null" json:"from_plan_id"`
+	ToPlanID   uint                  `gorm:"not null" json:"to_plan_id"`
+	DryRun     bool                  `gorm:"not null" json:"dry_run"`
+	ChunkSize  int                   `gorm:"not null" json:"chunk_size"`
+	Status     MigrationJobStatus    `gorm:"size:20;not null" json:"status"`
+	StartedAt  *time.Time            `json:"started_at,omitempty"`
+	FinishedAt *time.Time            `json:"finished_at,omitempty"`
+	CreatedAt  time.Time             `json:"created_at"`
+	UpdatedAt  time.Time             `json:"updated_at"`
+	Progress   *MigrationJobProgress `gorm:"-" json:"progress,omitempty"`
+}
+
+// MigrationJobItem is the outcome of one subscription in a migration job.
+type MigrationJobItem struct {
+	JobID          uint                `gorm:"primaryKey" json:"job_id"`
+	SubscriptionID uint                `gorm:"primaryKey" json:"subscription_id"`
+	UserID         uint                `gorm:"not null" json:"user_id"`
+	Status         MigrationItemStatus `gorm:"size:20;not null" json:"status"`
+	Error          string              `json:"error,omitempty"`
+	ProcessedAt    *time.Time          `json:"processed_at,omitempty"`
+}
+
+// MigrationJobProgress counts the items of a job by status.
+type MigrationJobProgress struct {
+	Total        int `json:"total"`
+	Pending      int `json:"pending"`
+	Migrated     int `json:"migrated"`
+	WouldMigrate int `json:"would_migrate"`
+	Skipped      int `json:"skipped"`
+	Failed       int `json:"failed"`
+}
+
+func (j MigrationJob) Validate(from, to Plan) error {
+	if from.ID == to.ID {
+		return errors.New("from and to plans must differ")
+	}
+	if to.ArchivedAt != nil {
+		return errors.New("the target plan is archived")
+	}
+	if j.ChunkSize < 1 || j.ChunkSize > 1000 {
+		return errors.New("chunk_size must be between 1 and 1000")
+	}
+	return nil
+}
+
+// Runnable reports whether a worker should keep processing the job.
+func (j MigrationJob) Runnable() bool {
+	return j.Status == MigrationPending || j.Status == MigrationRunning
+}
+
+// Finished reports whether the job reached a final status.
+func (j MigrationJob) Finished() bool {
+	return j.Status == MigrationCompleted || j.Status == MigrationCancelled
+}
+
+// Transition returns the terms of the forced move: immediate, keeping the
+// current billing period when both plans bill on the same interval.
+func (j MigrationJob) Transition(from, to Plan) PlanTransition {
+	t := DefaultTransition(from, to)
+	t.Timing, t.Prorate = ChangeImmediately, true
+	return t
+}
+
+// Migrate moves sub to the target plan of the job. A trial keeps running on
+// the target plan until its end and a paused subscription keeps the period it
+// was paused in, so Resume still gives back the time it had left. Other
+// subscriptions change plan right away on the terms of Transition.
+func (j MigrationJob) Migrate(sub *Subscription, from, to Plan, now time.Time) error {
+	if sub.Status == Trialing || sub.Paused() {
+		sub.PlanID = to.ID
+		sub.Price = to.Price
+		sub.ClearPendingChange()
+		return nil
+	}
+	return sub.ChangePlan(from, to, j.Transition(from, to), now, ActorAdmin)
+}
@@ -0,0 +1,78 @@
+package models
+
+import (
+	"testing"
+	"time"
+)
+
+func TestMigrationJobMigrate(t *testing.T) {
+	start := time.Date(2025, time.January, 1, 0, 0, 0, 0, time.UTC)
+	end := start.AddDate(0, 1, 0)
+	now := start.AddDate(0, 0, 10)
+	monthly := Plan{ID: 1, Price: 10, IntervalUnit: IntervalMonth, IntervalCount: 1}
+	sameInterval := Plan{ID: 2, Price: 12, IntervalUnit: IntervalMonth, IntervalCount: 1}
+	yearly := Plan{ID: 3, Price: 100, IntervalUnit: IntervalYear, IntervalCount: 1}
+
+	active := Subscription{PlanID: 1, Status: Active, Price: 10, StartDate: start, EndDate: end, BillingAnchor: start}
+	trialing := active
+	trialing.Status, trialing.TrialEnd = Trialing, &end
+	pausedAt := start.AddDate(0, 0, 5)
+	paused := active
+	paused.Status, paused.PausedAt = Inactive, &pausedAt
+
+	tests := []struct {
+		name       string
+		sub        Subscription
+		to         Plan
+		wantStatus SubscriptionStatus
+		wantStart  time.Time
+		wantEnd    time.Time
+		wantTrial  bool
+	}{
+		{"same interval keeps the period", active, sameInterval, Active, start, end, false},
+		{"other interval starts a new period", active, yearly, Active, now, now.AddDate(1, 0, 0), false},
+		{"trial keeps running", trialing, yearly, Trialing, start, end, true},
+		{"paused keeps its period", paused, yearly, Inactive, start, end, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sub := tt.sub
+			if err := (MigrationJob{}).Migrate(&sub, monthly, tt.to, now); err != nil {
+				t.Fatalf("Migrate() error = %v", err)
+			}
+			if sub.PlanID != tt.to.ID || sub.Price != tt.to.Price {
+				t.Errorf("plan, price = %d, %v, want %d, %v", sub.PlanID, sub.Price, tt.to.ID, tt.to.Price)
+			}
+			if sub.Status != tt.wantStatus {
+				t.Errorf("status = %s, want %s", sub.Status, tt.wantStatus)
+			}
+			if !sub.StartDate.Equal(tt.wantStart) || !sub.EndDate.Equal(tt.wantEnd) {
+				t.Errorf("period = %v - %v, want %v - %v", sub.StartDate, sub.EndDate, tt.wantStart, tt.wantEnd)
+			}
+			if (sub.TrialEnd != nil) != tt.wantTrial {
+				t.Errorf("trial end = %v, want set %v", sub.TrialEnd, tt.wantTrial)
+			}
+		})
+	}
+}
+
+func TestMigrationJobMigratePausedResumes(t *testing.T) {
+	start := time.Date(2025, time.January, 1, 0, 0, 0, 0, time.UTC)
+	end := start.AddDate(0, 1, 0)
+	pausedAt := start.AddDate(0, 0, 5)
+	sub := Subscription{PlanID: 1, Status: Inactive, Price: 10, StartDate: start, EndDate: end, BillingAnchor: start, PausedAt: &pausedAt}
+	monthly := Plan{ID: 1, Price: 10, IntervalUnit: IntervalMonth, IntervalCount: 1}
+	yearly := Plan{ID: 3, Price: 100, IntervalUnit: IntervalYear, IntervalCount: 1}
+
+	if err := (MigrationJob{}).Migrate(&sub, monthly, yearly, start.AddDate(0, 0, 10)); err != nil {
+		t.Fatalf("Migrate() error = %v", err)
+	}
+	resumedAt := start.AddDate(0, 0, 20)
+	if err := sub.Resume(resumedAt, ActorUser, "resumed"); err != nil {
+		t.Fatalf("Resume() error = %v", err)
+	}
+	// The 26 days left when paused are still there after resuming
+	if left := sub.EndDate.Sub(resumedAt); left != end.Sub(pausedAt) {
+		t.Errorf("time left after resume = %v, want %v", left, end.Sub(pausedAt))
+	}
+}
@@ -0,0 +1,71 @@
+package models
+
+import (
+	"fmt"
+	"time"
+)
+
+type NotificationKind string
+
+const (
+	NotificationPlanSunset  NotificationKind = "plan_sunset"
+	NotificationPriceChange NotificationKind = "price_change"
+	NotificationPaymentDue  NotificationKind = "payment_due"
+)
+
+// Notification is a message to a user, queued until SendAt and delivered by
+// a background job. SentAt is set once delivered.
+type Notification struct {
+	ID        uint             `gorm:"primaryKey" json:"id"`
+	UserID    uint             `gorm:"not null" json:"user_id"`
+	Kind      NotificationKind `gorm:"size:50;not null" json:"kind"`
+	Subject   string           `gorm:"size:200;not null" json:"subject"`
+	Body      string           `gorm:"not null" json:"body"`
+	SendAt    time.Time        `gorm:"not null" json:"send_at"`
+	SentAt    *time.Time       `json:"sent_at,omitempty"`
+	CreatedAt time.Time        `json:"created_at"`
+}
+
+// SunsetNotice tells a subscriber their plan is retired and when they move
+// to the replacement plan.
+func SunsetNotice(sub Subscription, from, to Plan, at time.Time, now time.Time) Notification {
+	return Notification{
+		UserID:  sub.UserID,
+		Kind:    NotificationPlanSunset,
+		Subject: fmt.Sprintf("%s is being retired", from.Name),
+		Body: fmt.Sprintf("%s is no longer offered. Your subscription moves to %s at %.2f %s on %s, when your current period ends.",
+			from.Name, to.Name, to.Price, to.Currency, at.Format("January 2, 2006")),
+		SendAt: now,
+	}
+}
+
+// PriceChangeNotice tells a subscriber the price they pay for their plan
+// changes from their renewal at the given time.
+func PriceChangeNotice(sub Subscription, plan Plan, newPrice float64, at time.Time, now time.Time) Notification {
+	return Notification{
+		UserID:  sub.UserID,
+		Kind:    NotificationPriceChange,
+		Subject: fmt.Sprintf("The price of %s is changing", plan.Name),
+		Body: fmt.Sprintf("From your renewal on %s, %s costs %.2f %s instead of %.2f %s.",
+			at.Format("January 2, 2006"), plan.Name, newPrice, plan.Currency, sub.Price, plan.Currency),
+		SendAt: now,
+	}
+}
+
+// PaymentDueNotice reminds a past due subscriber that their renewal payment
+// failed, when it is retried and when their access ends.
+func PaymentDueNotice(sub Subscription, plan Plan, amount float64, now time.Time) Notification {
+	body := fmt.Sprintf("We could not collect %.2f %s for your %s renewal.", amount, plan.Currency, plan.Name)
+	if sub.NextRetryAt != nil {
+		body += fmt.Sprintf(" We will try again on %s.", sub.NextRetryAt.Format("January 2, 2006"))
+	}
+	body += fmt.Sprintf(" Please update your payment details before %s to keep your subscription.",
+		sub.GraceEndsAt.Format("January 2, 2006"))
+	return Notification{
+		UserID:  sub.UserID,
+		Kind:    NotificationPaymentDue,
+		Subject: fmt.Sprintf("Payment for %s failed", plan.Name),
+		Body:    body,
+		SendAt:  now,
+	}
+}
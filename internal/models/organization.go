@@ -0,0 +1,12 @@
+package models
+
+import "time"
+
+// Organization groups the users of a customer account, so a private plan
+// can be offered to all of them.
+type Organization struct {
+	ID        uint      `gorm:"primaryKey" json:"id"`
+	Name      string    `gorm:"size:100;not null" json:"name"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
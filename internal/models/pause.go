@@ -0,0 +1,59 @@
+package models
+
+import (
+	"errors"
+	"time"
+)
+
+// Paused reports whether the subscription is on hold. A paused subscription
+// grants no entitlements and its remaining time is frozen until it resumes.
+func (s Subscription) Paused() bool {
+	return s.Status == Inactive && s.PausedAt != nil
+}
+
+// ValidatePause checks the resume date of a pause, which may be at most
+// maxDays away when a limit is set.
+func (s Subscription) ValidatePause(now time.Time, resumeAt *time.Time, maxDays int) error {
+	if resumeAt != nil && !resumeAt.After(now) {
+		return errors.New("resume_at must be in the future")
+	}
+	if maxDays > 0 && resumeAt != nil && resumeAt.After(now.AddDate(0, 0, maxDays)) {
+		return errors.New("resume_at is beyond the maximum pause length")
+	}
+	return nil
+}
+
+// Pause puts the subscription on hold. Without a resume date it stays
+// paused until resumed by the user, or for maxDays when a limit is set.
+func (s *Subscription) Pause(now time.Time, resumeAt *time.Time, maxDays int) error {
+	if err := s.Transition(Inactive, ActorUser, "paused by user", now); err != nil {
+		return err
+	}
+	if resumeAt == nil && maxDays > 0 {
+		at := now.AddDate(0, 0, maxDays)
+		resumeAt = &at
+	}
+	s.PausedAt = &now
+	s.ResumeAt = resumeAt
+	return nil
+}
+
+// Resume ends the pause. The current period, and any plan change scheduled
+// at its end, are pushed back by the time spent paused, so the remaining
+// time is the same as when the subscription was paused.
+func (s *Subscription) Resume(now time.Time, actor Actor, reason string) error {
+	if err := s.Transition(Active, actor, reason, now); err != nil {
+		return err
+	}
+	paused := now.Sub(*s.PausedAt)
+	s.StartDate = s.StartDate.Add(paused)
+	s.EndDate = s.EndDate.Add(paused)
+	s.BillingAnchor = s.BillingAnchor.Add(paused)
+	if s.PendingChangeAt != nil {
+		at := s.PendingChangeAt.Add(paused)
+		s.PendingChangeAt = &at
+	}
+	s.PausedAt = nil
+	s.ResumeAt = nil
+	return nil
+}
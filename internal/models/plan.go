@@ -1,18 +1,166 @@
 package models
 
 import (
+	"errors"
+	"fmt"
 	"time"
 
 	"gorm.io/datatypes"
 )
 
 type Plan struct {
-	ID            uint           `gorm:"primaryKey" json:"id"`
-	Name          string         `gorm:"size:100;not null" json:"name"`
-	Price         float64        `gorm:"not null" json:"price"`
-	Features      datatypes.JSON `gorm:"type:jsonb" json:"features" swaggertype:"object"`
-	Duration      int            `gorm:"column:duration_days" json:"duration_days"`
-	CreatedAt     time.Time      `json:"created_at"`
-	UpdatedAt     time.Time      `json:"updated_at"`
-	Subscriptions []Subscription `json:"-"`
+	ID                uint           `gorm:"primaryKey" json:"id"`
+	Code              string         `gorm:"size:100;not null" json:"code"`
+	Version           int            `gorm:"not null;default:1" json:"version"`
+	Name              string         `gorm:"size:100;not null" json:"name"`
+	Price             float64        `gorm:"not null" json:"price"`
+	Currency          string         `gorm:"size:3;not null;default:USD" json:"currency"`
+	PricingModel      PricingModel   `gorm:"size:20;not null;default:flat" json:"pricing_model"`
+	PriceTiers        PriceTiers     `gorm:"type:jsonb;not null" json:"price_tiers,omitempty"`
+	MinQuantity       int            `gorm:"not null;default:1" json:"min_quantity"`
+	MaxQuantity       *int           `json:"max_quantity,omitempty"`
+	Features          datatypes.JSON `gorm:"type:jsonb" json:"features" swaggertype:"object"`
+	Duration          int            `gorm:"column:duration_days" json:"duration_days"`
+	IntervalUnit      IntervalUnit   `gorm:"column:interval_unit;size:10" json:"interval_unit,omitempty"`
+	IntervalCount     int            `gorm:"column:interval_count;not null;default:1" json:"interval_count"`
+	Entitlements      EntitlementSet `gorm:"type:jsonb;not null" json:"entitlements" swaggertype:"object"`
+	TrialDays         int            `gorm:"not null;default:0" json:"trial_days"`
+	AvailableFrom     *time.Time     `json:"available_from,omitempty"`
+	AvailableUntil    *time.Time     `json:"available_until,omitempty"`
+	MaxSubscribers    *int           `json:"max_subscribers,omitempty"`
+	SubscriberCount   int            `gorm:"not null;default:0" json:"-"`
+	RemainingCapacity *int           `gorm:"-" json:"remaining_capacity,omitempty"`
+	BasePlanID        *uint          `json:"base_plan_id,omitempty"`
+	OwnerUserID       *uint          `json:"owner_user_id,omitempty"`
+	OrganizationID    *uint          `json:"organization_id,omitempty"`
+	ArchivedAt        *time.Time     `json:"archived_at,omitempty"`
+	CreatedAt         time.Time      `json:"created_at"`
+	UpdatedAt         time.Time      `json:"updated_at"`
+	Subscriptions     []Subscription `json:"-"`
+}
+
+// Validate checks the plan terms and its entitlements against the schema.
+func (p Plan) Validate(defs []EntitlementDefinition) error {
+	if p.Code == "" {
+		return errors.New("code is required")
+	}
+	if err := ValidatePricing(p.PricingModel, p.Price, p.PriceTiers); err != nil {
+		return err
+	}
+	if p.MinQuantity < 1 {
+		return errors.New("min_quantity must be at least 1")
+	}
+	if p.MaxQuantity != nil && *p.MaxQuantity < p.MinQuantity {
+		return errors.New("max_quantity must not be less than min_quantity")
+	}
+	if p.TrialDays < 0 {
+		return errors.New("trial_days must not be negative")
+	}
+	if p.IntervalUnit == "" && p.Duration <= 0 {
+		return errors.New("either interval_unit or a positive duration_days is required")
+	}
+	if p.IntervalUnit != "" && !p.IntervalUnit.Valid() {
+		return errors.New("interval_unit must be one of day, week, month, year")
+	}
+	if p.AvailableFrom != nil && p.AvailableUntil != nil && !p.AvailableUntil.After(*p.AvailableFrom) {
+		return errors.New("available_until must be after available_from")
+	}
+	if p.MaxSubscribers != nil && *p.MaxSubscribers < 1 {
+		return errors.New("max_subscribers must be at least 1")
+	}
+	if p.OwnerUserID != nil && p.OrganizationID != nil {
+		return errors.New("a private plan belongs to either a user or an organization")
+	}
+	return ValidateEntitlements(defs, p.Entitlements)
+}
+
+// Purchasable reports whether new subscriptions may be sold on the plan at
+// the given time. Archived plan versions and plans outside their
+// availability window keep serving their existing subscribers. Subscriber
+// caps are enforced separately when a place is claimed.
+func (p Plan) Purchasable(now time.Time) bool {
+	return p.ArchivedAt == nil && p.Available(now)
+}
+
+// Available reports whether now falls inside the plan's availability window.
+func (p Plan) Available(now time.Time) bool {
+	if p.AvailableFrom != nil && now.Before(*p.AvailableFrom) {
+		return false
+	}
+	return p.AvailableUntil == nil || now.Before(*p.AvailableUntil)
+}
+
+// SetSubscriberCount records how many places of a capped plan are taken and
+// derives the remaining capacity shown to clients.
+func (p *Plan) SetSubscriberCount(count int) {
+	p.SubscriberCount = count
+	if p.MaxSubscribers == nil {
+		p.RemainingCapacity = nil
+		return
+	}
+	remaining := max(*p.MaxSubscribers-count, 0)
+	p.RemainingCapacity = &remaining
+}
+
+// Interval returns the billing interval of the plan. Plans without a calendar
+// interval bill every duration_days days.
+func (p Plan) Interval() (IntervalUnit, int) {
+	if p.IntervalUnit == "" {
+		return IntervalDay, p.Duration
+	}
+	if p.IntervalCount < 1 {
+		return p.IntervalUnit, 1
+	}
+	return p.IntervalUnit, p.IntervalCount
+}
+
+// PeriodEnd returns the end of the n-th billing period counted from anchor.
+func (p Plan) PeriodEnd(anchor time.Time, n int) time.Time {
+	unit, count := p.Interval()
+	return AddInterval(anchor, unit, count, n)
+}
+
+// NextPeriodEnd returns the first period boundary of the plan strictly after
+// the given time, keeping the boundaries aligned to the billing anchor.
+func (p Plan) NextPeriodEnd(anchor, after time.Time) time.Time {
+	if !p.PeriodEnd(anchor, 1).After(anchor) {
+		return after
+	}
+	for n := 1; ; n++ {
+		if end := p.PeriodEnd(anchor, n); end.After(after) {
+			return end
+		}
+	}
+}
+
+// CheckQuantity reports whether a subscription may hold quantity seats of
+// the plan.
+func (p Plan) CheckQuantity(quantity int) error {
+	if quantity < max(p.MinQuantity, 1) {
+		return fmt.Errorf("%s needs at least %d seats", p.Name, max(p.MinQuantity, 1))
+	}
+	if p.MaxQuantity != nil && quantity > *p.MaxQuantity {
+		return fmt.Errorf("%s allows at most %d seats", p.Name, *p.MaxQuantity)
+	}
+	return nil
+}
+
+// Quote prices quantity units of the plan.
+func (p Plan) Quote(quantity int) (PriceQuote, error) {
+	amount, lines, err := Quote(p.PricingModel, p.Price, p.PriceTiers, quantity)
+	if err != nil {
+		return PriceQuote{}, err
+	}
+	model := p.PricingModel
+	if model == "" {
+		model = PricingFlat
+	}
+	return PriceQuote{
+		PlanID:       p.ID,
+		PricingModel: model,
+		Quantity:     quantity,
+		Currency:     p.Currency,
+		Amount:       amount,
+		Lines:        lines,
+	}, nil
 }
@@ -0,0 +1,102 @@
+package models
+
+import (
+	"cmp"
+	"encoding/json"
+	"fmt"
+	"slices"
+	"strings"
+)
+
+// PlanFilter narrows the plan catalog. Zero values leave a criterion unset.
+type PlanFilter struct {
+	MinPrice *float64
+	MaxPrice *float64
+	Currency string
+	Interval IntervalUnit
+	Feature  string
+}
+
+// Matches reports whether the plan satisfies every criterion of the filter.
+func (f PlanFilter) Matches(p Plan) bool {
+	if f.MinPrice != nil && p.Price < *f.MinPrice {
+		return false
+	}
+	if f.MaxPrice != nil && p.Price > *f.MaxPrice {
+		return false
+	}
+	if f.Currency != "" && !strings.EqualFold(p.Currency, f.Currency) {
+		return false
+	}
+	if f.Interval != "" {
+		if unit, _ := p.Interval(); unit != f.Interval {
+			return false
+		}
+	}
+	return f.Feature == "" || p.HasFeature(f.Feature)
+}
+
+// HasFeature reports whether the plan grants the entitlement key or lists the
+// feature by name. Limits of zero and unset flags do not count as granted.
+func (p Plan) HasFeature(feature string) bool {
+	if value, ok := p.Entitlements[feature]; ok {
+		switch v := value.(type) {
+		case bool:
+			return v
+		case string:
+			return v != ""
+		default:
+			limit, isLimit := limitValue(v)
+			return isLimit && limit != 0
+		}
+	}
+
+	var names []string
+	if err := json.Unmarshal(p.Features, &names); err != nil {
+		return false
+	}
+	return slices.ContainsFunc(names, func(name string) bool {
+		return strings.EqualFold(name, feature)
+	})
+}
+
+var planSortKeys = map[string]func(a, b Plan) int{
+	"id":         func(a, b Plan) int { return cmp.Compare(a.ID, b.ID) },
+	"name":       func(a, b Plan) int { return strings.Compare(strings.ToLower(a.Name), strings.ToLower(b.Name)) },
+	"price":      func(a, b Plan) int { return cmp.Compare(a.Price, b.Price) },
+	"created_at": func(a, b Plan) int { return a.CreatedAt.Compare(b.CreatedAt) },
+}
+
+// SortPlans orders plans by a comma separated list of keys (id, name, price,
+// created_at), each optionally prefixed with "-" for descending order. Ties
+// fall back to the plan ID so the order is stable.
+func SortPlans(plans []Plan, sort string) error {
+	var compares []func(a, b Plan) int
+	for _, key := range strings.Split(sort, ",") {
+		key = strings.TrimSpace(key)
+		if key == "" {
+			continue
+		}
+		desc := strings.HasPrefix(key, "-")
+		compare, ok := planSortKeys[strings.TrimPrefix(key, "-")]
+		if !ok {
+			return fmt.Errorf("unknown sort key %q", strings.TrimPrefix(key, "-"))
+		}
+		if desc {
+			asc := compare
+			compare = func(a, b Plan) int { return -asc(a, b) }
+		}
+		compares = append(compares, compare)
+	}
+	compares = append(compares, planSortKeys["id"])
+
+	slices.SortStableFunc(plans, func(a, b Plan) int {
+		for _, compare := range compares {
+			if c := compare(a, b); c != 0 {
+				return c
+			}
+		}
+		return 0
+	})
+	return nil
+}
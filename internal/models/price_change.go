@@ -0,0 +1,73 @@
+package models
+
+import (
+	"errors"
+	"fmt"
+	"time"
+)
+
+type PriceChangeStatus string
+
+const (
+	PriceChangeScheduled PriceChangeStatus = "scheduled"
+	PriceChangeApplied   PriceChangeStatus = "applied"
+	PriceChangeSkipped   PriceChangeStatus = "skipped"
+	PriceChangeExempt    PriceChangeStatus = "exempt"
+)
+
+// PriceChange moves the existing subscribers of a plan to a new price. Each
+// subscriber is given notice when the change is announced and pays the new
+// price from their first renewal after the notice period. The plan price
+// paid by new subscribers is not affected.
+type PriceChange struct {
+	ID          uint                 `gorm:"primaryKey" json:"id"`
+	PlanID      uint                 `gorm:"not null" json:"plan_id"`
+	NewPrice    float64              `gorm:"not null" json:"new_price"`
+	NoticeDays  int                  `gorm:"not null" json:"notice_days"`
+	AnnouncedAt time.Time            `gorm:"not null" json:"announced_at"`
+	CompletedAt *time.Time           `json:"completed_at,omitempty"`
+	CreatedAt   time.Time            `json:"created_at"`
+	UpdatedAt   time.Time            `json:"updated_at"`
+	Progress    *PriceChangeProgress `gorm:"-" json:"progress,omitempty"`
+}
+
+// PriceChangeSubscriber is the schedule of a price change for one
+// subscription.
+type PriceChangeSubscriber struct {
+	PriceChangeID  uint              `gorm:"primaryKey" json:"price_change_id"`
+	SubscriptionID uint              `gorm:"primaryKey" json:"subscription_id"`
+	UserID         uint              `gorm:"not null" json:"user_id"`
+	OldPrice       float64           `gorm:"not null" json:"old_price"`
+	Status         PriceChangeStatus `gorm:"size:20;not null" json:"status"`
+	EffectiveAt    time.Time         `gorm:"not null" json:"effective_at"`
+	AppliedAt      *time.Time        `json:"applied_at,omitempty"`
+}
+
+// PriceChangeProgress counts the subscribers of a price change by status.
+type PriceChangeProgress struct {
+	Scheduled int `json:"scheduled"`
+	Applied   int `json:"applied"`
+	Skipped   int `json:"skipped"`
+	Exempt    int `json:"exempt"`
+}
+
+// Validate checks a price change on a plan. The new price replaces the
+// subscriber's price, which tiered plans do not charge from.
+func (c PriceChange) Validate(plan Plan) error {
+	if plan.PricingModel != PricingFlat && plan.PricingModel != PricingPerUnit {
+		return fmt.Errorf("plan %d uses %s pricing; only flat and per_unit prices can be changed", plan.ID, plan.PricingModel)
+	}
+	if c.NewPrice < 0 {
+		return errors.New("new_price must not be negative")
+	}
+	if c.NoticeDays < 0 {
+		return errors.New("notice_days must not be negative")
+	}
+	return nil
+}
+
+// EffectiveDate returns when a subscription starts paying the new price: its
+// first renewal after the notice period.
+func (c PriceChange) EffectiveDate(sub Subscription, plan Plan) time.Time {
+	return sub.RenewalAfter(plan, c.AnnouncedAt.AddDate(0, 0, c.NoticeDays))
+}
@@ -0,0 +1,27 @@
+package models
+
+import "testing"
+
+func TestPriceChangeValidate(t *testing.T) {
+	tests := []struct {
+		name    string
+		change  PriceChange
+		model   PricingModel
+		wantErr bool
+	}{
+		{"flat plan", PriceChange{NewPrice: 12, NoticeDays: 30}, PricingFlat, false},
+		{"per unit plan", PriceChange{NewPrice: 8}, PricingPerUnit, false},
+		{"graduated plan", PriceChange{NewPrice: 12, NoticeDays: 30}, PricingGraduated, true},
+		{"volume plan", PriceChange{NewPrice: 12, NoticeDays: 30}, PricingVolume, true},
+		{"negative price", PriceChange{NewPrice: -1}, PricingFlat, true},
+		{"negative notice", PriceChange{NewPrice: 12, NoticeDays: -1}, PricingFlat, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.change.Validate(Plan{ID: 2, PricingModel: tt.model})
+			if (err != nil) != tt.wantErr {
+				t.Errorf("Validate() error = %v, want error %v", err, tt.wantErr)
+			}
+		})
+	}
+}
@@ -0,0 +1,151 @@
+package models
+
+import (
+	"errors"
+	"fmt"
+	"math"
+
+	"gorm.io/datatypes"
+)
+
+type PricingModel string
+
+const (
+	PricingFlat      PricingModel = "flat"
+	PricingPerUnit   PricingModel = "per_unit"
+	PricingGraduated PricingModel = "graduated"
+	PricingVolume    PricingModel = "volume"
+)
+
+// PriceTier is one band of a tiered price. UpTo is the last unit covered by
+// the band; the last tier leaves it empty to cover every remaining unit.
+type PriceTier struct {
+	UpTo      *int    `json:"up_to,omitempty"`
+	UnitPrice float64 `json:"unit_price"`
+	FlatFee   float64 `json:"flat_fee,omitempty"`
+}
+
+type PriceTiers = datatypes.JSONSlice[PriceTier]
+
+type QuoteLine struct {
+	Quantity  int     `json:"quantity"`
+	UnitPrice float64 `json:"unit_price"`
+	FlatFee   float64 `json:"flat_fee,omitempty"`
+	Amount    float64 `json:"amount"`
+}
+
+type PriceQuote struct {
+	PlanID       uint         `json:"plan_id"`
+	PricingModel PricingModel `json:"pricing_model"`
+	Quantity     int          `json:"quantity"`
+	Currency     string       `json:"currency"`
+	Amount       float64      `json:"amount"`
+	Lines        []QuoteLine  `json:"lines"`
+}
+
+// ValidatePricing checks that the tiers fit the pricing model: flat and
+// per-unit prices take no tiers, tiered prices need ascending bands that end
+// with an open-ended one.
+func ValidatePricing(model PricingModel, price float64, tiers []PriceTier) error {
+	if price < 0 {
+		return errors.New("price must not be negative")
+	}
+	switch model {
+	case "", PricingFlat, PricingPerUnit:
+		if len(tiers) > 0 {
+			return fmt.Errorf("pricing model %q takes no price_tiers", model)
+		}
+		return nil
+	case PricingGraduated, PricingVolume:
+	default:
+		return fmt.Errorf("unknown pricing model %q", model)
+	}
+
+	if len(tiers) == 0 {
+		return fmt.Errorf("pricing model %q needs price_tiers", model)
+	}
+	last := 0
+	for i, tier := range tiers {
+		if tier.UnitPrice < 0 || tier.FlatFee < 0 {
+			return fmt.Errorf("tier %d: prices must not be negative", i+1)
+		}
+		if tier.UpTo == nil {
+			if i != len(tiers)-1 {
+				return fmt.Errorf("tier %d: only the last tier may leave up_to empty", i+1)
+			}
+			continue
+		}
+		if *tier.UpTo <= last {
+			return fmt.Errorf("tier %d: up_to must be greater than %d", i+1, last)
+		}
+		last = *tier.UpTo
+	}
+	if tiers[len(tiers)-1].UpTo != nil {
+		return errors.New("the last tier must leave up_to empty")
+	}
+	return nil
+}
+
+// Quote computes the charge for quantity units:
+//   - flat charges price regardless of quantity
+//   - per_unit charges price for every unit
+//   - graduated charges each unit at the price of the tier it falls in
+//   - volume charges every unit at the price of the tier the total falls in
+//
+// A tier's flat fee is added once whenever the tier is used.
+func Quote(model PricingModel, price float64, tiers []PriceTier, quantity int) (float64, []QuoteLine, error) {
+	if quantity < 1 {
+		return 0, nil, errors.New("quantity must be at least 1")
+	}
+
+	var lines []QuoteLine
+	switch model {
+	case "", PricingFlat:
+		lines = []QuoteLine{{Quantity: quantity, FlatFee: price, Amount: price}}
+	case PricingPerUnit:
+		lines = []QuoteLine{{Quantity: quantity, UnitPrice: price, Amount: price * float64(quantity)}}
+	case PricingGraduated:
+		covered := 0
+		for _, tier := range tiers {
+			upTo := quantity
+			if tier.UpTo != nil && *tier.UpTo < quantity {
+				upTo = *tier.UpTo
+			}
+			units := upTo - covered
+			if units <= 0 {
+				break
+			}
+			lines = append(lines, tierLine(tier, units))
+			covered = upTo
+		}
+	case PricingVolume:
+		for _, tier := range tiers {
+			if tier.UpTo == nil || quantity <= *tier.UpTo {
+				lines = []QuoteLine{tierLine(tier, quantity)}
+				break
+			}
+		}
+	default:
+		return 0, nil, fmt.Errorf("unknown pricing model %q", model)
+	}
+
+	amount := 0.0
+	for i := range lines {
+		lines[i].Amount = roundCents(lines[i].Amount)
+		amount += lines[i].Amount
+	}
+	return roundCents(amount), lines, nil
+}
+
+func tierLine(tier PriceTier, units int) QuoteLine {
+	return QuoteLine{
+		Quantity:  units,
+		UnitPrice: tier.UnitPrice,
+		FlatFee:   tier.FlatFee,
+		Amount:    tier.UnitPrice*float64(units) + tier.FlatFee,
+	}
+}
+
+func roundCents(amount float64) float64 {
+	return math.Round(amount*100) / 100
+}
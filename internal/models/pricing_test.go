@@ -0,0 +1,159 @@
+package models
+
+import "testing"
+
+func intPtr(n int) *int { return &n }
+
+func TestQuote(t *testing.T) {
+	tiers := []PriceTier{
+		{UpTo: intPtr(10), UnitPrice: 5},
+		{UpTo: intPtr(50), UnitPrice: 4, FlatFee: 20},
+		{UnitPrice: 3},
+	}
+
+	tests := []struct {
+		name      string
+		model     PricingModel
+		price     float64
+		tiers     []PriceTier
+		quantity  int
+		wantTotal float64
+		wantLines []QuoteLine
+	}{
+		{
+			name: "flat ignores quantity", model: PricingFlat, price: 49.99, quantity: 12,
+			wantTotal: 49.99,
+			wantLines: []QuoteLine{{Quantity: 12, FlatFee: 49.99, Amount: 49.99}},
+		},
+		{
+			name: "empty model is flat", model: "", price: 10, quantity: 3,
+			wantTotal: 10,
+			wantLines: []QuoteLine{{Quantity: 3, FlatFee: 10, Amount: 10}},
+		},
+		{
+			name: "per unit", model: PricingPerUnit, price: 7.5, quantity: 4,
+			wantTotal: 30,
+			wantLines: []QuoteLine{{Quantity: 4, UnitPrice: 7.5, Amount: 30}},
+		},
+		{
+			name: "graduated within first tier", model: PricingGraduated, tiers: tiers, quantity: 3,
+			wantTotal: 15,
+			wantLines: []QuoteLine{{Quantity: 3, UnitPrice: 5, Amount: 15}},
+		},
+		{
+			name: "graduated at first boundary", model: PricingGraduated, tiers: tiers, quantity: 10,
+			wantTotal: 50,
+			wantLines: []QuoteLine{{Quantity: 10, UnitPrice: 5, Amount: 50}},
+		},
+		{
+			name: "graduated one past boundary adds flat fee", model: PricingGraduated, tiers: tiers, quantity: 11,
+			wantTotal: 74,
+			wantLines: []QuoteLine{
+				{Quantity: 10, UnitPrice: 5, Amount: 50},
+				{Quantity: 1, UnitPrice: 4, FlatFee: 20, Amount: 24},
+			},
+		},
+		{
+			name: "graduated into open tier", model: PricingGraduated, tiers: tiers, quantity: 60,
+			wantTotal: 260,
+			wantLines: []QuoteLine{
+				{Quantity: 10, UnitPrice: 5, Amount: 50},
+				{Quantity: 40, UnitPrice: 4, FlatFee: 20, Amount: 180},
+				{Quantity: 10, UnitPrice: 3, Amount: 30},
+			},
+		},
+		{
+			name: "volume at boundary", model: PricingVolume, tiers: tiers, quantity: 10,
+			wantTotal: 50,
+			wantLines: []QuoteLine{{Quantity: 10, UnitPrice: 5, Amount: 50}},
+		},
+		{
+			name: "volume one past boundary reprices every unit", model: PricingVolume, tiers: tiers, quantity: 11,
+			wantTotal: 64,
+			wantLines: []QuoteLine{{Quantity: 11, UnitPrice: 4, FlatFee: 20, Amount: 64}},
+		},
+		{
+			name: "volume in open tier", model: PricingVolume, tiers: tiers, quantity: 100,
+			wantTotal: 300,
+			wantLines: []QuoteLine{{Quantity: 100, UnitPrice: 3, Amount: 300}},
+		},
+		{
+			name: "amounts round to cents", model: PricingPerUnit, price: 0.333, quantity: 3,
+			wantTotal: 1,
+			wantLines: []QuoteLine{{Quantity: 3, UnitPrice: 0.333, Amount: 1}},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			total, lines, err := Quote(tt.model, tt.price, tt.tiers, tt.quantity)
+			if err != nil {
+				t.Fatalf("Quote() error = %v", err)
+			}
+			if total != tt.wantTotal {
+				t.Errorf("total = %v, want %v", total, tt.wantTotal)
+			}
+			if len(lines) != len(tt.wantLines) {
+				t.Fatalf("lines = %+v, want %+v", lines, tt.wantLines)
+			}
+			for i := range lines {
+				if lines[i] != tt.wantLines[i] {
+					t.Errorf("line %d = %+v, want %+v", i, lines[i], tt.wantLines[i])
+				}
+			}
+		})
+	}
+}
+
+func TestQuoteRejects(t *testing.T) {
+	tests := []struct {
+		name     string
+		model    PricingModel
+		quantity int
+	}{
+		{"zero quantity", PricingPerUnit, 0},
+		{"negative quantity", PricingFlat, -1},
+		{"unknown model", PricingModel("metered"), 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, _, err := Quote(tt.model, 1, nil, tt.quantity); err == nil {
+				t.Error("Quote() error = nil, want an error")
+			}
+		})
+	}
+}
+
+func TestValidatePricing(t *testing.T) {
+	tests := []struct {
+		name    string
+		model   PricingModel
+		price   float64
+		tiers   []PriceTier
+		wantErr bool
+	}{
+		{name: "flat", model: PricingFlat, price: 10},
+		{name: "empty model", model: "", price: 10},
+		{name: "per unit", model: PricingPerUnit, price: 2},
+		{name: "negative price", model: PricingFlat, price: -1, wantErr: true},
+		{name: "flat with tiers", model: PricingFlat, tiers: []PriceTier{{UnitPrice: 1}}, wantErr: true},
+		{name: "per unit with tiers", model: PricingPerUnit, tiers: []PriceTier{{UnitPrice: 1}}, wantErr: true},
+		{name: "unknown model", model: PricingModel("metered"), wantErr: true},
+		{name: "graduated", model: PricingGraduated, tiers: []PriceTier{{UpTo: intPtr(10), UnitPrice: 5}, {UnitPrice: 4}}},
+		{name: "volume single open tier", model: PricingVolume, tiers: []PriceTier{{UnitPrice: 4}}},
+		{name: "tiered without tiers", model: PricingGraduated, wantErr: true},
+		{name: "last tier bounded", model: PricingVolume, tiers: []PriceTier{{UpTo: intPtr(10), UnitPrice: 5}}, wantErr: true},
+		{name: "open tier not last", model: PricingGraduated, tiers: []PriceTier{{UnitPrice: 5}, {UpTo: intPtr(10), UnitPrice: 4}}, wantErr: true},
+		{name: "bounds not ascending", model: PricingGraduated, tiers: []PriceTier{{UpTo: intPtr(10), UnitPrice: 5}, {UpTo: intPtr(10), UnitPrice: 4}, {UnitPrice: 3}}, wantErr: true},
+		{name: "negative unit price", model: PricingVolume, tiers: []PriceTier{{UnitPrice: -1}}, wantErr: true},
+		{name: "negative flat fee", model: PricingVolume, tiers: []PriceTier{{UnitPrice: 1, FlatFee: -5}}, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := ValidatePricing(tt.model, tt.price, tt.tiers)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("ValidatePricing() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
@@ -0,0 +1,95 @@
+package models
+
+import (
+	"errors"
+	"fmt"
+	"time"
+)
+
+// PrivatePlanTerms are the terms negotiated for a private plan. Unset fields
+// keep the terms of the base plan; entitlements are merged over the base
+// plan's.
+type PrivatePlanTerms struct {
+	Code           string
+	Name           string
+	Price          *float64
+	PriceTiers     PriceTiers
+	Entitlements   EntitlementSet
+	OwnerUserID    *uint
+	OrganizationID *uint
+	AvailableUntil *time.Time
+}
+
+// Private reports whether the plan is reserved for one user or organization.
+// Private plans never appear in the public catalog.
+func (p Plan) Private() bool {
+	return p.OwnerUserID != nil || p.OrganizationID != nil
+}
+
+// VisibleTo reports whether a user, member of the given organization if
+// any, may see and buy the plan.
+func (p Plan) VisibleTo(userId uint, organizationId *uint) bool {
+	if !p.Private() {
+		return true
+	}
+	if p.OwnerUserID != nil {
+		return *p.OwnerUserID == userId
+	}
+	return organizationId != nil && *p.OrganizationID == *organizationId
+}
+
+// Derive builds a private plan from a public base plan. The billing
+// interval, currency, pricing model, trial and features come from the base
+// plan; the private plan is uncapped and sold until AvailableUntil, if set.
+func (p Plan) Derive(terms PrivatePlanTerms) (Plan, error) {
+	if p.Private() {
+		return Plan{}, errors.New("the base plan must be public")
+	}
+	if p.ArchivedAt != nil {
+		return Plan{}, errors.New("the base plan is archived")
+	}
+	if (terms.OwnerUserID == nil) == (terms.OrganizationID == nil) {
+		return Plan{}, errors.New("exactly one of owner_user_id and organization_id is required")
+	}
+
+	derived := p
+	derived.ID = 0
+	derived.Version = 1
+	derived.BasePlanID = &p.ID
+	derived.OwnerUserID = terms.OwnerUserID
+	derived.OrganizationID = terms.OrganizationID
+	derived.AvailableFrom = nil
+	derived.AvailableUntil = terms.AvailableUntil
+	derived.MaxSubscribers = nil
+	derived.SubscriberCount = 0
+	derived.RemainingCapacity = nil
+	derived.ArchivedAt = nil
+	derived.CreatedAt, derived.UpdatedAt = time.Time{}, time.Time{}
+	derived.Subscriptions = nil
+
+	derived.Code = terms.Code
+	if derived.Code == "" {
+		if terms.OwnerUserID != nil {
+			derived.Code = fmt.Sprintf("%s_user_%d", p.Code, *terms.OwnerUserID)
+		} else {
+			derived.Code = fmt.Sprintf("%s_org_%d", p.Code, *terms.OrganizationID)
+		}
+	}
+	if terms.Name != "" {
+		derived.Name = terms.Name
+	}
+	if terms.Price != nil {
+		derived.Price = *terms.Price
+	}
+	if terms.PriceTiers != nil {
+		derived.PriceTiers = terms.PriceTiers
+	}
+	derived.Entitlements = make(EntitlementSet, len(p.Entitlements)+len(terms.Entitlements))
+	for key, value := range p.Entitlements {
+		derived.Entitlements[key] = value
+	}
+	for key, value := range terms.Entitlements {
+		derived.Entitlements[key] = value
+	}
+	return derived, nil
+}
@@ -0,0 +1,89 @@
+package models
+
+import (
+	"math"
+	"time"
+)
+
+type ProrationGranularity string
+
+const (
+	ProrateBySecond ProrationGranularity = "second"
+	ProrateByDay    ProrationGranularity = "day"
+)
+
+// ParseProrationGranularity reads the granularity used to measure unused
+// time, defaulting to seconds.
+func ParseProrationGranularity(s string) ProrationGranularity {
+	if ProrationGranularity(s) == ProrateByDay {
+		return ProrateByDay
+	}
+	return ProrateBySecond
+}
+
+// RemainingFraction is the part of the current period still to run at now.
+// By day, only whole days left count, so the day of the change is used up.
+// The remaining time of a paused subscription is the time left when paused.
+func (s Subscription) RemainingFraction(now time.Time, g ProrationGranularity) float64 {
+	if s.Paused() {
+		now = *s.PausedAt
+	}
+	if !s.EndDate.After(now) {
+		return 0
+	}
+	period := s.EndDate.Sub(s.StartDate)
+	remaining := s.EndDate.Sub(now)
+	if period <= 0 {
+		return 0
+	}
+	if g == ProrateByDay {
+		days := math.Round(period.Hours() / 24)
+		if days < 1 {
+			days = 1
+		}
+		return math.Min(math.Floor(remaining.Hours()/24)/days, 1)
+	}
+	return math.Min(float64(remaining)/float64(period), 1)
+}
+
+// Proration credits the unused time on the old plan and charges for the time
+// on the new plan when a subscription changes plan with proration. before is
+// the subscription as it was and after as it is once changed, so a change
+// that starts a new period is charged for the whole period.
+func Proration(before Subscription, from Plan, after Subscription, to Plan, now time.Time, g ProrationGranularity) []BillingLineItem {
+	var lines []BillingLineItem
+	if credit := roundCents(before.PeriodAmount(from) * before.RemainingFraction(now, g)); before.Status != Trialing && credit > 0 {
+		lines = append(lines, BillingLineItem{
+			UserID:         before.UserID,
+			SubscriptionID: before.ID,
+			Kind:           LineItemProrationCredit,
+			Description:    "Unused time on " + from.Name,
+			Amount:         -credit,
+			Currency:       from.Currency,
+			PeriodStart:    now,
+			PeriodEnd:      before.EndDate,
+		})
+	}
+	if charge := roundCents(after.PeriodAmount(to) * after.RemainingFraction(now, g)); charge > 0 {
+		lines = append(lines, BillingLineItem{
+			UserID:         after.UserID,
+			SubscriptionID: after.ID,
+			Kind:           LineItemProrationCharge,
+			Description:    "Remaining time on " + to.Name,
+			Amount:         charge,
+			Currency:       to.Currency,
+			PeriodStart:    now,
+			PeriodEnd:      after.EndDate,
+		})
+	}
+	return lines
+}
+
+// AmountDue totals the line items; a negative total is owed to the user.
+func AmountDue(lines []BillingLineItem) float64 {
+	total := 0.0
+	for _, line := range lines {
+		total += line.Amount
+	}
+	return roundCents(total)
+}
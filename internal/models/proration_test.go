@@ -0,0 +1,155 @@
+package models
+
+import (
+	"math"
+	"testing"
+	"time"
+)
+
+func TestRemainingFraction(t *testing.T) {
+	start := time.Date(2025, time.January, 1, 0, 0, 0, 0, time.UTC)
+	sub := Subscription{Status: Active, StartDate: start, EndDate: start.AddDate(0, 0, 30)}
+	pausedAt := start.AddDate(0, 0, 10)
+	paused := sub
+	paused.Status, paused.PausedAt = Inactive, &pausedAt
+
+	tests := []struct {
+		name string
+		sub  Subscription
+		now  time.Time
+		g    ProrationGranularity
+		want float64
+	}{
+		{"half way by second", sub, start.AddDate(0, 0, 15), ProrateBySecond, 0.5},
+		{"start of period", sub, start, ProrateBySecond, 1},
+		{"before the period is capped", sub, start.AddDate(0, 0, -5), ProrateBySecond, 1},
+		{"period over", sub, start.AddDate(0, 0, 31), ProrateBySecond, 0},
+		{"by day counts whole days left", sub, start.AddDate(0, 0, 15).Add(6 * time.Hour), ProrateByDay, 14.0 / 30},
+		{"paused uses the time left when paused", paused, start.AddDate(0, 0, 25), ProrateBySecond, 20.0 / 30},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.sub.RemainingFraction(tt.now, tt.g); math.Abs(got-tt.want) > 1e-9 {
+				t.Errorf("RemainingFraction() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestProration(t *testing.T) {
+	start := time.Date(2025, time.January, 1, 0, 0, 0, 0, time.UTC)
+	end := start.AddDate(0, 0, 30)
+	now := start.AddDate(0, 0, 15)
+	basic := Plan{Name: "Basic", Price: 10, Currency: "USD", PricingModel: PricingFlat}
+	pro := Plan{Name: "Pro", Price: 30, Currency: "USD", PricingModel: PricingFlat}
+	team := Plan{Name: "Team", Price: 8, Currency: "USD", PricingModel: PricingPerUnit}
+
+	onBasic := Subscription{ID: 7, UserID: 3, Status: Active, Price: 10, Quantity: 1, StartDate: start, EndDate: end}
+	onPro := onBasic
+	onPro.Price = 30
+	trialing := onBasic
+	trialing.Status = Trialing
+	newPeriod := onPro
+	newPeriod.StartDate, newPeriod.EndDate = now, now.AddDate(0, 0, 30)
+	onTeam := onBasic
+	onTeam.Price, onTeam.Quantity = 8, 5
+
+	tests := []struct {
+		name      string
+		before    Subscription
+		from      Plan
+		after     Subscription
+		to        Plan
+		wantLines []float64
+		wantDue   float64
+	}{
+		{"upgrade keeps the period", onBasic, basic, onPro, pro, []float64{-5, 15}, 10},
+		{"downgrade is owed back", onPro, pro, onBasic, basic, []float64{-15, 5}, -10},
+		{"trial gets no credit", trialing, basic, onPro, pro, []float64{15}, 15},
+		{"new period is charged in full", onBasic, basic, newPeriod, pro, []float64{-5, 30}, 25},
+		{"charge covers every seat", onBasic, basic, onTeam, team, []float64{-5, 20}, 15},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			lines := Proration(tt.before, tt.from, tt.after, tt.to, now, ProrateBySecond)
+			if len(lines) != len(tt.wantLines) {
+				t.Fatalf("lines = %+v, want amounts %v", lines, tt.wantLines)
+			}
+			for i, line := range lines {
+				if line.Amount != tt.wantLines[i] {
+					t.Errorf("line %d amount = %v, want %v", i, line.Amount, tt.wantLines[i])
+				}
+				if line.SubscriptionID != 7 || line.UserID != 3 || !line.PeriodStart.Equal(now) {
+					t.Errorf("line %d = %+v, not tied to the subscription at now", i, line)
+				}
+			}
+			if len(lines) == 2 && (lines[0].Kind != LineItemProrationCredit || lines[1].Kind != LineItemProrationCharge) {
+				t.Errorf("kinds = %s, %s, want credit then charge", lines[0].Kind, lines[1].Kind)
+			}
+			if got := AmountDue(lines); got != tt.wantDue {
+				t.Errorf("AmountDue() = %v, want %v", got, tt.wantDue)
+			}
+		})
+	}
+}
+
+func TestChangePlanPeriod(t *testing.T) {
+	start := time.Date(2025, time.January, 1, 0, 0, 0, 0, time.UTC)
+	end := start.AddDate(0, 1, 0)
+	now := start.AddDate(0, 0, 10)
+	monthly := Plan{ID: 1, Price: 10, IntervalUnit: IntervalMonth, IntervalCount: 1}
+	otherMonthly := Plan{ID: 2, Price: 10, IntervalUnit: IntervalMonth, IntervalCount: 1}
+	yearly := Plan{ID: 3, Price: 120, IntervalUnit: IntervalYear, IntervalCount: 1}
+	sub := Subscription{PlanID: 1, Status: Active, Price: 10, StartDate: start, EndDate: end, BillingAnchor: start}
+
+	tests := []struct {
+		name      string
+		to        Plan
+		prorate   bool
+		now       time.Time
+		wantStart time.Time
+		wantEnd   time.Time
+	}{
+		{"prorated same interval keeps the period", otherMonthly, true, now, start, end},
+		{"prorated other interval starts a new period", yearly, true, now, now, now.AddDate(1, 0, 0)},
+		{"prorated after the period ended starts a new period", otherMonthly, true, end.AddDate(0, 0, 1), end.AddDate(0, 0, 1), end.AddDate(0, 1, 1)},
+		{"not prorated keeps the period", otherMonthly, false, now, start, end},
+		{"not prorated other interval keeps the period", yearly, false, now, start, end},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := sub
+			if err := got.ChangePlan(monthly, tt.to, PlanTransition{Prorate: tt.prorate}, tt.now, ActorUser); err != nil {
+				t.Fatalf("ChangePlan() error = %v", err)
+			}
+			if !got.StartDate.Equal(tt.wantStart) || !got.EndDate.Equal(tt.wantEnd) {
+				t.Errorf("period = %v - %v, want %v - %v", got.StartDate, got.EndDate, tt.wantStart, tt.wantEnd)
+			}
+		})
+	}
+
+	// Switching back and forth between same-price plans never moves the
+	// billing date
+	got := sub
+	for i, at := range []time.Time{now, now.AddDate(0, 0, 5), now.AddDate(0, 0, 10)} {
+		from, to := monthly, otherMonthly
+		if i%2 == 1 {
+			from, to = otherMonthly, monthly
+		}
+		if err := got.ChangePlan(from, to, DefaultTransition(from, to), at, ActorUser); err != nil {
+			t.Fatalf("ChangePlan() error = %v", err)
+		}
+	}
+	if !got.EndDate.Equal(end) || !got.BillingAnchor.Equal(start) {
+		t.Errorf("end, anchor = %v, %v after lateral switches, want %v, %v", got.EndDate, got.BillingAnchor, end, start)
+	}
+}
+
+func TestProrationAfterPeriodEnd(t *testing.T) {
+	start := time.Date(2025, time.January, 1, 0, 0, 0, 0, time.UTC)
+	sub := Subscription{Status: Active, Price: 10, StartDate: start, EndDate: start.AddDate(0, 0, 30)}
+	plan := Plan{Name: "Basic", Price: 10}
+	if lines := Proration(sub, plan, sub, plan, start.AddDate(0, 0, 40), ProrateBySecond); len(lines) != 0 {
+		t.Errorf("lines = %+v, want none once the period is over", lines)
+	}
+}
@@ -0,0 +1,127 @@
+package models
+
+import (
+	"fmt"
+	"time"
+)
+
+// Renewal is the charge for the next billing period of a subscription,
+// handed to the payment collector when the subscription renews. Charges
+// sent with the same IdempotencyKey are one charge.
+type Renewal struct {
+	SubscriptionID uint      `json:"subscription_id"`
+	UserID         uint      `json:"user_id"`
+	PlanID         uint      `json:"plan_id"`
+	Amount         float64   `json:"amount"`
+	Currency       string    `json:"currency"`
+	PeriodStart    time.Time `json:"period_start"`
+	PeriodEnd      time.Time `json:"period_end"`
+	IdempotencyKey string    `json:"idempotency_key"`
+}
+
+// Renew starts the next billing period at the end of the current one. A
+// pending plan change takes effect now, on plan, priced at plan.Price, which
+// the caller sets to the user's price; otherwise plan is the current plan,
+// the subscription keeps its price and the period stays aligned to the
+// billing anchor.
+func (s *Subscription) Renew(plan Plan) Renewal {
+	at := s.EndDate
+	if s.PendingPlanID != nil {
+		s.PendingChangeAt = &at
+		s.ApplyPendingChange(plan)
+	} else {
+		s.StartDate = at
+		s.EndDate = plan.NextPeriodEnd(s.BillingAnchor, at)
+	}
+	return Renewal{
+		SubscriptionID: s.ID,
+		UserID:         s.UserID,
+		PlanID:         s.PlanID,
+		Amount:         s.PeriodAmount(plan),
+		Currency:       plan.Currency,
+		PeriodStart:    s.StartDate,
+		PeriodEnd:      s.EndDate,
+	}
+}
+
+type InvoiceStatus string
+
+const (
+	InvoicePending  InvoiceStatus = "pending"
+	InvoicePaid     InvoiceStatus = "paid"
+	InvoiceFailed   InvoiceStatus = "failed"
+	InvoiceRefunded InvoiceStatus = "refunded"
+)
+
+// RenewalInvoice records a renewal charge before it is collected, one per
+// subscription and period. An invoice left pending, because the outcome of
+// its charge was lost, is collected again under the same idempotency key;
+// Attempts counts the failed charges, so every retry after a failure is a
+// new charge.
+type RenewalInvoice struct {
+	ID             uint          `gorm:"primaryKey" json:"id"`
+	SubscriptionID uint          `gorm:"not null" json:"subscription_id"`
+	UserID         uint          `gorm:"not null" json:"user_id"`
+	PlanID         uint          `gorm:"not null" json:"plan_id"`
+	Amount         float64       `gorm:"not null" json:"amount"`
+	Currency       string        `gorm:"size:3;not null" json:"currency"`
+	PeriodStart    time.Time     `gorm:"not null" json:"period_start"`
+	PeriodEnd      time.Time     `gorm:"not null" json:"period_end"`
+	Status         InvoiceStatus `gorm:"size:20;not null" json:"status"`
+	Attempts       int           `gorm:"not null" json:"attempts"`
+	Error          string        `json:"error,omitempty"`
+	PaidAt         *time.Time    `json:"paid_at,omitempty"`
+	CreatedAt      time.Time     `json:"created_at"`
+	UpdatedAt      time.Time     `json:"updated_at"`
+}
+
+// NewRenewalInvoice opens a pending invoice for a renewal.
+func NewRenewalInvoice(r Renewal) RenewalInvoice {
+	invoice := RenewalInvoice{SubscriptionID: r.SubscriptionID, UserID: r.UserID, Status: InvoicePending}
+	invoice.Reprice(r)
+	return invoice
+}
+
+// Reprice sets the plan, amount and period of an invoice to those of the
+// renewal, for a retry after a failed charge.
+func (i *RenewalInvoice) Reprice(r Renewal) {
+	i.PlanID = r.PlanID
+	i.Amount = r.Amount
+	i.Currency = r.Currency
+	i.PeriodStart = r.PeriodStart
+	i.PeriodEnd = r.PeriodEnd
+}
+
+// IdempotencyKey identifies the current attempt to collect the invoice.
+func (i RenewalInvoice) IdempotencyKey() string {
+	return fmt.Sprintf("renewal-%d-%d-%d", i.SubscriptionID, i.PeriodStart.Unix(), i.Attempts)
+}
+
+// Renewal is the charge to collect for the invoice.
+func (i RenewalInvoice) Renewal() Renewal {
+	return Renewal{
+		SubscriptionID: i.SubscriptionID,
+		UserID:         i.UserID,
+		PlanID:         i.PlanID,
+		Amount:         i.Amount,
+		Currency:       i.Currency,
+		PeriodStart:    i.PeriodStart,
+		PeriodEnd:      i.PeriodEnd,
+		IdempotencyKey: i.IdempotencyKey(),
+	}
+}
+
+// RenewalRefundLine pays back a collected renewal whose period did not
+// start because the subscription changed while it was being charged.
+func RenewalRefundLine(i RenewalInvoice) BillingLineItem {
+	return BillingLineItem{
+		UserID:         i.UserID,
+		SubscriptionID: i.SubscriptionID,
+		Kind:           LineItemRefund,
+		Description:    "Refund of a renewal charge for a period that did not start",
+		Amount:         -i.Amount,
+		Currency:       i.Currency,
+		PeriodStart:    i.PeriodStart,
+		PeriodEnd:      i.PeriodEnd,
+	}
+}
@@ -0,0 +1,115 @@
+package models
+
+import (
+	"testing"
+	"time"
+)
+
+func TestRenew(t *testing.T) {
+	monthly := Plan{ID: 2, Price: 10, Currency: "USD", PricingModel: PricingFlat, IntervalUnit: IntervalMonth, IntervalCount: 1}
+	yearly := Plan{ID: 5, Price: 100, Currency: "EUR", PricingModel: PricingFlat, IntervalUnit: IntervalYear, IntervalCount: 1}
+	anchor := date(2025, time.January, 31)
+	sub := Subscription{ID: 7, UserID: 3, PlanID: monthly.ID, Price: 9, StartDate: anchor,
+		EndDate: date(2025, time.February, 28), BillingAnchor: anchor}
+
+	renewed := sub
+	renewal := renewed.Renew(monthly)
+	want := Renewal{SubscriptionID: 7, UserID: 3, PlanID: monthly.ID, Amount: 9, Currency: "USD",
+		PeriodStart: date(2025, time.February, 28), PeriodEnd: date(2025, time.March, 31)}
+	if renewal != want {
+		t.Errorf("Renew() = %+v, want %+v", renewal, want)
+	}
+	if !renewed.StartDate.Equal(want.PeriodStart) || !renewed.EndDate.Equal(want.PeriodEnd) || !renewed.BillingAnchor.Equal(anchor) {
+		t.Errorf("renewed period %v - %v anchored on %v, want it aligned to %v", renewed.StartDate, renewed.EndDate, renewed.BillingAnchor, anchor)
+	}
+
+	changed := sub
+	changed.SchedulePlanChange(yearly.ID, sub.EndDate)
+	renewal = changed.Renew(yearly)
+	want = Renewal{SubscriptionID: 7, UserID: 3, PlanID: yearly.ID, Amount: 100, Currency: "EUR",
+		PeriodStart: date(2025, time.February, 28), PeriodEnd: date(2026, time.February, 28)}
+	if renewal != want {
+		t.Errorf("Renew() with a pending change = %+v, want %+v", renewal, want)
+	}
+	if changed.PlanID != yearly.ID || changed.PendingPlanID != nil || !changed.BillingAnchor.Equal(want.PeriodStart) {
+		t.Errorf("subscription after the change = %+v, want it on plan %d anchored at %v", changed, yearly.ID, want.PeriodStart)
+	}
+}
+
+func TestRenewalInvoiceIdempotencyKey(t *testing.T) {
+	start := time.Date(2026, time.March, 1, 0, 0, 0, 0, time.UTC)
+	renewal := Renewal{
+		SubscriptionID: 7,
+		UserID:         3,
+		PlanID:         2,
+		Amount:         10,
+		Currency:       "USD",
+		PeriodStart:    start,
+		PeriodEnd:      start.AddDate(0, 1, 0),
+	}
+	invoice := NewRenewalInvoice(renewal)
+	if invoice.Status != InvoicePending {
+		t.Fatalf("status = %s, want %s", invoice.Status, InvoicePending)
+	}
+
+	first := invoice.Renewal()
+	if first.IdempotencyKey == "" || first.IdempotencyKey != invoice.Renewal().IdempotencyKey {
+		t.Fatalf("retrying a pending charge must reuse its key, got %q", first.IdempotencyKey)
+	}
+	if first.Amount != renewal.Amount || !first.PeriodEnd.Equal(renewal.PeriodEnd) {
+		t.Errorf("renewal = %+v, want the invoiced charge", first)
+	}
+
+	invoice.Attempts++
+	renewal.Amount = 12
+	invoice.Reprice(renewal)
+	retry := invoice.Renewal()
+	if retry.IdempotencyKey == first.IdempotencyKey {
+		t.Errorf("retry after a failure reused key %q", retry.IdempotencyKey)
+	}
+	if retry.Amount != 12 {
+		t.Errorf("retry amount = %v, want 12", retry.Amount)
+	}
+
+	other := NewRenewalInvoice(Renewal{SubscriptionID: 7, PeriodStart: start.AddDate(0, 1, 0)})
+	if other.IdempotencyKey() == first.IdempotencyKey {
+		t.Errorf("next period reused key %q", first.IdempotencyKey)
+	}
+}
+
+func TestRenewalRefundLine(t *testing.T) {
+	start := time.Date(2026, time.March, 1, 0, 0, 0, 0, time.UTC)
+	invoice := NewRenewalInvoice(Renewal{SubscriptionID: 7, UserID: 3, Amount: 10, Currency: "USD",
+		PeriodStart: start, PeriodEnd: start.AddDate(0, 1, 0)})
+	line := RenewalRefundLine(invoice)
+	if line.Kind != LineItemRefund || line.Amount != -10 || line.SubscriptionID != 7 || line.UserID != 3 {
+		t.Errorf("refund line = %+v", line)
+	}
+}
+
+func TestRenewEndedTrial(t *testing.T) {
+	plan := Plan{ID: 2, Price: 10, Currency: "USD", IntervalUnit: IntervalMonth, IntervalCount: 1, TrialDays: 14}
+	sub := Subscription{ID: 7, UserID: 3, PlanID: plan.ID, Price: plan.Price, Quantity: 1}
+	started := date(2025, time.January, 17)
+	if err := sub.StartTrial(plan, started); err != nil {
+		t.Fatal(err)
+	}
+	trialEnd := date(2025, time.January, 31)
+
+	renewal := sub.Renew(plan)
+	if !renewal.PeriodStart.Equal(trialEnd) || !renewal.PeriodEnd.Equal(date(2025, time.February, 28)) {
+		t.Errorf("first paid period = %v - %v, want %v - %v",
+			renewal.PeriodStart, renewal.PeriodEnd, trialEnd, date(2025, time.February, 28))
+	}
+	if renewal.Amount != 10 {
+		t.Errorf("amount = %v, want 10", renewal.Amount)
+	}
+
+	failed := sub
+	if err := failed.MarkPastDue(trialEnd, DunningPolicy{GraceDays: 7, FinalStatus: Expired}); err != nil {
+		t.Errorf("a trial whose first payment fails must go past due: %v", err)
+	}
+	if err := sub.EndTrial(trialEnd); err != nil || sub.Status != Active {
+		t.Errorf("EndTrial() = %v, status %s, want %s", err, sub.Status, Active)
+	}
+}
@@ -0,0 +1,88 @@
+package models
+
+import (
+	"fmt"
+	"time"
+)
+
+// SeatAssignment gives one of the seats bought on a subscription to a user.
+type SeatAssignment struct {
+	ID             uint      `gorm:"primaryKey" json:"id"`
+	SubscriptionID uint      `gorm:"not null" json:"subscription_id"`
+	UserID         uint      `gorm:"not null" json:"user_id"`
+	CreatedAt      time.Time `json:"assigned_at"`
+}
+
+// SeatUsage reports how many of the seats bought on a subscription are
+// assigned.
+type SeatUsage struct {
+	SubscriptionID uint             `json:"subscription_id"`
+	Quantity       int              `json:"quantity"`
+	Assigned       int              `json:"assigned"`
+	Available      int              `json:"available"`
+	Assignments    []SeatAssignment `json:"assignments"`
+}
+
+// NewSeatUsage counts the assignments against the seats bought.
+func NewSeatUsage(sub Subscription, assignments []SeatAssignment) SeatUsage {
+	return SeatUsage{
+		SubscriptionID: sub.ID,
+		Quantity:       sub.Quantity,
+		Assigned:       len(assignments),
+		Available:      max(sub.Quantity-len(assignments), 0),
+		Assignments:    assignments,
+	}
+}
+
+// QuantityChange is the outcome of changing the seat count of a
+// subscription, with the prorated charge or credit for the current period.
+type QuantityChange struct {
+	Subscription
+	LineItems []BillingLineItem `json:"line_items,omitempty"`
+	AmountDue float64           `json:"amount_due"`
+}
+
+// PeriodAmount is what a billing period of the subscription costs: its
+// locked-in price applied to its seats with the plan's pricing model.
+func (s Subscription) PeriodAmount(plan Plan) float64 {
+	amount, _, err := Quote(plan.PricingModel, s.Price, plan.PriceTiers, max(s.Quantity, 1))
+	if err != nil {
+		return s.Price
+	}
+	return amount
+}
+
+// ChangeQuantity sets the number of seats within the plan's bounds.
+func (s *Subscription) ChangeQuantity(plan Plan, quantity int) error {
+	if err := plan.CheckQuantity(quantity); err != nil {
+		return err
+	}
+	s.Quantity = quantity
+	return nil
+}
+
+// SeatProration charges the added seats, or credits the removed ones, for
+// the rest of the current period. Seat changes during a trial are free.
+func SeatProration(before, after Subscription, plan Plan, now time.Time, g ProrationGranularity) []BillingLineItem {
+	if before.Status == Trialing {
+		return nil
+	}
+	amount := roundCents((after.PeriodAmount(plan) - before.PeriodAmount(plan)) * before.RemainingFraction(now, g))
+	if amount == 0 {
+		return nil
+	}
+	kind := LineItemProrationCharge
+	if amount < 0 {
+		kind = LineItemProrationCredit
+	}
+	return []BillingLineItem{{
+		UserID:         after.UserID,
+		SubscriptionID: after.ID,
+		Kind:           kind,
+		Description:    fmt.Sprintf("Seats on %s changed from %d to %d", plan.Name, before.Quantity, after.Quantity),
+		Amount:         amount,
+		Currency:       plan.Currency,
+		PeriodStart:    now,
+		PeriodEnd:      after.EndDate,
+	}}
+}
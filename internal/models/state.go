@@ -0,0 +1,87 @@
+package models
+
+import (
+	"fmt"
+	"time"
+)
+
+// subscriptionTransitions lists the statuses each status may move to.
+// CANCELLED and EXPIRED are final: a user who comes back gets a new
+// subscription.
+var subscriptionTransitions = map[SubscriptionStatus][]SubscriptionStatus{
+	Trialing: {Active, Cancelled, Expired, PastDue},
+	Active:   {Inactive, Cancelled, Expired, PastDue},
+	Inactive: {Active, Cancelled},
+	PastDue:  {Active, Cancelled, Expired},
+}
+
+// CanTransitionTo reports whether a subscription may move from s to the
+// given status.
+func (s SubscriptionStatus) CanTransitionTo(to SubscriptionStatus) bool {
+	for _, allowed := range subscriptionTransitions[s] {
+		if allowed == to {
+			return true
+		}
+	}
+	return false
+}
+
+type Actor string
+
+const (
+	ActorUser   Actor = "user"
+	ActorSystem Actor = "system"
+	ActorAdmin  Actor = "admin"
+)
+
+// StatusTransition records a change of status of a subscription: who made
+// it and why. A new subscription records its initial status with an empty
+// FromStatus.
+type StatusTransition struct {
+	ID             uint               `gorm:"primaryKey" json:"id"`
+	SubscriptionID uint               `gorm:"not null" json:"subscription_id"`
+	UserID         uint               `gorm:"not null" json:"user_id"`
+	FromStatus     SubscriptionStatus `gorm:"size:20" json:"from_status,omitempty"`
+	ToStatus       SubscriptionStatus `gorm:"size:20;not null" json:"to_status"`
+	Actor          Actor              `gorm:"size:20;not null" json:"actor"`
+	Reason         string             `gorm:"not null" json:"reason"`
+	CreatedAt      time.Time          `json:"created_at"`
+}
+
+func (StatusTransition) TableName() string {
+	return "subscription_transitions"
+}
+
+// Transition moves the subscription to the given status, failing when the
+// state machine does not allow it. Staying in the same status is not a
+// transition. Each transition is kept on the subscription until taken with
+// TakeTransitions to be recorded.
+func (s *Subscription) Transition(to SubscriptionStatus, actor Actor, reason string, now time.Time) error {
+	if s.Status == to {
+		return nil
+	}
+	if s.Status != "" && !s.Status.CanTransitionTo(to) {
+		return fmt.Errorf("cannot move subscription from %s to %s", s.Status, to)
+	}
+	s.transitions = append(s.transitions, StatusTransition{
+		UserID:     s.UserID,
+		FromStatus: s.Status,
+		ToStatus:   to,
+		Actor:      actor,
+		Reason:     reason,
+		CreatedAt:  now,
+	})
+	s.Status = to
+	return nil
+}
+
+// TakeTransitions returns the transitions made since the last call, ready
+// to be recorded once the subscription is saved.
+func (s *Subscription) TakeTransitions() []StatusTransition {
+	taken := s.transitions
+	s.transitions = nil
+	for i := range taken {
+		taken[i].SubscriptionID = s.ID
+	}
+	return taken
+}
@@ -0,0 +1,74 @@
+package models
+
+import (
+	"fmt"
+	"testing"
+	"time"
+)
+
+func TestCanTransitionTo(t *testing.T) {
+	statuses := []SubscriptionStatus{Trialing, Active, Inactive, Cancelled, Expired, PastDue}
+	allowed := map[[2]SubscriptionStatus]bool{
+		{Trialing, Active}:    true,
+		{Trialing, Cancelled}: true,
+		{Trialing, Expired}:   true,
+		{Trialing, PastDue}:   true,
+		{Active, Inactive}:    true,
+		{Active, Cancelled}:   true,
+		{Active, Expired}:     true,
+		{Active, PastDue}:     true,
+		{Inactive, Active}:    true,
+		{Inactive, Cancelled}: true,
+		{PastDue, Active}:     true,
+		{PastDue, Cancelled}:  true,
+		{PastDue, Expired}:    true,
+	}
+	for _, from := range statuses {
+		for _, to := range statuses {
+			t.Run(fmt.Sprintf("%s to %s", from, to), func(t *testing.T) {
+				want := allowed[[2]SubscriptionStatus{from, to}]
+				if got := from.CanTransitionTo(to); got != want {
+					t.Errorf("CanTransitionTo() = %v, want %v", got, want)
+				}
+			})
+		}
+	}
+}
+
+func TestTransition(t *testing.T) {
+	now := time.Date(2025, time.March, 1, 0, 0, 0, 0, time.UTC)
+
+	sub := Subscription{UserID: 3}
+	if err := sub.Transition(Active, ActorUser, "subscribed", now); err != nil {
+		t.Fatalf("a new subscription may start in any status: %v", err)
+	}
+	if err := sub.Transition(Active, ActorUser, "no change", now); err != nil {
+		t.Errorf("staying in the same status failed: %v", err)
+	}
+	if err := sub.Transition(Inactive, ActorUser, "paused", now); err != nil {
+		t.Fatalf("ACTIVE to INACTIVE failed: %v", err)
+	}
+	if err := sub.Transition(Trialing, ActorAdmin, "back to trial", now); err == nil {
+		t.Error("INACTIVE to TRIALING succeeded, want an error")
+	}
+	if sub.Status != Inactive {
+		t.Errorf("status = %s after a refused transition, want %s", sub.Status, Inactive)
+	}
+
+	got := sub.TakeTransitions()
+	want := []StatusTransition{
+		{UserID: 3, ToStatus: Active, Actor: ActorUser, Reason: "subscribed", CreatedAt: now},
+		{UserID: 3, FromStatus: Active, ToStatus: Inactive, Actor: ActorUser, Reason: "paused", CreatedAt: now},
+	}
+	if len(got) != len(want) {
+		t.Fatalf("transitions = %+v, want %+v", got, want)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("transition %d = %+v, want %+v", i, got[i], want[i])
+		}
+	}
+	if rest := sub.TakeTransitions(); len(rest) != 0 {
+		t.Errorf("transitions taken twice: %+v", rest)
+	}
+}
@@ -1,6 +1,9 @@
 package models
 
-import "time"
+import (
+	"errors"
+	"time"
+)
 
 type SubscriptionStatus string
 
@@ -9,17 +12,149 @@ const (
 	Inactive  SubscriptionStatus = "INACTIVE"
 	Cancelled SubscriptionStatus = "CANCELLED"
 	Expired   SubscriptionStatus = "EXPIRED"
+	Trialing  SubscriptionStatus = "TRIALING"
+	PastDue   SubscriptionStatus = "PAST_DUE"
 )
 
 type Subscription struct {
-	ID        uint               `gorm:"primaryKey" json:"id"`
-	UserID    uint               `gorm:"not null;unique" json:"user_id"`
-	PlanID    uint               `gorm:"not null" json:"plan_id"`
-	Status    SubscriptionStatus `gorm:"type:subscription_status;not null"`
-	StartDate time.Time          `gorm:"not null" json:"start_date"`
-	EndDate   time.Time          `gorm:"not null" json:"end_date"`
-	CreatedAt time.Time          `json:"created_at"`
-	UpdatedAt time.Time          `json:"updated_at"`
-	User      *User              `gorm:"foreignKey:UserID" json:"-"`
-	Plan      *Plan              `gorm:"foreignKey:PlanID" json:"-"`
+	ID                uint                `gorm:"primaryKey" json:"id"`
+	UserID            uint                `gorm:"not null" json:"user_id"`
+	PlanID            uint                `gorm:"not null" json:"plan_id"`
+	Price             float64             `gorm:"not null" json:"price"`
+	Quantity          int                 `gorm:"not null;default:1" json:"quantity"`
+	Status            SubscriptionStatus  `gorm:"type:subscription_status;not null"`
+	StartDate         time.Time           `gorm:"not null" json:"start_date"`
+	EndDate           time.Time           `gorm:"not null" json:"end_date"`
+	BillingAnchor     time.Time           `gorm:"not null" json:"billing_anchor"`
+	TrialEnd          *time.Time          `json:"trial_end,omitempty"`
+	PendingPlanID     *uint               `json:"pending_plan_id,omitempty"`
+	PendingChangeAt   *time.Time          `json:"pending_change_at,omitempty"`
+	IsCurrent         bool                `gorm:"not null;default:true" json:"is_current"`
+	AutoRenew         bool                `gorm:"not null;default:true" json:"auto_renew"`
+	CancelAtPeriodEnd bool                `gorm:"not null;default:false" json:"cancel_at_period_end"`
+	CancelledAt       *time.Time          `json:"cancelled_at,omitempty"`
+	PausedAt          *time.Time          `json:"paused_at,omitempty"`
+	ResumeAt          *time.Time          `json:"resume_at,omitempty"`
+	PastDueSince      *time.Time          `json:"past_due_since,omitempty"`
+	GraceEndsAt       *time.Time          `json:"grace_ends_at,omitempty"`
+	NextRetryAt       *time.Time          `json:"next_retry_at,omitempty"`
+	PaymentRetries    int                 `gorm:"not null;default:0" json:"payment_retries"`
+	CreatedAt         time.Time           `json:"created_at"`
+	UpdatedAt         time.Time           `json:"updated_at"`
+	AddOns            []SubscriptionAddOn `gorm:"foreignKey:SubscriptionID" json:"add_ons"`
+	User              *User               `gorm:"foreignKey:UserID" json:"-"`
+	Plan              *Plan               `gorm:"foreignKey:PlanID" json:"-"`
+
+	transitions []StatusTransition
+}
+
+// Entitled reports whether the subscription currently grants the
+// entitlements of its plan. A past due subscription keeps them for its grace
+// period.
+func (s Subscription) Entitled() bool {
+	return s.Status == Active || s.Status == Trialing || s.Status == PastDue
+}
+
+// Ended reports whether the subscription was cancelled or ran out. A user
+// with an ended subscription may subscribe again.
+func (s Subscription) Ended() bool {
+	return s.Status == Cancelled || s.Status == Expired
+}
+
+// Cancel ends the subscription now and moves it to the user's history. A
+// cancellation scheduled earlier keeps the time it was asked for.
+func (s *Subscription) Cancel(now time.Time, actor Actor, reason string) error {
+	if err := s.Transition(Cancelled, actor, reason, now); err != nil {
+		return err
+	}
+	s.IsCurrent = false
+	s.CancelAtPeriodEnd = false
+	if s.CancelledAt == nil {
+		s.CancelledAt = &now
+	}
+	if s.EndDate.After(now) {
+		s.EndDate = now
+	}
+	s.ClearPendingChange()
+	return nil
+}
+
+// StartTrial puts the subscription on the plan's free trial. The first paid
+// period starts when the trial ends, so the trial end is the billing anchor.
+func (s *Subscription) StartTrial(plan Plan, now time.Time) error {
+	if err := s.Transition(Trialing, ActorUser, "free trial started", now); err != nil {
+		return err
+	}
+	trialEnd := now.AddDate(0, 0, plan.TrialDays)
+	s.TrialEnd = &trialEnd
+	s.EndDate = trialEnd
+	s.BillingAnchor = trialEnd
+	return nil
+}
+
+// EndTrial makes a trialing subscription ACTIVE once its first paid period,
+// started by Renew at the trial end, is collected.
+func (s *Subscription) EndTrial(now time.Time) error {
+	return s.Transition(Active, ActorSystem, "free trial ended", now)
+}
+
+// ChangePlan moves the subscription to the plan right away. Only a prorated
+// change to a plan billed on another interval, or one made after the period
+// ended, starts a new period now, which the proration charges in full; any
+// other change keeps the current billing period. Any scheduled change is
+// dropped. A trial ends with the change; a paused subscription stays paused.
+// A past due subscription cannot change plan until its payment is collected.
+func (s *Subscription) ChangePlan(from, to Plan, t PlanTransition, now time.Time, actor Actor) error {
+	if s.Status == PastDue {
+		return errors.New("a past due subscription cannot change plan")
+	}
+	if s.Status != Inactive {
+		if err := s.Transition(Active, actor, "plan changed", now); err != nil {
+			return err
+		}
+	}
+	fromUnit, fromCount := from.Interval()
+	toUnit, toCount := to.Interval()
+	s.PlanID = to.ID
+	s.Price = to.Price
+	s.ClearPendingChange()
+	if !t.Prorate || (fromUnit == toUnit && fromCount == toCount && s.EndDate.After(now)) {
+		return nil
+	}
+	s.StartDate = now
+	s.EndDate = to.PeriodEnd(now, 1)
+	s.BillingAnchor = now
+	return nil
+}
+
+// SchedulePlanChange records a move to another plan at the given time.
+func (s *Subscription) SchedulePlanChange(planId uint, at time.Time) {
+	s.PendingPlanID = &planId
+	s.PendingChangeAt = &at
+}
+
+func (s *Subscription) ClearPendingChange() {
+	s.PendingPlanID = nil
+	s.PendingChangeAt = nil
+}
+
+// ApplyPendingChange moves the subscription to its scheduled plan, starting a
+// new billing period at the scheduled time.
+func (s *Subscription) ApplyPendingChange(plan Plan) {
+	at := *s.PendingChangeAt
+	s.PlanID = plan.ID
+	s.Price = plan.Price
+	s.StartDate = at
+	s.EndDate = plan.PeriodEnd(at, 1)
+	s.BillingAnchor = at
+	s.ClearPendingChange()
+}
+
+// RenewalAfter returns the first renewal of the subscription on the plan that
+// is not before t: the end of the current period, or a later period boundary.
+func (s Subscription) RenewalAfter(plan Plan, t time.Time) time.Time {
+	if !s.EndDate.Before(t) {
+		return s.EndDate
+	}
+	return plan.NextPeriodEnd(s.BillingAnchor, t)
 }
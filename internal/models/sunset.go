@@ -0,0 +1,62 @@
+package models
+
+import (
+	"errors"
+	"time"
+)
+
+// PlanSunset retires every version of a plan code. Subscribers are moved to
+// the replacement plan at their first renewal after the notice period, and
+// the sunset completes once the old plan has no subscribers left.
+type PlanSunset struct {
+	ID                uint            `gorm:"primaryKey" json:"id"`
+	PlanCode          string          `gorm:"size:100;not null" json:"plan_code"`
+	ReplacementPlanID uint            `gorm:"not null" json:"replacement_plan_id"`
+	NoticeDays        int             `gorm:"not null" json:"notice_days"`
+	AnnouncedAt       time.Time       `gorm:"not null" json:"announced_at"`
+	CompletedAt       *time.Time      `json:"completed_at,omitempty"`
+	CreatedAt         time.Time       `json:"created_at"`
+	UpdatedAt         time.Time       `json:"updated_at"`
+	Progress          *SunsetProgress `gorm:"-" json:"progress,omitempty"`
+}
+
+// SunsetProgress counts the subscribers still on the retired plan, those
+// scheduled to move and those already moved.
+type SunsetProgress struct {
+	Remaining int `json:"remaining"`
+	Scheduled int `json:"scheduled"`
+	Migrated  int `json:"migrated"`
+}
+
+// SunsetSubscriber records a subscription scheduled to move off a retired
+// plan and when its notice was queued.
+type SunsetSubscriber struct {
+	SunsetID       uint      `gorm:"primaryKey" json:"sunset_id"`
+	SubscriptionID uint      `gorm:"primaryKey" json:"subscription_id"`
+	MigrateAt      time.Time `gorm:"not null" json:"migrate_at"`
+	NotifiedAt     time.Time `gorm:"not null" json:"notified_at"`
+}
+
+func (s PlanSunset) Validate(plan, replacement Plan, now time.Time) error {
+	if s.NoticeDays < 0 {
+		return errors.New("notice_days must not be negative")
+	}
+	if plan.Code == replacement.Code {
+		return errors.New("the replacement must be another plan")
+	}
+	if replacement.Private() || !replacement.Purchasable(now) {
+		return errors.New("the replacement plan must be public and on sale")
+	}
+	return nil
+}
+
+// MigrationDate returns when a subscription on the retired plan moves to the
+// replacement: at the end of its current period, or at the first renewal
+// after the notice period if that ends later.
+func (s PlanSunset) MigrationDate(sub Subscription, plan Plan) time.Time {
+	return sub.RenewalAfter(plan, s.AnnouncedAt.AddDate(0, 0, s.NoticeDays))
+}
+
+func (s PlanSunset) Completed() bool {
+	return s.CompletedAt != nil
+}
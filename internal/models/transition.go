@@ -0,0 +1,100 @@
+package models
+
+import (
+	"errors"
+	"time"
+)
+
+type TransitionKind string
+
+const (
+	TransitionUpgrade   TransitionKind = "upgrade"
+	TransitionDowngrade TransitionKind = "downgrade"
+	TransitionLateral   TransitionKind = "lateral"
+	TransitionForbidden TransitionKind = "forbidden"
+)
+
+type ChangeTiming string
+
+const (
+	ChangeImmediately ChangeTiming = "immediate"
+	ChangeAtPeriodEnd ChangeTiming = "period_end"
+)
+
+// PlanTransition is the policy for moving a subscription from one plan to
+// another. Rules are keyed by plan code so they hold across plan versions.
+type PlanTransition struct {
+	FromPlanCode string         `gorm:"primaryKey;size:100" json:"from_plan_code" validate:"required,max=100"`
+	ToPlanCode   string         `gorm:"primaryKey;size:100" json:"to_plan_code" validate:"required,max=100"`
+	Kind         TransitionKind `gorm:"size:20;not null" json:"kind" validate:"required,oneof=upgrade downgrade lateral forbidden"`
+	Timing       ChangeTiming   `gorm:"size:20;not null" json:"timing" validate:"omitempty,oneof=immediate period_end"`
+	Prorate      bool           `gorm:"not null" json:"prorate"`
+	CreatedAt    time.Time      `json:"created_at"`
+	UpdatedAt    time.Time      `json:"updated_at"`
+}
+
+// Validate checks that a rule other than forbidden says when it applies.
+func (t PlanTransition) Validate() error {
+	if t.FromPlanCode == t.ToPlanCode {
+		return errors.New("from_plan_code and to_plan_code must differ")
+	}
+	if t.Kind != TransitionForbidden && t.Timing == "" {
+		return errors.New("timing is required unless the transition is forbidden")
+	}
+	return nil
+}
+
+// Allowed reports whether the transition may be performed.
+func (t PlanTransition) Allowed() bool {
+	return t.Kind != TransitionForbidden
+}
+
+// DefaultTransition is the policy used when no rule is configured between two
+// plans. Plans are compared on their daily rate for one unit: upgrades apply
+// immediately with proration, downgrades wait for the end of the paid period
+// and moves between plans of the same rate apply immediately.
+func DefaultTransition(from, to Plan) PlanTransition {
+	t := PlanTransition{FromPlanCode: from.Code, ToPlanCode: to.Code}
+	switch fromRate, toRate := from.dailyRate(), to.dailyRate(); {
+	case toRate > fromRate:
+		t.Kind, t.Timing, t.Prorate = TransitionUpgrade, ChangeImmediately, true
+	case toRate < fromRate:
+		t.Kind, t.Timing = TransitionDowngrade, ChangeAtPeriodEnd
+	default:
+		t.Kind, t.Timing = TransitionLateral, ChangeImmediately
+	}
+	return t
+}
+
+// dailyRate is the price of one unit of the plan spread over its billing
+// period, so plans billed on different intervals compare fairly.
+func (p Plan) dailyRate() float64 {
+	amount := p.Price
+	if quote, err := p.Quote(1); err == nil {
+		amount = quote.Amount
+	}
+	anchor := time.Date(2001, time.January, 1, 0, 0, 0, 0, time.UTC)
+	days := p.PeriodEnd(anchor, 1).Sub(anchor).Hours() / 24
+	if days <= 0 {
+		return amount
+	}
+	return amount / days
+}
+
+// PlanChange is the outcome of a plan change: the updated subscription, the
+// transition policy that was applied and, for a prorated change, the credits
+// and charges it gave rise to.
+type PlanChange struct {
+	Subscription
+	Transition PlanTransition    `json:"transition"`
+	LineItems  []BillingLineItem `json:"line_items,omitempty"`
+	AmountDue  float64           `json:"amount_due"`
+}
+
+// PendingPlanChange is a plan change scheduled on a subscription, applied
+// when the subscription renews at EffectiveAt.
+type PendingPlanChange struct {
+	SubscriptionID uint      `json:"subscription_id"`
+	Plan           Plan      `json:"plan"`
+	EffectiveAt    time.Time `json:"effective_at"`
+}
@@ -0,0 +1,128 @@
+package models
+
+import (
+	"encoding/json"
+	"slices"
+	"time"
+
+	"golang.org/x/text/language"
+	"gorm.io/datatypes"
+)
+
+// PlanTranslation holds the customer-facing texts of a plan in one locale.
+// Translations are keyed by plan code so new plan versions keep them.
+type PlanTranslation struct {
+	PlanCode  string         `gorm:"primaryKey;size:100" json:"plan_code"`
+	Locale    string         `gorm:"primaryKey;size:35" json:"locale"`
+	Name      string         `gorm:"size:100;not null" json:"name"`
+	Features  datatypes.JSON `gorm:"type:jsonb;not null" json:"features" swaggertype:"array,string"`
+	CreatedAt time.Time      `json:"created_at"`
+	UpdatedAt time.Time      `json:"updated_at"`
+}
+
+// EntitlementTranslation holds the description of an entitlement in one locale.
+type EntitlementTranslation struct {
+	EntitlementKey string    `gorm:"primaryKey;size:100" json:"entitlement_key"`
+	Locale         string    `gorm:"primaryKey;size:35" json:"locale"`
+	Description    string    `gorm:"not null" json:"description"`
+	CreatedAt      time.Time `json:"created_at"`
+	UpdatedAt      time.Time `json:"updated_at"`
+}
+
+// NormalizeLocale returns the canonical BCP 47 form of a locale, e.g. pt_br
+// becomes pt-BR.
+func NormalizeLocale(locale string) (string, error) {
+	tag, err := language.Parse(locale)
+	if err != nil {
+		return "", err
+	}
+	return tag.String(), nil
+}
+
+// LocaleFallbacks lists the locales to try for a locale, from the most to the
+// least specific: pt-BR falls back to pt.
+func LocaleFallbacks(locale string) []string {
+	fallbacks := []string{locale}
+	tag, err := language.Parse(locale)
+	if err != nil {
+		return fallbacks
+	}
+	if base, confidence := tag.Base(); confidence != language.No && base.String() != locale {
+		fallbacks = append(fallbacks, base.String())
+	}
+	return fallbacks
+}
+
+// AcceptedLocales lists the locales of an Accept-Language header, most
+// preferred first. Malformed headers and the * wildcard yield none.
+func AcceptedLocales(header string) []string {
+	tags, _, err := language.ParseAcceptLanguage(header)
+	if err != nil {
+		return nil
+	}
+	locales := make([]string, 0, len(tags))
+	for _, tag := range tags {
+		// The wildcard parses as "mul", which no translation uses
+		if tag != language.Und && tag.String() != "mul" {
+			locales = append(locales, tag.String())
+		}
+	}
+	return locales
+}
+
+// MatchLocale returns the first offered locale among the requested ones and
+// their base languages, in order, or "" when none is offered: fr-CA is
+// served fr when there is no fr-CA translation.
+func MatchLocale(requested []string, offers []string) string {
+	for _, locale := range requested {
+		for _, candidate := range LocaleFallbacks(locale) {
+			if slices.Contains(offers, candidate) {
+				return candidate
+			}
+		}
+	}
+	return ""
+}
+
+// LocalizePlans replaces plan names and features with their translation in
+// the locale, falling back to the base language and then to the plan's own
+// texts.
+func LocalizePlans(plans []Plan, translations []PlanTranslation, locale string) {
+	byKey := make(map[[2]string]PlanTranslation, len(translations))
+	for _, t := range translations {
+		byKey[[2]string{t.PlanCode, t.Locale}] = t
+	}
+
+	for i := range plans {
+		for _, candidate := range LocaleFallbacks(locale) {
+			t, ok := byKey[[2]string{plans[i].Code, candidate}]
+			if !ok {
+				continue
+			}
+			plans[i].Name = t.Name
+			var features []string
+			if err := json.Unmarshal(t.Features, &features); err == nil && len(features) > 0 {
+				plans[i].Features = t.Features
+			}
+			break
+		}
+	}
+}
+
+// LocalizeEntitlements replaces entitlement descriptions with their
+// translation in the locale, with the same fallbacks as LocalizePlans.
+func LocalizeEntitlements(defs []EntitlementDefinition, translations []EntitlementTranslation, locale string) {
+	byKey := make(map[[2]string]string, len(translations))
+	for _, t := range translations {
+		byKey[[2]string{t.EntitlementKey, t.Locale}] = t.Description
+	}
+
+	for i := range defs {
+		for _, candidate := range LocaleFallbacks(locale) {
+			if description, ok := byKey[[2]string{defs[i].Key, candidate}]; ok {
+				defs[i].Description = description
+				break
+			}
+		}
+	}
+}
@@ -0,0 +1,125 @@
+package models
+
+import (
+	"slices"
+	"testing"
+
+	"gorm.io/datatypes"
+)
+
+func TestNormalizeLocale(t *testing.T) {
+	tests := []struct {
+		locale  string
+		want    string
+		wantErr bool
+	}{
+		{"en", "en", false},
+		{"pt_br", "pt-BR", false},
+		{"ZH-hant-tw", "zh-Hant-TW", false},
+		{"not a locale", "", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.locale, func(t *testing.T) {
+			got, err := NormalizeLocale(tt.locale)
+			if (err != nil) != tt.wantErr || got != tt.want {
+				t.Errorf("NormalizeLocale(%q) = %q, %v; want %q, error %v", tt.locale, got, err, tt.want, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestLocaleFallbacks(t *testing.T) {
+	tests := []struct {
+		locale string
+		want   []string
+	}{
+		{"pt-BR", []string{"pt-BR", "pt"}},
+		{"fr", []string{"fr"}},
+		{"not a locale", []string{"not a locale"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.locale, func(t *testing.T) {
+			if got := LocaleFallbacks(tt.locale); !slices.Equal(got, tt.want) {
+				t.Errorf("LocaleFallbacks(%q) = %v, want %v", tt.locale, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestLocalizePlans(t *testing.T) {
+	translations := []PlanTranslation{
+		{PlanCode: "basic", Locale: "pt", Name: "Básico", Features: datatypes.JSON(`["Suporte"]`)},
+		{PlanCode: "basic", Locale: "pt-BR", Name: "Básico BR", Features: datatypes.JSON(`[]`)},
+		{PlanCode: "pro", Locale: "fr", Name: "Pro FR", Features: datatypes.JSON(`["Assistance"]`)},
+	}
+	plans := func() []Plan {
+		return []Plan{
+			{Code: "basic", Name: "Basic", Features: datatypes.JSON(`["Support"]`)},
+			{Code: "pro", Name: "Pro", Features: datatypes.JSON(`["Priority support"]`)},
+		}
+	}
+	tests := []struct {
+		locale       string
+		wantNames    []string
+		wantFeatures []string
+	}{
+		{"pt-BR", []string{"Básico BR", "Pro"}, []string{`["Support"]`, `["Priority support"]`}},
+		{"pt-PT", []string{"Básico", "Pro"}, []string{`["Suporte"]`, `["Priority support"]`}},
+		{"fr-CA", []string{"Basic", "Pro FR"}, []string{`["Support"]`, `["Assistance"]`}},
+		{"de", []string{"Basic", "Pro"}, []string{`["Support"]`, `["Priority support"]`}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.locale, func(t *testing.T) {
+			got := plans()
+			LocalizePlans(got, translations, tt.locale)
+			for i, plan := range got {
+				if plan.Name != tt.wantNames[i] || string(plan.Features) != tt.wantFeatures[i] {
+					t.Errorf("plan %s = %q %s, want %q %s", plan.Code, plan.Name, plan.Features, tt.wantNames[i], tt.wantFeatures[i])
+				}
+			}
+		})
+	}
+}
+
+func TestAcceptedLocales(t *testing.T) {
+	tests := []struct {
+		header string
+		want   []string
+	}{
+		{"", []string{}},
+		{"de", []string{"de"}},
+		{"fr-CA, fr;q=0.8, en;q=0.5", []string{"fr-CA", "fr", "en"}},
+		{"en;q=0.3, pt-br", []string{"pt-BR", "en"}},
+		{"*", []string{}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.header, func(t *testing.T) {
+			if got := AcceptedLocales(tt.header); !slices.Equal(got, tt.want) {
+				t.Errorf("AcceptedLocales(%q) = %v, want %v", tt.header, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestMatchLocale(t *testing.T) {
+	offers := []string{"en", "fr", "pt-BR"}
+	tests := []struct {
+		name      string
+		requested []string
+		want      string
+	}{
+		{"exact", []string{"pt-BR"}, "pt-BR"},
+		{"region falls back to base", []string{"fr-CA"}, "fr"},
+		{"first match wins", []string{"de", "fr", "en"}, "fr"},
+		{"base does not widen to a region", []string{"pt"}, ""},
+		{"nothing offered", []string{"de"}, ""},
+		{"nothing requested", nil, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := MatchLocale(tt.requested, offers); got != tt.want {
+				t.Errorf("MatchLocale(%v) = %q, want %q", tt.requested, got, tt.want)
+			}
+		})
+	}
+}
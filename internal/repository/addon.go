@@ -0,0 +1,160 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/Harshal292004/subscription-service/internal/models"
+	"github.com/avast/retry-go"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// GetAddOns lists the add-on catalog. A positive planId keeps only the
+// add-ons compatible with that plan.
+func (r *Repository) GetAddOns(planId int) ([]models.AddOn, error) {
+	log.Printf("[GetAddOns] === Starting GetAddOns for plan ID: %d ===", planId)
+	ctx := context.Background()
+
+	var addOns []models.AddOn
+	err := retry.Do(func() error {
+		query := r.DB.WithContext(ctx).Preload("Compatibility").Order("id")
+		if planId > 0 {
+			query = query.Where("id IN (?)",
+				r.DB.Model(&models.PlanAddOn{}).Select("add_on_id").Where("plan_id = ?", planId))
+		}
+		dbErr := query.Find(&addOns).Error
+		if dbErr != nil {
+			log.Printf("[GetAddOns] DB query attempt failed: %v", dbErr)
+		}
+		return dbErr
+	}, retry.Attempts(3), retry.Delay(100*time.Millisecond), retry.DelayType(retry.BackOffDelay), retry.LastErrorOnly(true))
+
+	if err != nil {
+		log.Printf("[GetAddOns] All DB query attempts failed: %v", err)
+		return nil, err
+	}
+
+	log.Printf("[GetAddOns] === Returning %d add-ons ===", len(addOns))
+	return addOns, nil
+}
+
+func (r *Repository) PostAddOn(addOn models.AddOn) (models.AddOn, error) {
+	log.Printf("[PostAddOn] === Starting PostAddOn for code: %s ===", addOn.Code)
+	ctx := context.Background()
+
+	defs, err := r.GetEntitlementDefinitions()
+	if err != nil {
+		return models.AddOn{}, err
+	}
+	if err := addOn.Validate(defs); err != nil {
+		log.Printf("[PostAddOn] Add-on rejected: %v", err)
+		return models.AddOn{}, fmt.Errorf("%w: %v", ErrInvalidAddOn, err)
+	}
+
+	addOn.ID = 0
+	err = retry.Do(func() error {
+		createErr := r.DB.WithContext(ctx).Create(&addOn).Error
+		if createErr != nil {
+			log.Printf("[PostAddOn] DB create attempt failed: %v", createErr)
+		}
+		return createErr
+	}, retry.Attempts(3), retry.Delay(100*time.Millisecond), retry.DelayType(retry.BackOffDelay), retry.LastErrorOnly(true))
+
+	if err != nil {
+		log.Printf("[PostAddOn] Failed to create add-on: %v", err)
+		return models.AddOn{}, err
+	}
+
+	log.Printf("[PostAddOn] === Successfully created add-on ID: %d ===", addOn.ID)
+	return addOn, nil
+}
+
+// AttachAddOn adds an add-on to the user's subscription, or changes its
+// quantity when it is already attached.
+func (r *Repository) AttachAddOn(userId int, addOnId int, quantity int) (models.Subscription, error) {
+	log.Printf("[AttachAddOn] === Starting AttachAddOn for user ID: %d, add-on ID: %d, quantity: %d ===", userId, addOnId, quantity)
+	ctx := context.Background()
+
+	err := retry.Do(func() error {
+		return r.DB.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+			var sub models.Subscription
+			if err := tx.Where("user_id = ? AND is_current", userId).First(&sub).Error; err != nil {
+				return err
+			}
+			if !sub.Entitled() {
+				return ErrSubscriptionNotActive
+			}
+
+			var addOn models.AddOn
+			if err := tx.Preload("Compatibility").First(&addOn, addOnId).Error; err != nil {
+				return err
+			}
+			rule, ok := addOn.Rule(sub.PlanID)
+			if !ok {
+				return ErrAddOnNotCompatible
+			}
+			if quantity > rule.MaxQuantity {
+				return fmt.Errorf("%w: at most %d units of %s on this plan", ErrAddOnNotCompatible, rule.MaxQuantity, addOn.Code)
+			}
+
+			item := models.SubscriptionAddOn{SubscriptionID: sub.ID, AddOnID: addOn.ID, Quantity: quantity}
+			return tx.Clauses(clause.OnConflict{
+				Columns:   []clause.Column{{Name: "subscription_id"}, {Name: "add_on_id"}},
+				DoUpdates: clause.AssignmentColumns([]string{"quantity", "updated_at"}),
+			}).Create(&item).Error
+		})
+	}, retry.Attempts(3), retry.Delay(100*time.Millisecond), retry.DelayType(retry.BackOffDelay),
+		retry.LastErrorOnly(true), retry.RetryIf(retryable))
+
+	if err != nil {
+		log.Printf("[AttachAddOn] Failed to attach add-on: %v", err)
+		return models.Subscription{}, err
+	}
+
+	r.evictSubscription(ctx, userId)
+	log.Printf("[AttachAddOn] === Successfully attached add-on ID %d ===", addOnId)
+	return r.GetCachedSubscription(userId)
+}
+
+func (r *Repository) DetachAddOn(userId int, addOnId int) (models.Subscription, error) {
+	log.Printf("[DetachAddOn] === Starting DetachAddOn for user ID: %d, add-on ID: %d ===", userId, addOnId)
+	ctx := context.Background()
+
+	err := retry.Do(func() error {
+		var sub models.Subscription
+		if err := r.DB.WithContext(ctx).Where("user_id = ? AND is_current", userId).First(&sub).Error; err != nil {
+			return err
+		}
+		result := r.DB.WithContext(ctx).
+			Where("subscription_id = ? AND add_on_id = ?", sub.ID, addOnId).
+			Delete(&models.SubscriptionAddOn{})
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return gorm.ErrRecordNotFound
+		}
+		return nil
+	}, retry.Attempts(3), retry.Delay(100*time.Millisecond), retry.DelayType(retry.BackOffDelay),
+		retry.LastErrorOnly(true), retry.RetryIf(retryable))
+
+	if err != nil {
+		log.Printf("[DetachAddOn] Failed to detach add-on: %v", err)
+		return models.Subscription{}, err
+	}
+
+	r.evictSubscription(ctx, userId)
+	log.Printf("[DetachAddOn] === Successfully detached add-on ID %d ===", addOnId)
+	return r.GetCachedSubscription(userId)
+}
+
+// detachIncompatibleAddOns drops the add-ons of a subscription that are not
+// offered on its plan, after a plan change.
+func detachIncompatibleAddOns(tx *gorm.DB, sub models.Subscription) error {
+	return tx.Where("subscription_id = ? AND add_on_id NOT IN (SELECT add_on_id FROM plan_add_ons WHERE plan_id = ?)",
+		sub.ID, sub.PlanID).
+		Delete(&models.SubscriptionAddOn{}).Error
+}
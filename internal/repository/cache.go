@@ -0,0 +1,126 @@
+package repository
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/Harshal292004/subscription-service/internal/models"
+	"github.com/avast/retry-go"
+)
+
+// catalogVersionKey holds a counter bumped whenever the plans shown to
+// clients change. It lives outside plans:* so dropping the cached catalog
+// does not reset it.
+const catalogVersionKey = "catalog:version"
+
+// invalidatePlansCache drops the cached catalog, including its per-locale
+// copies, so the next read reloads it from the database.
+func (r *Repository) invalidatePlansCache(ctx context.Context) {
+	err := retry.Do(func() error {
+		keys := []string{"plans"}
+		iter := r.Redis.Scan(ctx, 0, "plans:*", 100).Iterator()
+		for iter.Next(ctx) {
+			keys = append(keys, iter.Val())
+		}
+		if err := iter.Err(); err != nil {
+			return err
+		}
+		return r.Redis.Del(ctx, keys...).Err()
+	}, retry.Attempts(3), retry.Delay(100*time.Millisecond), retry.DelayType(retry.BackOffDelay))
+
+	if err != nil {
+		log.Printf("[invalidatePlansCache] Failed to remove plans from cache: %v", err)
+	}
+	r.bumpCatalogVersion(ctx)
+}
+
+// bumpCatalogVersion moves the catalog version on, so ETags handed out for
+// the previous catalog stop matching. A missing counter is seeded from the
+// clock rather than restarting at 1, so versions are not reused after Redis
+// loses the key.
+func (r *Repository) bumpCatalogVersion(ctx context.Context) {
+	err := retry.Do(func() error {
+		if err := r.Redis.SetNX(ctx, catalogVersionKey, time.Now().UnixNano(), 0).Err(); err != nil {
+			return err
+		}
+		return r.Redis.Incr(ctx, catalogVersionKey).Err()
+	}, retry.Attempts(3), retry.Delay(100*time.Millisecond), retry.DelayType(retry.BackOffDelay))
+
+	if err != nil {
+		log.Printf("[bumpCatalogVersion] Failed to bump the catalog version: %v", err)
+	}
+}
+
+// GetCatalogVersion returns a version of what the plan endpoints serve. It
+// combines the catalog counter with the places taken on capped plans and the
+// availability windows opened or closed by now, which change without a
+// catalog write, and is cheap enough to read on every request.
+func (r *Repository) GetCatalogVersion(now time.Time) (string, error) {
+	ctx := context.Background()
+
+	var version string
+	err := retry.Do(func() error {
+		if err := r.Redis.SetNX(ctx, catalogVersionKey, time.Now().UnixNano(), 0).Err(); err != nil {
+			return err
+		}
+		var err error
+		version, err = r.Redis.Get(ctx, catalogVersionKey).Result()
+		return err
+	}, retry.Attempts(3), retry.Delay(100*time.Millisecond), retry.DelayType(retry.BackOffDelay), retry.LastErrorOnly(true))
+
+	if err != nil {
+		log.Printf("[GetCatalogVersion] Failed to read the catalog version: %v", err)
+		return "", err
+	}
+
+	var state struct {
+		Places  int64
+		Windows int64
+	}
+	err = retry.Do(func() error {
+		return r.DB.WithContext(ctx).Model(&models.Plan{}).
+			Select("COALESCE(SUM(subscriber_count) FILTER (WHERE max_subscribers IS NOT NULL), 0) AS places, "+
+				"COUNT(*) FILTER (WHERE available_from <= ?) + COUNT(*) FILTER (WHERE available_until <= ?) AS windows", now, now).
+			Scan(&state).Error
+	}, retry.Attempts(3), retry.Delay(100*time.Millisecond), retry.DelayType(retry.BackOffDelay), retry.LastErrorOnly(true))
+
+	if err != nil {
+		log.Printf("[GetCatalogVersion] Failed to read plan availability: %v", err)
+		return "", err
+	}
+	return fmt.Sprintf("%s.%d.%d", version, state.Places, state.Windows), nil
+}
+
+// cacheJSON stores value under key. Caching is best effort: failures are
+// logged and the caller carries on with the value it already has.
+func (r *Repository) cacheJSON(ctx context.Context, key string, value interface{}, ttl time.Duration) {
+	data, err := json.Marshal(value)
+	if err != nil {
+		log.Printf("[cacheJSON] Failed to marshal %s for caching: %v", key, err)
+		return
+	}
+
+	err = retry.Do(func() error {
+		return r.Redis.Set(ctx, key, data, ttl).Err()
+	}, retry.Attempts(3), retry.Delay(100*time.Millisecond), retry.DelayType(retry.BackOffDelay))
+
+	if err != nil {
+		log.Printf("[cacheJSON] Failed to cache %s: %v", key, err)
+	}
+}
+
+// evictSubscription removes the cached subscription of a user so the next
+// read goes to the database.
+func (r *Repository) evictSubscription(ctx context.Context, userId int) {
+	key := fmt.Sprintf("%d:sub", userId)
+	err := retry.Do(func() error {
+		return r.Redis.Del(ctx, key).Err()
+	}, retry.Attempts(3), retry.Delay(100*time.Millisecond), retry.DelayType(retry.BackOffDelay))
+
+	if err != nil {
+		log.Printf("[evictSubscription] Failed to remove %s from cache (non-critical): %v", key, err)
+	}
+}
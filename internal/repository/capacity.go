@@ -0,0 +1,56 @@
+package repository
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"github.com/Harshal292004/subscription-service/internal/models"
+	"github.com/avast/retry-go"
+	"gorm.io/gorm"
+)
+
+// claimPlace takes one place on the plan for a new subscriber. The check and
+// the increment are a single conditional UPDATE, so concurrent purchases
+// cannot oversell a capped plan. Places are not given back when a subscriber
+// leaves: a cap limits how many subscriptions are ever sold on the plan.
+func claimPlace(tx *gorm.DB, planId uint) error {
+	result := tx.Model(&models.Plan{}).
+		Where("id = ? AND (max_subscribers IS NULL OR subscriber_count < max_subscribers)", planId).
+		UpdateColumn("subscriber_count", gorm.Expr("subscriber_count + 1"))
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return ErrPlanSoldOut
+	}
+	return nil
+}
+
+// GetSubscriberCounts reads the places taken on every capped plan, bypassing
+// the cache so the remaining capacity is always current.
+func (r *Repository) GetSubscriberCounts() (map[uint]int, error) {
+	ctx := context.Background()
+
+	var rows []struct {
+		ID              uint
+		SubscriberCount int
+	}
+	err := retry.Do(func() error {
+		return r.DB.WithContext(ctx).Model(&models.Plan{}).
+			Select("id, subscriber_count").
+			Where("max_subscribers IS NOT NULL").
+			Scan(&rows).Error
+	}, retry.Attempts(3), retry.Delay(100*time.Millisecond), retry.DelayType(retry.BackOffDelay), retry.LastErrorOnly(true))
+
+	if err != nil {
+		log.Printf("[GetSubscriberCounts] Failed to read subscriber counts: %v", err)
+		return nil, err
+	}
+
+	counts := make(map[uint]int, len(rows))
+	for _, row := range rows {
+		counts[row.ID] = row.SubscriberCount
+	}
+	return counts, nil
+}
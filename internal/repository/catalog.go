@@ -0,0 +1,134 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/Harshal292004/subscription-service/internal/catalog"
+	"github.com/Harshal292004/subscription-service/internal/models"
+	"github.com/avast/retry-go"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// GetCurrentPlans reads the current version of every public plan from the
+// database, bypassing the cache. Private plans are managed outside the
+// catalog.
+func (r *Repository) GetCurrentPlans() ([]models.Plan, error) {
+	log.Println("[GetCurrentPlans] === Starting GetCurrentPlans ===")
+	ctx := context.Background()
+
+	var plans []models.Plan
+	err := retry.Do(func() error {
+		return r.DB.WithContext(ctx).Scopes(publicPlans).Where("archived_at IS NULL").Order("code").Find(&plans).Error
+	}, retry.Attempts(3), retry.Delay(100*time.Millisecond), retry.DelayType(retry.BackOffDelay), retry.LastErrorOnly(true))
+
+	if err != nil {
+		log.Printf("[GetCurrentPlans] All DB query attempts failed: %v", err)
+		return nil, err
+	}
+
+	log.Printf("[GetCurrentPlans] === Returning %d plans ===", len(plans))
+	return plans, nil
+}
+
+// ApplyCatalog applies catalog changes in a single transaction. New versions
+// archive the version they replace, so subscribers keep the terms they bought
+// while new subscriptions get the new ones.
+func (r *Repository) ApplyCatalog(changes []catalog.Change) error {
+	log.Printf("[ApplyCatalog] === Starting ApplyCatalog with %d changes ===", len(changes))
+	ctx := context.Background()
+	now := time.Now()
+
+	err := r.DB.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		for _, change := range changes {
+			log.Printf("[ApplyCatalog] Applying %s", change)
+			if err := applyCatalogChange(tx, change, now); err != nil {
+				return fmt.Errorf("%s: %w", change, err)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		log.Printf("[ApplyCatalog] Rolled back: %v", err)
+		return err
+	}
+
+	r.invalidatePlansCache(ctx)
+	log.Println("[ApplyCatalog] === Catalog applied ===")
+	return nil
+}
+
+func applyCatalogChange(tx *gorm.DB, change catalog.Change, now time.Time) error {
+	switch change.Action {
+	case catalog.CreateEntitlement:
+		def := change.Entitlement.Model()
+		return tx.Create(&def).Error
+
+	case catalog.UpdateEntitlement:
+		def := change.Entitlement.Model()
+		return tx.Model(&models.EntitlementDefinition{}).Where("key = ?", def.Key).
+			Updates(map[string]interface{}{"allowed_values": def.AllowedValues, "description": def.Description}).Error
+
+	case catalog.CreatePlan:
+		// A code may come back after being archived; continue its versions.
+		var latest int
+		if err := tx.Model(&models.Plan{}).Where("code = ?", change.Code).
+			Select("COALESCE(MAX(version), 0)").Scan(&latest).Error; err != nil {
+			return err
+		}
+		plan := change.Plan.Model()
+		plan.Version = latest + 1
+		return tx.Create(&plan).Error
+
+	case catalog.NewPlanVersion:
+		current, err := lockCurrentPlan(tx, change.Code)
+		if err != nil {
+			return err
+		}
+		if err := tx.Model(&current).Update("archived_at", now).Error; err != nil {
+			return err
+		}
+		plan := change.Plan.Model()
+		plan.Version = current.Version + 1
+		// Places taken on a capped plan carry over to its next version
+		plan.SubscriberCount = current.SubscriberCount
+		return tx.Create(&plan).Error
+
+	case catalog.UpdatePlan:
+		current, err := lockCurrentPlan(tx, change.Code)
+		if err != nil {
+			return err
+		}
+		plan := change.Plan.Model()
+		if plan.MaxSubscribers != nil && *plan.MaxSubscribers < current.SubscriberCount {
+			return fmt.Errorf("plan %q: max_subscribers cannot be below the %d places already taken",
+				change.Code, current.SubscriberCount)
+		}
+		return tx.Model(&current).Updates(map[string]interface{}{
+			"name":            plan.Name,
+			"features":        plan.Features,
+			"available_from":  plan.AvailableFrom,
+			"available_until": plan.AvailableUntil,
+			"max_subscribers": plan.MaxSubscribers,
+		}).Error
+
+	case catalog.ArchivePlan:
+		current, err := lockCurrentPlan(tx, change.Code)
+		if err != nil {
+			return err
+		}
+		return tx.Model(&current).Update("archived_at", now).Error
+	}
+	return fmt.Errorf("unknown catalog action %q", change.Action)
+}
+
+func lockCurrentPlan(tx *gorm.DB, code string) (models.Plan, error) {
+	var plan models.Plan
+	err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+		Where("code = ? AND archived_at IS NULL", code).
+		First(&plan).Error
+	return plan, err
+}
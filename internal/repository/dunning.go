@@ -0,0 +1,54 @@
+package repository
+
+import (
+	"log"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/Harshal292004/subscription-service/internal/models"
+)
+
+// defaultDunningPolicy keeps past due subscriptions for a week, retrying
+// payment after one, three and five days, then expires them.
+var defaultDunningPolicy = models.DunningPolicy{
+	GraceDays:   7,
+	RetryDays:   []int{1, 3, 5},
+	FinalStatus: models.Expired,
+}
+
+// DunningPolicy reads how failed renewal payments are chased from
+// GRACE_PERIOD_DAYS, DUNNING_RETRY_DAYS (a comma separated list of days
+// after the first failure) and DUNNING_FINAL_STATUS (EXPIRED or CANCELLED).
+// Unset or invalid settings fall back to the defaults.
+func DunningPolicy() models.DunningPolicy {
+	policy := models.DunningPolicy{
+		GraceDays:   defaultDunningPolicy.GraceDays,
+		RetryDays:   defaultDunningPolicy.RetryDays,
+		FinalStatus: defaultDunningPolicy.FinalStatus,
+	}
+	if raw := os.Getenv("GRACE_PERIOD_DAYS"); raw != "" {
+		if days, err := strconv.Atoi(raw); err == nil {
+			policy.GraceDays = days
+		}
+	}
+	if raw := os.Getenv("DUNNING_RETRY_DAYS"); raw != "" {
+		policy.RetryDays = nil
+		for _, part := range strings.Split(raw, ",") {
+			day, err := strconv.Atoi(strings.TrimSpace(part))
+			if err != nil {
+				policy.RetryDays = defaultDunningPolicy.RetryDays
+				break
+			}
+			policy.RetryDays = append(policy.RetryDays, day)
+		}
+	}
+	if raw := os.Getenv("DUNNING_FINAL_STATUS"); raw != "" {
+		policy.FinalStatus = models.SubscriptionStatus(strings.ToUpper(raw))
+	}
+	if err := policy.Validate(); err != nil {
+		log.Printf("[DunningPolicy] Invalid dunning settings, using defaults: %v", err)
+		return defaultDunningPolicy
+	}
+	return policy
+}
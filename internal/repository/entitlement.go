@@ -0,0 +1,104 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/Harshal292004/subscription-service/internal/models"
+	"github.com/avast/retry-go"
+	"gorm.io/gorm"
+)
+
+func (r *Repository) GetEntitlementDefinitions() ([]models.EntitlementDefinition, error) {
+	log.Println("[GetEntitlementDefinitions] === Starting GetEntitlementDefinitions ===")
+	ctx := context.Background()
+
+	var defs []models.EntitlementDefinition
+	err := retry.Do(func() error {
+		dbErr := r.DB.WithContext(ctx).Order("key").Find(&defs).Error
+		if dbErr != nil {
+			log.Printf("[GetEntitlementDefinitions] DB query attempt failed: %v", dbErr)
+		}
+		return dbErr
+	}, retry.Attempts(3), retry.Delay(100*time.Millisecond), retry.DelayType(retry.BackOffDelay), retry.LastErrorOnly(true))
+
+	if err != nil {
+		log.Printf("[GetEntitlementDefinitions] All DB query attempts failed: %v", err)
+		return nil, err
+	}
+
+	log.Printf("[GetEntitlementDefinitions] === Returning %d definitions ===", len(defs))
+	return defs, nil
+}
+
+func (r *Repository) PostEntitlementDefinition(def models.EntitlementDefinition) (models.EntitlementDefinition, error) {
+	log.Printf("[PostEntitlementDefinition] === Starting PostEntitlementDefinition for key: %s ===", def.Key)
+	ctx := context.Background()
+
+	if err := def.Validate(); err != nil {
+		log.Printf("[PostEntitlementDefinition] Definition rejected: %v", err)
+		return models.EntitlementDefinition{}, fmt.Errorf("%w: %v", ErrInvalidEntitlement, err)
+	}
+	if def.AllowedValues == nil {
+		def.AllowedValues = []string{}
+	}
+
+	err := retry.Do(func() error {
+		createErr := r.DB.WithContext(ctx).Create(&def).Error
+		if createErr != nil {
+			log.Printf("[PostEntitlementDefinition] DB create attempt failed: %v", createErr)
+		}
+		return createErr
+	}, retry.Attempts(3), retry.Delay(100*time.Millisecond), retry.DelayType(retry.BackOffDelay), retry.LastErrorOnly(true))
+
+	if err != nil {
+		log.Printf("[PostEntitlementDefinition] Failed to create definition: %v", err)
+		return models.EntitlementDefinition{}, err
+	}
+
+	log.Printf("[PostEntitlementDefinition] === Successfully created definition: %s ===", def.Key)
+	return def, nil
+}
+
+// GetEntitlements returns the effective entitlement set of a user: the plan's
+// entitlements plus those of attached add-ons. Users without an active
+// subscription get the default of every entitlement.
+func (r *Repository) GetEntitlements(userId int) (models.EntitlementSet, error) {
+	log.Printf("[GetEntitlements] === Starting GetEntitlements for user ID: %d ===", userId)
+
+	defs, err := r.GetEntitlementDefinitions()
+	if err != nil {
+		return nil, err
+	}
+
+	sub, err := r.GetCachedSubscription(userId)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		log.Printf("[GetEntitlements] No subscription for user ID %d, using defaults", userId)
+		return models.EffectiveEntitlements(defs, nil), nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if !sub.Entitled() {
+		log.Printf("[GetEntitlements] Subscription ID %d is %v, using defaults", sub.ID, sub.Status)
+		return models.EffectiveEntitlements(defs, nil), nil
+	}
+
+	plan, err := r.GetCachedPlan(int(sub.PlanID))
+	if err != nil {
+		return nil, err
+	}
+
+	entitlements := models.EffectiveEntitlements(defs, plan.Entitlements)
+	for _, item := range sub.AddOns {
+		if item.AddOn != nil {
+			entitlements.Grant(defs, item.AddOn.Entitlements, item.Quantity)
+		}
+	}
+
+	log.Printf("[GetEntitlements] === Returning entitlements of plan ID %d with %d add-ons ===", plan.ID, len(sub.AddOns))
+	return entitlements, nil
+}
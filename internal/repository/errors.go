@@ -0,0 +1,73 @@
+package repository
+
+import (
+	"errors"
+
+	"gorm.io/gorm"
+)
+
+var (
+	ErrInvalidPlan           = errors.New("invalid plan")
+	ErrInvalidEntitlement    = errors.New("invalid entitlement")
+	ErrInvalidAddOn          = errors.New("invalid add-on")
+	ErrAddOnNotCompatible    = errors.New("add-on is not available on the current plan")
+	ErrSubscriptionNotActive = errors.New("subscription is not active")
+	ErrAlreadySubscribed     = errors.New("user already has a subscription")
+	ErrInvalidCancellation   = errors.New("invalid cancellation")
+	ErrNoPendingCancellation = errors.New("subscription is not scheduled for cancellation")
+	ErrCancellationPending   = errors.New("subscription is scheduled for cancellation")
+	ErrInvalidPause          = errors.New("invalid pause")
+	ErrSubscriptionNotPaused = errors.New("subscription is not paused")
+	ErrStatusTransition      = errors.New("illegal subscription status transition")
+	ErrPlanUnavailable       = errors.New("plan is no longer available")
+	ErrPlanSoldOut           = errors.New("plan is sold out")
+	ErrInvalidTransition     = errors.New("invalid plan transition")
+	ErrTransitionNotAllowed  = errors.New("plan change is not allowed")
+	ErrInvalidExperiment     = errors.New("invalid experiment")
+	ErrInvalidSunset         = errors.New("invalid plan sunset")
+	ErrInvalidMigrationJob   = errors.New("invalid migration job")
+	ErrMigrationJobState     = errors.New("migration job cannot change status")
+	ErrInvalidPriceChange    = errors.New("invalid price change")
+	ErrInvalidQuantity       = errors.New("invalid seat quantity")
+	ErrSeatsInUse            = errors.New("more seats are assigned than requested")
+	ErrNoSeatAvailable       = errors.New("all seats are assigned")
+)
+
+// businessErrors are outcomes that a second attempt cannot change.
+var businessErrors = []error{
+	gorm.ErrRecordNotFound,
+	ErrInvalidPlan,
+	ErrInvalidEntitlement,
+	ErrInvalidAddOn,
+	ErrAddOnNotCompatible,
+	ErrSubscriptionNotActive,
+	ErrAlreadySubscribed,
+	ErrInvalidCancellation,
+	ErrNoPendingCancellation,
+	ErrCancellationPending,
+	ErrInvalidPause,
+	ErrSubscriptionNotPaused,
+	ErrStatusTransition,
+	ErrPlanUnavailable,
+	ErrPlanSoldOut,
+	ErrInvalidTransition,
+	ErrTransitionNotAllowed,
+	ErrInvalidExperiment,
+	ErrInvalidSunset,
+	ErrInvalidMigrationJob,
+	ErrMigrationJobState,
+	ErrInvalidPriceChange,
+	ErrInvalidQuantity,
+	ErrSeatsInUse,
+	ErrNoSeatAvailable,
+}
+
+// retryable tells retry.Do to give up early on business errors.
+func retryable(err error) bool {
+	for _, target := range businessErrors {
+		if errors.Is(err, target) {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,264 @@
+package repository
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/Harshal292004/subscription-service/internal/models"
+	"github.com/avast/retry-go"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+func (r *Repository) GetExperiments() ([]models.Experiment, error) {
+	log.Println("[GetExperiments] === Starting GetExperiments ===")
+	ctx := context.Background()
+
+	var experiments []models.Experiment
+	err := retry.Do(func() error {
+		return r.DB.WithContext(ctx).Preload("Variants", variantsInOrder).Order("id").Find(&experiments).Error
+	}, retry.Attempts(3), retry.Delay(100*time.Millisecond), retry.DelayType(retry.BackOffDelay), retry.LastErrorOnly(true))
+
+	if err != nil {
+		log.Printf("[GetExperiments] All DB query attempts failed: %v", err)
+		return nil, err
+	}
+
+	log.Printf("[GetExperiments] === Returning %d experiments ===", len(experiments))
+	return experiments, nil
+}
+
+// PostExperiment starts a price experiment on a plan. A plan runs at most
+// one experiment at a time.
+func (r *Repository) PostExperiment(experiment models.Experiment) (models.Experiment, error) {
+	log.Printf("[PostExperiment] === Starting PostExperiment %q on plan ID: %d ===", experiment.Name, experiment.PlanID)
+	ctx := context.Background()
+
+	var plan models.Plan
+	err := retry.Do(func() error {
+		return r.DB.WithContext(ctx).First(&plan, experiment.PlanID).Error
+	}, retry.Attempts(3), retry.Delay(100*time.Millisecond), retry.DelayType(retry.BackOffDelay),
+		retry.LastErrorOnly(true), retry.RetryIf(retryable))
+
+	if err != nil {
+		log.Printf("[PostExperiment] Failed to fetch plan: %v", err)
+		return models.Experiment{}, err
+	}
+	if plan.ArchivedAt != nil {
+		return models.Experiment{}, fmt.Errorf("%w: plan %d is archived", ErrInvalidExperiment, plan.ID)
+	}
+	if plan.Private() {
+		return models.Experiment{}, fmt.Errorf("%w: plan %d is private", ErrInvalidExperiment, plan.ID)
+	}
+	if err := experiment.Validate(plan); err != nil {
+		log.Printf("[PostExperiment] Experiment rejected: %v", err)
+		return models.Experiment{}, fmt.Errorf("%w: %v", ErrInvalidExperiment, err)
+	}
+
+	experiment.ID = 0
+	experiment.StartedAt = time.Now()
+	experiment.EndedAt = nil
+	err = r.DB.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		var running int64
+		if err := tx.Model(&models.Experiment{}).
+			Where("plan_id = ? AND ended_at IS NULL", plan.ID).
+			Count(&running).Error; err != nil {
+			return err
+		}
+		if running > 0 {
+			return fmt.Errorf("%w: plan %d already has a running experiment", ErrInvalidExperiment, plan.ID)
+		}
+		return tx.Create(&experiment).Error
+	})
+	if err != nil {
+		log.Printf("[PostExperiment] Failed to create experiment: %v", err)
+		return models.Experiment{}, err
+	}
+
+	r.invalidatePlansCache(ctx)
+	log.Printf("[PostExperiment] === Started experiment ID: %d ===", experiment.ID)
+	return experiment, nil
+}
+
+// StopExperiment ends an experiment. Users see the plan price again, while
+// subscriptions bought at a variant price keep it.
+func (r *Repository) StopExperiment(experimentId int) (models.Experiment, error) {
+	log.Printf("[StopExperiment] === Starting StopExperiment for experiment ID: %d ===", experimentId)
+	ctx := context.Background()
+
+	var experiment models.Experiment
+	err := retry.Do(func() error {
+		return r.DB.WithContext(ctx).Preload("Variants", variantsInOrder).First(&experiment, experimentId).Error
+	}, retry.Attempts(3), retry.Delay(100*time.Millisecond), retry.DelayType(retry.BackOffDelay),
+		retry.LastErrorOnly(true), retry.RetryIf(retryable))
+
+	if err != nil {
+		log.Printf("[StopExperiment] Failed to fetch experiment: %v", err)
+		return models.Experiment{}, err
+	}
+	if !experiment.Running() {
+		return experiment, nil
+	}
+
+	now := time.Now()
+	if err := r.DB.WithContext(ctx).Model(&experiment).Update("ended_at", now).Error; err != nil {
+		log.Printf("[StopExperiment] Failed to stop experiment: %v", err)
+		return models.Experiment{}, err
+	}
+	experiment.EndedAt = &now
+
+	r.invalidatePlansCache(ctx)
+	log.Printf("[StopExperiment] === Stopped experiment ID: %d ===", experiment.ID)
+	return experiment, nil
+}
+
+// GetExperimentReport counts, per variant, the users assigned and those who
+// subscribed at the variant price.
+func (r *Repository) GetExperimentReport(experimentId int) ([]models.VariantResult, error) {
+	log.Printf("[GetExperimentReport] === Starting GetExperimentReport for experiment ID: %d ===", experimentId)
+	ctx := context.Background()
+
+	var experiment models.Experiment
+	err := retry.Do(func() error {
+		return r.DB.WithContext(ctx).Preload("Variants", variantsInOrder).First(&experiment, experimentId).Error
+	}, retry.Attempts(3), retry.Delay(100*time.Millisecond), retry.DelayType(retry.BackOffDelay),
+		retry.LastErrorOnly(true), retry.RetryIf(retryable))
+
+	if err != nil {
+		log.Printf("[GetExperimentReport] Failed to fetch experiment: %v", err)
+		return nil, err
+	}
+
+	var rows []struct {
+		VariantID uint
+		Assigned  int
+		Converted int
+	}
+	err = r.DB.WithContext(ctx).Model(&models.ExperimentAssignment{}).
+		Select("variant_id, COUNT(*) AS assigned, COUNT(converted_at) AS converted").
+		Where("experiment_id = ?", experimentId).
+		Group("variant_id").
+		Scan(&rows).Error
+	if err != nil {
+		log.Printf("[GetExperimentReport] Failed to count assignments: %v", err)
+		return nil, err
+	}
+
+	results := make([]models.VariantResult, 0, len(experiment.Variants))
+	for _, v := range experiment.Variants {
+		result := models.VariantResult{VariantID: v.ID, Name: v.Name, Price: v.Price}
+		for _, row := range rows {
+			if row.VariantID == v.ID {
+				result.Assigned, result.Converted = row.Assigned, row.Converted
+			}
+		}
+		if result.Assigned > 0 {
+			result.ConversionRate = float64(result.Converted) / float64(result.Assigned)
+		}
+		results = append(results, result)
+	}
+	return results, nil
+}
+
+// PersonalizePlans shows the user the price of their variant on every plan
+// under a running experiment and records the assignment.
+func (r *Repository) PersonalizePlans(userId int, plans []models.Plan) error {
+	ctx := context.Background()
+
+	experiments, err := r.getRunningExperiments(ctx)
+	if err != nil {
+		return err
+	}
+	for _, experiment := range experiments {
+		for i := range plans {
+			if plans[i].ID != experiment.PlanID {
+				continue
+			}
+			variant := experiment.Assign(uint(userId))
+			plans[i].Price = variant.Price
+			if err := recordAssignment(r.DB.WithContext(ctx), experiment.ID, userId, variant.ID, time.Now()); err != nil {
+				log.Printf("[PersonalizePlans] Failed to record assignment to experiment ID %d: %v", experiment.ID, err)
+			}
+		}
+	}
+	return nil
+}
+
+// experimentPrice returns the price a user pays for a plan, honoring their
+// variant when the plan runs an experiment, and marks the assignment as
+// converted.
+func experimentPrice(tx *gorm.DB, plan models.Plan, userId int, now time.Time) (float64, error) {
+	price, experimentId, err := variantPrice(tx, plan, userId, now)
+	if err != nil || experimentId == 0 {
+		return price, err
+	}
+	err = tx.Model(&models.ExperimentAssignment{}).
+		Where("experiment_id = ? AND user_id = ? AND converted_at IS NULL", experimentId, userId).
+		Update("converted_at", now).Error
+	if err != nil {
+		return 0, err
+	}
+	return price, nil
+}
+
+// variantPrice returns the price a user is offered for a plan and the
+// experiment it comes from, zero when the plan runs none.
+func variantPrice(tx *gorm.DB, plan models.Plan, userId int, now time.Time) (float64, uint, error) {
+	var experiment models.Experiment
+	err := tx.Preload("Variants", variantsInOrder).Where("plan_id = ? AND ended_at IS NULL", plan.ID).First(&experiment).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return plan.Price, 0, nil
+	}
+	if err != nil {
+		return 0, 0, err
+	}
+
+	variant := experiment.Assign(uint(userId))
+	if err := recordAssignment(tx, experiment.ID, userId, variant.ID, now); err != nil {
+		return 0, 0, err
+	}
+	return variant.Price, experiment.ID, nil
+}
+
+func recordAssignment(db *gorm.DB, experimentId uint, userId int, variantId uint, now time.Time) error {
+	return db.Clauses(clause.OnConflict{DoNothing: true}).Create(&models.ExperimentAssignment{
+		ExperimentID: experimentId,
+		UserID:       uint(userId),
+		VariantID:    variantId,
+		AssignedAt:   now,
+	}).Error
+}
+
+// getRunningExperiments lists running experiments with their variants,
+// cached next to the catalog.
+func (r *Repository) getRunningExperiments(ctx context.Context) ([]models.Experiment, error) {
+	key := "plans:experiments"
+
+	var experiments []models.Experiment
+	if val, err := r.Redis.Get(ctx, key).Result(); err == nil {
+		if err := json.Unmarshal([]byte(val), &experiments); err == nil {
+			return experiments, nil
+		}
+	}
+
+	err := retry.Do(func() error {
+		return r.DB.WithContext(ctx).Preload("Variants", variantsInOrder).Where("ended_at IS NULL").Find(&experiments).Error
+	}, retry.Attempts(3), retry.Delay(100*time.Millisecond), retry.DelayType(retry.BackOffDelay), retry.LastErrorOnly(true))
+
+	if err != nil {
+		log.Printf("[getRunningExperiments] Failed to load running experiments: %v", err)
+		return nil, err
+	}
+
+	r.cacheJSON(ctx, key, experiments, 12*time.Hour)
+	return experiments, nil
+}
+
+// variantsInOrder keeps variants in creation order, which Assign relies on.
+func variantsInOrder(db *gorm.DB) *gorm.DB {
+	return db.Order("id")
+}
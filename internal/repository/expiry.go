@@ -0,0 +1,122 @@
+package repository
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"log"
+	"time"
+
+	"github.com/Harshal292004/subscription-service/internal/models"
+	"github.com/redis/go-redis/v9"
+	"gorm.io/gorm"
+)
+
+const (
+	// ExpiryJob names the expiry sweeper in its lock and last-run status.
+	ExpiryJob = "expiry"
+
+	expiryLockTTL = 5 * time.Minute
+)
+
+// ExpireSubscriptions moves every current subscription whose paid period or
+// free trial is over and that will not renew to EXPIRED, batchSize at a time, and drops
+// their cached copies. Subscriptions that renew automatically, are paused
+// or are scheduled for cancellation are left to their own jobs.
+//
+// A Redis lock keeps the sweeper to one instance at a time; a run that
+// finds the lock taken does nothing and reports false. Each run's outcome is
+// kept as the job's last-run status.
+func (r *Repository) ExpireSubscriptions(now time.Time, batchSize int) (models.JobRun, bool, error) {
+	log.Println("[ExpireSubscriptions] === Starting ExpireSubscriptions ===")
+	ctx := context.Background()
+
+	release, ok, err := r.acquireLock(ctx, ExpiryJob, expiryLockTTL)
+	if err != nil {
+		log.Printf("[ExpireSubscriptions] Failed to take the expiry lock: %v", err)
+		return models.JobRun{}, false, err
+	}
+	if !ok {
+		log.Println("[ExpireSubscriptions] === Another instance is sweeping, skipping ===")
+		return models.JobRun{}, false, nil
+	}
+	defer release()
+
+	run := models.JobRun{Job: ExpiryJob, StartedAt: now}
+	for {
+		var userIds []uint
+		// Each expired subscription records its transition in the same
+		// statement.
+		err = r.DB.WithContext(ctx).Raw(`
+			WITH expired AS (
+				UPDATE subscriptions s SET status = ?, updated_at = ?
+				FROM (
+					SELECT id, status FROM subscriptions
+					WHERE is_current AND status IN ? AND NOT auto_renew AND NOT cancel_at_period_end
+						AND paused_at IS NULL AND end_date <= ?
+					ORDER BY end_date
+					LIMIT ?
+					FOR UPDATE SKIP LOCKED
+				) due
+				WHERE s.id = due.id
+				RETURNING s.id, s.user_id, due.status AS from_status
+			)
+			INSERT INTO subscription_transitions (subscription_id, user_id, from_status, to_status, actor, reason, created_at)
+			SELECT id, user_id, from_status, ?, ?, ?, ? FROM expired
+			RETURNING user_id`,
+			models.Expired, now, []models.SubscriptionStatus{models.Active, models.Trialing}, now, batchSize,
+			models.Expired, models.ActorSystem, "period ended without renewal", now).Scan(&userIds).Error
+		if err != nil {
+			log.Printf("[ExpireSubscriptions] Failed to expire batch %d: %v", run.Batches+1, err)
+			break
+		}
+		if len(userIds) == 0 {
+			break
+		}
+
+		for _, userId := range userIds {
+			r.evictSubscription(ctx, int(userId))
+		}
+		run.Batches++
+		run.Processed += len(userIds)
+		log.Printf("[ExpireSubscriptions] Expired %d subscriptions in batch %d", len(userIds), run.Batches)
+		if len(userIds) < batchSize {
+			break
+		}
+	}
+
+	finished := time.Now()
+	run.FinishedAt = &finished
+	if err != nil {
+		run.Error = err.Error()
+	}
+	r.cacheJSON(ctx, jobRunKey(ExpiryJob), run, 0)
+
+	log.Printf("[ExpireSubscriptions] === Expired %d subscriptions ===", run.Processed)
+	return run, true, err
+}
+
+// GetJobRun returns the last-run status of a background job, or
+// gorm.ErrRecordNotFound when it has not run yet.
+func (r *Repository) GetJobRun(job string) (models.JobRun, error) {
+	ctx := context.Background()
+
+	val, err := r.Redis.Get(ctx, jobRunKey(job)).Result()
+	if errors.Is(err, redis.Nil) {
+		return models.JobRun{}, gorm.ErrRecordNotFound
+	}
+	if err != nil {
+		log.Printf("[GetJobRun] Failed to read status of job %s: %v", job, err)
+		return models.JobRun{}, err
+	}
+
+	var run models.JobRun
+	if err := json.Unmarshal([]byte(val), &run); err != nil {
+		return models.JobRun{}, err
+	}
+	return run, nil
+}
+
+func jobRunKey(job string) string {
+	return "jobs:" + job + ":last_run"
+}
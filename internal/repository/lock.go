@@ -0,0 +1,42 @@
+package repository
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"log"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// releaseLock deletes a lock only while it still holds the caller's token,
+// so a run that outlived its lock cannot release the next holder's.
+var releaseLock = redis.NewScript(`
+if redis.call("GET", KEYS[1]) == ARGV[1] then
+	return redis.call("DEL", KEYS[1])
+end
+return 0`)
+
+// acquireLock takes the named lock in Redis for at most ttl, so a background
+// job runs on one service instance at a time. It reports false when another
+// instance holds the lock; release must be called once the job is done.
+func (r *Repository) acquireLock(ctx context.Context, name string, ttl time.Duration) (release func(), ok bool, err error) {
+	buf := make([]byte, 16)
+	if _, err := rand.Read(buf); err != nil {
+		return nil, false, err
+	}
+	token := hex.EncodeToString(buf)
+	key := "lock:" + name
+
+	ok, err = r.Redis.SetNX(ctx, key, token, ttl).Result()
+	if err != nil || !ok {
+		return nil, false, err
+	}
+	release = func() {
+		if err := releaseLock.Run(ctx, r.Redis, []string{key}, token).Err(); err != nil {
+			log.Printf("[acquireLock] Failed to release %s (it expires on its own): %v", key, err)
+		}
+	}
+	return release, true, nil
+}
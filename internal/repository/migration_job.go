@@ -0,0 +1,391 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/Harshal292004/subscription-service/internal/models"
+	"github.com/avast/retry-go"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// errDryRun rolls back the changes made for an item of a dry run.
+var errDryRun = errors.New("dry run")
+
+// skippedItem leaves an item of a job untouched for the given reason.
+type skippedItem string
+
+func (e skippedItem) Error() string { return string(e) }
+
+// PostMigrationJob creates a migration job listing every live subscription
+// currently on the source plan. The job starts pending.
+func (r *Repository) PostMigrationJob(job models.MigrationJob) (models.MigrationJob, error) {
+	log.Printf("[PostMigrationJob] === Starting PostMigrationJob from plan ID %d to plan ID %d, dry run: %v ===",
+		job.FromPlanID, job.ToPlanID, job.DryRun)
+	ctx := context.Background()
+
+	var from, to models.Plan
+	err := retry.Do(func() error {
+		if err := r.DB.WithContext(ctx).First(&from, job.FromPlanID).Error; err != nil {
+			return err
+		}
+		return r.DB.WithContext(ctx).First(&to, job.ToPlanID).Error
+	}, retry.Attempts(3), retry.Delay(100*time.Millisecond), retry.DelayType(retry.BackOffDelay),
+		retry.LastErrorOnly(true), retry.RetryIf(retryable))
+
+	if err != nil {
+		log.Printf("[PostMigrationJob] Failed to fetch plans: %v", err)
+		return models.MigrationJob{}, err
+	}
+	if err := job.Validate(from, to); err != nil {
+		log.Printf("[PostMigrationJob] Job rejected: %v", err)
+		return models.MigrationJob{}, fmt.Errorf("%w: %v", ErrInvalidMigrationJob, err)
+	}
+
+	job.ID = 0
+	job.Status = models.MigrationPending
+	job.StartedAt, job.FinishedAt = nil, nil
+	err = r.DB.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(&job).Error; err != nil {
+			return err
+		}
+		return tx.Exec(`
+			INSERT INTO migration_job_items (job_id, subscription_id, user_id, status)
+			SELECT ?, id, user_id, ? FROM subscriptions WHERE plan_id = ? AND status NOT IN ?`,
+			job.ID, models.MigrationItemPending, from.ID, []models.SubscriptionStatus{models.Cancelled, models.Expired},
+		).Error
+	})
+	if err != nil {
+		log.Printf("[PostMigrationJob] Failed to create job: %v", err)
+		return models.MigrationJob{}, err
+	}
+
+	log.Printf("[PostMigrationJob] === Created migration job ID: %d ===", job.ID)
+	return r.GetMigrationJob(int(job.ID))
+}
+
+func (r *Repository) GetMigrationJobs() ([]models.MigrationJob, error) {
+	log.Println("[GetMigrationJobs] === Starting GetMigrationJobs ===")
+	ctx := context.Background()
+
+	var jobs []models.MigrationJob
+	err := retry.Do(func() error {
+		return r.DB.WithContext(ctx).Order("id DESC").Find(&jobs).Error
+	}, retry.Attempts(3), retry.Delay(100*time.Millisecond), retry.DelayType(retry.BackOffDelay), retry.LastErrorOnly(true))
+
+	if err != nil {
+		log.Printf("[GetMigrationJobs] All DB query attempts failed: %v", err)
+		return nil, err
+	}
+	for i := range jobs {
+		progress, err := r.migrationJobProgress(ctx, jobs[i].ID)
+		if err != nil {
+			return nil, err
+		}
+		jobs[i].Progress = &progress
+	}
+
+	log.Printf("[GetMigrationJobs] === Returning %d jobs ===", len(jobs))
+	return jobs, nil
+}
+
+// GetMigrationJob returns a job with its progress.
+func (r *Repository) GetMigrationJob(jobId int) (models.MigrationJob, error) {
+	ctx := context.Background()
+
+	var job models.MigrationJob
+	err := retry.Do(func() error {
+		return r.DB.WithContext(ctx).First(&job, jobId).Error
+	}, retry.Attempts(3), retry.Delay(100*time.Millisecond), retry.DelayType(retry.BackOffDelay),
+		retry.LastErrorOnly(true), retry.RetryIf(retryable))
+
+	if err != nil {
+		log.Printf("[GetMigrationJob] Failed to fetch job ID %d: %v", jobId, err)
+		return models.MigrationJob{}, err
+	}
+	progress, err := r.migrationJobProgress(ctx, job.ID)
+	if err != nil {
+		return models.MigrationJob{}, err
+	}
+	job.Progress = &progress
+	return job, nil
+}
+
+// GetMigrationJobItems lists the items of a job, optionally only those with
+// the given status.
+func (r *Repository) GetMigrationJobItems(jobId int, status models.MigrationItemStatus) ([]models.MigrationJobItem, error) {
+	log.Printf("[GetMigrationJobItems] === Starting GetMigrationJobItems for job ID: %d, status: %q ===", jobId, status)
+	ctx := context.Background()
+
+	if _, err := r.GetMigrationJob(jobId); err != nil {
+		return nil, err
+	}
+
+	var items []models.MigrationJobItem
+	err := retry.Do(func() error {
+		query := r.DB.WithContext(ctx).Where("job_id = ?", jobId).Order("subscription_id")
+		if status != "" {
+			query = query.Where("status = ?", status)
+		}
+		return query.Find(&items).Error
+	}, retry.Attempts(3), retry.Delay(100*time.Millisecond), retry.DelayType(retry.BackOffDelay), retry.LastErrorOnly(true))
+
+	if err != nil {
+		log.Printf("[GetMigrationJobItems] All DB query attempts failed: %v", err)
+		return nil, err
+	}
+	return items, nil
+}
+
+// SetMigrationJobStatus pauses, resumes or cancels a job. Cancelling leaves
+// the remaining items pending; items already migrated stay migrated.
+func (r *Repository) SetMigrationJobStatus(jobId int, status models.MigrationJobStatus) (models.MigrationJob, error) {
+	log.Printf("[SetMigrationJobStatus] === Setting migration job ID %d to %s ===", jobId, status)
+	ctx := context.Background()
+
+	allowed := map[models.MigrationJobStatus][]models.MigrationJobStatus{
+		models.MigrationPaused:    {models.MigrationPending, models.MigrationRunning},
+		models.MigrationRunning:   {models.MigrationPaused},
+		models.MigrationCancelled: {models.MigrationPending, models.MigrationRunning, models.MigrationPaused},
+	}
+	from, ok := allowed[status]
+	if !ok {
+		return models.MigrationJob{}, fmt.Errorf("%w: cannot set status %q", ErrInvalidMigrationJob, status)
+	}
+
+	updates := map[string]interface{}{"status": status}
+	if status == models.MigrationCancelled {
+		updates["finished_at"] = time.Now()
+	}
+	var result *gorm.DB
+	err := retry.Do(func() error {
+		result = r.DB.WithContext(ctx).Model(&models.MigrationJob{}).
+			Where("id = ? AND status IN ?", jobId, from).
+			Updates(updates)
+		return result.Error
+	}, retry.Attempts(3), retry.Delay(100*time.Millisecond), retry.DelayType(retry.BackOffDelay), retry.LastErrorOnly(true))
+
+	if err != nil {
+		log.Printf("[SetMigrationJobStatus] Failed to update job: %v", err)
+		return models.MigrationJob{}, err
+	}
+
+	job, err := r.GetMigrationJob(jobId)
+	if err != nil {
+		return models.MigrationJob{}, err
+	}
+	if result.RowsAffected == 0 && job.Status != status {
+		return job, fmt.Errorf("%w: job %d is %s", ErrMigrationJobState, job.ID, job.Status)
+	}
+	return job, nil
+}
+
+// GetRunnableMigrationJobs lists the IDs of the jobs waiting for a worker.
+func (r *Repository) GetRunnableMigrationJobs() ([]int, error) {
+	var ids []int
+	err := r.DB.WithContext(context.Background()).Model(&models.MigrationJob{}).
+		Where("status IN ?", []models.MigrationJobStatus{models.MigrationPending, models.MigrationRunning}).
+		Order("id").
+		Pluck("id", &ids).Error
+	return ids, err
+}
+
+// RunMigrationJob processes a job chunk by chunk until it completes or is
+// paused or cancelled. Each chunk runs in one transaction holding the job
+// row, so concurrent workers take turns and a pause or cancel takes effect
+// between chunks.
+func (r *Repository) RunMigrationJob(jobId int) error {
+	log.Printf("[RunMigrationJob] === Starting RunMigrationJob for job ID: %d ===", jobId)
+	ctx := context.Background()
+
+	for {
+		var migrated []uint
+		var job models.MigrationJob
+		err := r.DB.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+			var err error
+			job, migrated, err = runMigrationChunk(tx, jobId, time.Now())
+			return err
+		})
+		if err != nil {
+			log.Printf("[RunMigrationJob] Chunk of job ID %d failed: %v", jobId, err)
+			return err
+		}
+
+		for _, userId := range migrated {
+			r.evictSubscription(ctx, int(userId))
+		}
+		if len(migrated) > 0 {
+			log.Printf("[RunMigrationJob] Job ID %d migrated %d subscriptions", jobId, len(migrated))
+		}
+		if !job.Runnable() {
+			log.Printf("[RunMigrationJob] === Job ID %d is %s ===", jobId, job.Status)
+			return nil
+		}
+	}
+}
+
+// runMigrationChunk processes the next chunk of a job and returns the job
+// and the users whose subscription was changed.
+func runMigrationChunk(tx *gorm.DB, jobId int, now time.Time) (models.MigrationJob, []uint, error) {
+	var job models.MigrationJob
+	if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&job, jobId).Error; err != nil {
+		return job, nil, err
+	}
+	if !job.Runnable() {
+		return job, nil, nil
+	}
+	if job.Status == models.MigrationPending {
+		job.Status, job.StartedAt = models.MigrationRunning, &now
+		if err := tx.Model(&job).Updates(map[string]interface{}{"status": job.Status, "started_at": now}).Error; err != nil {
+			return job, nil, err
+		}
+	}
+
+	var items []models.MigrationJobItem
+	err := tx.Where("job_id = ? AND status = ?", job.ID, models.MigrationItemPending).
+		Order("subscription_id").
+		Limit(job.ChunkSize).
+		Find(&items).Error
+	if err != nil {
+		return job, nil, err
+	}
+	if len(items) == 0 {
+		job.Status, job.FinishedAt = models.MigrationCompleted, &now
+		err := tx.Model(&job).Updates(map[string]interface{}{"status": job.Status, "finished_at": now}).Error
+		return job, nil, err
+	}
+
+	var from, to models.Plan
+	if err := tx.First(&from, job.FromPlanID).Error; err != nil {
+		return job, nil, err
+	}
+	if err := tx.First(&to, job.ToPlanID).Error; err != nil {
+		return job, nil, err
+	}
+
+	var migrated []uint
+	var skipped skippedItem
+	for _, item := range items {
+		// Each item runs in a savepoint, so one failure does not undo the chunk
+		err := tx.Transaction(func(tx *gorm.DB) error {
+			if err := migrateSubscription(tx, job, item, from, to); err != nil {
+				return err
+			}
+			if job.DryRun {
+				return errDryRun
+			}
+			return nil
+		})
+
+		item.Status, item.Error = models.MigrationItemMigrated, ""
+		switch {
+		case err == nil:
+			migrated = append(migrated, item.UserID)
+		case errors.Is(err, errDryRun):
+			item.Status = models.MigrationItemWouldMigrate
+		case errors.As(err, &skipped):
+			item.Status, item.Error = models.MigrationItemSkipped, string(skipped)
+		case errors.Is(err, gorm.ErrRecordNotFound):
+			item.Status, item.Error = models.MigrationItemSkipped, "subscription is no longer on the source plan"
+		default:
+			item.Status, item.Error = models.MigrationItemFailed, err.Error()
+		}
+		err = tx.Model(&models.MigrationJobItem{}).
+			Where("job_id = ? AND subscription_id = ?", item.JobID, item.SubscriptionID).
+			Updates(map[string]interface{}{"status": item.Status, "error": item.Error, "processed_at": now}).Error
+		if err != nil {
+			return job, nil, err
+		}
+	}
+	return job, migrated, nil
+}
+
+// migrateSubscription moves one subscription to the target plan right away.
+func migrateSubscription(tx *gorm.DB, job models.MigrationJob, item models.MigrationJobItem, from, to models.Plan) error {
+	var sub models.Subscription
+	err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+		Where("id = ? AND plan_id = ? AND status NOT IN ?", item.SubscriptionID, from.ID,
+			[]models.SubscriptionStatus{models.Cancelled, models.Expired}).
+		First(&sub).Error
+	if err != nil {
+		return err
+	}
+	// Changing plan would end dunning without collecting the payment
+	if sub.Status == models.PastDue {
+		return skippedItem("subscription is past due")
+	}
+
+	if err := to.CheckQuantity(sub.Quantity); err != nil {
+		return fmt.Errorf("%w: %v", ErrInvalidQuantity, err)
+	}
+	if err := claimPlace(tx, to.ID); err != nil {
+		return err
+	}
+	now := time.Now()
+	if err := job.Migrate(&sub, from, to, now); err != nil {
+		return illegalTransition(err)
+	}
+	// Keep the price the user is offered on the target plan, including an
+	// experiment variant
+	price, err := experimentPrice(tx, to, int(sub.UserID), now)
+	if err != nil {
+		return err
+	}
+	sub.Price = price
+	sub.AddOns = nil
+	err = tx.Model(&sub).Updates(map[string]interface{}{
+		"plan_id":           sub.PlanID,
+		"price":             sub.Price,
+		"status":            sub.Status,
+		"start_date":        sub.StartDate,
+		"end_date":          sub.EndDate,
+		"billing_anchor":    sub.BillingAnchor,
+		"trial_end":         sub.TrialEnd,
+		"pending_plan_id":   sub.PendingPlanID,
+		"pending_change_at": sub.PendingChangeAt,
+	}).Error
+	if err != nil {
+		return err
+	}
+	if err := recordTransitions(tx, sub.TakeTransitions()); err != nil {
+		return err
+	}
+	return detachIncompatibleAddOns(tx, sub)
+}
+
+func (r *Repository) migrationJobProgress(ctx context.Context, jobId uint) (models.MigrationJobProgress, error) {
+	var rows []struct {
+		Status models.MigrationItemStatus
+		Count  int
+	}
+	err := r.DB.WithContext(ctx).Model(&models.MigrationJobItem{}).
+		Select("status, COUNT(*) AS count").
+		Where("job_id = ?", jobId).
+		Group("status").
+		Scan(&rows).Error
+	if err != nil {
+		return models.MigrationJobProgress{}, err
+	}
+
+	var progress models.MigrationJobProgress
+	for _, row := range rows {
+		progress.Total += row.Count
+		switch row.Status {
+		case models.MigrationItemPending:
+			progress.Pending = row.Count
+		case models.MigrationItemMigrated:
+			progress.Migrated = row.Count
+		case models.MigrationItemWouldMigrate:
+			progress.WouldMigrate = row.Count
+		case models.MigrationItemSkipped:
+			progress.Skipped = row.Count
+		case models.MigrationItemFailed:
+			progress.Failed = row.Count
+		}
+	}
+	return progress, nil
+}
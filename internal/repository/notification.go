@@ -0,0 +1,51 @@
+package repository
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"github.com/Harshal292004/subscription-service/internal/models"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// DeliverNotifications hands up to limit due notifications to send and marks
+// the delivered ones as sent. Rows are locked with SKIP LOCKED, so several
+// instances can drain the queue without sending a notification twice; a
+// failed send is retried on the next run.
+func (r *Repository) DeliverNotifications(now time.Time, limit int, send func(models.Notification) error) (int, error) {
+	log.Println("[DeliverNotifications] === Starting DeliverNotifications ===")
+	ctx := context.Background()
+
+	sent := 0
+	err := r.DB.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		var due []models.Notification
+		err := tx.Clauses(clause.Locking{Strength: "UPDATE", Options: "SKIP LOCKED"}).
+			Where("sent_at IS NULL AND send_at <= ?", now).
+			Order("send_at").
+			Limit(limit).
+			Find(&due).Error
+		if err != nil {
+			return err
+		}
+		for _, n := range due {
+			if err := send(n); err != nil {
+				log.Printf("[DeliverNotifications] Failed to send notification ID %d: %v", n.ID, err)
+				continue
+			}
+			if err := tx.Model(&n).Update("sent_at", now).Error; err != nil {
+				return err
+			}
+			sent++
+		}
+		return nil
+	})
+	if err != nil {
+		log.Printf("[DeliverNotifications] Failed to deliver notifications: %v", err)
+		return 0, err
+	}
+
+	log.Printf("[DeliverNotifications] === Delivered %d notifications ===", sent)
+	return sent, nil
+}
@@ -0,0 +1,87 @@
+package repository
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"github.com/Harshal292004/subscription-service/internal/models"
+	"github.com/avast/retry-go"
+	"gorm.io/gorm"
+)
+
+func (r *Repository) PostOrganization(org models.Organization) (models.Organization, error) {
+	log.Printf("[PostOrganization] === Starting PostOrganization for: %s ===", org.Name)
+	ctx := context.Background()
+
+	org.ID = 0
+	err := retry.Do(func() error {
+		return r.DB.WithContext(ctx).Create(&org).Error
+	}, retry.Attempts(3), retry.Delay(100*time.Millisecond), retry.DelayType(retry.BackOffDelay), retry.LastErrorOnly(true))
+
+	if err != nil {
+		log.Printf("[PostOrganization] Failed to create organization: %v", err)
+		return models.Organization{}, err
+	}
+
+	log.Printf("[PostOrganization] === Created organization ID: %d ===", org.ID)
+	return org, nil
+}
+
+// PutOrganizationMember moves a user into an organization. The user sees the
+// private plans of the organization from then on.
+func (r *Repository) PutOrganizationMember(orgId int, userId int) (models.User, error) {
+	log.Printf("[PutOrganizationMember] === Adding user ID %d to organization ID %d ===", userId, orgId)
+	ctx := context.Background()
+
+	var user models.User
+	err := retry.Do(func() error {
+		return r.DB.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+			if err := tx.Select("id").First(&models.Organization{}, orgId).Error; err != nil {
+				return err
+			}
+			if err := tx.First(&user, userId).Error; err != nil {
+				return err
+			}
+			return tx.Model(&user).Update("organization_id", orgId).Error
+		})
+	}, retry.Attempts(3), retry.Delay(100*time.Millisecond), retry.DelayType(retry.BackOffDelay),
+		retry.LastErrorOnly(true), retry.RetryIf(retryable))
+
+	if err != nil {
+		log.Printf("[PutOrganizationMember] Failed to add member: %v", err)
+		return models.User{}, err
+	}
+
+	// The member now sees the organization's private plans
+	r.bumpCatalogVersion(ctx)
+
+	log.Printf("[PutOrganizationMember] === User ID %d is now in organization ID %d ===", userId, orgId)
+	return user, nil
+}
+
+// DeleteOrganizationMember removes a user from an organization. Existing
+// subscriptions on the organization's private plans are kept.
+func (r *Repository) DeleteOrganizationMember(orgId int, userId int) error {
+	log.Printf("[DeleteOrganizationMember] === Removing user ID %d from organization ID %d ===", userId, orgId)
+	ctx := context.Background()
+
+	var result *gorm.DB
+	err := retry.Do(func() error {
+		result = r.DB.WithContext(ctx).Model(&models.User{}).
+			Where("id = ? AND organization_id = ?", userId, orgId).
+			Update("organization_id", nil)
+		return result.Error
+	}, retry.Attempts(3), retry.Delay(100*time.Millisecond), retry.DelayType(retry.BackOffDelay), retry.LastErrorOnly(true))
+
+	if err != nil {
+		log.Printf("[DeleteOrganizationMember] Failed to remove member: %v", err)
+		return err
+	}
+	if result.RowsAffected == 0 {
+		return gorm.ErrRecordNotFound
+	}
+
+	r.bumpCatalogVersion(ctx)
+	return nil
+}
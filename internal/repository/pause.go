@@ -0,0 +1,166 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/Harshal292004/subscription-service/internal/models"
+	"github.com/avast/retry-go"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// maxPauseDays is the longest a subscription may stay paused, set with
+// MAX_PAUSE_DAYS. Zero means pauses are not limited.
+func maxPauseDays() int {
+	days, err := strconv.Atoi(os.Getenv("MAX_PAUSE_DAYS"))
+	if err != nil || days < 0 {
+		return 0
+	}
+	return days
+}
+
+// PauseSubscription puts the user's current subscription on hold until
+// resumeAt, or until resumed when resumeAt is nil.
+func (r *Repository) PauseSubscription(userId int, resumeAt *time.Time) (models.Subscription, error) {
+	log.Printf("[PauseSubscription] === Starting PauseSubscription for user ID: %d ===", userId)
+	ctx := context.Background()
+	now := time.Now()
+	maxDays := maxPauseDays()
+
+	var sub models.Subscription
+	err := r.DB.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+			Where("user_id = ? AND is_current", userId).First(&sub).Error; err != nil {
+			return err
+		}
+		if sub.Status != models.Active {
+			return ErrSubscriptionNotActive
+		}
+		if sub.CancelAtPeriodEnd {
+			return ErrCancellationPending
+		}
+		if err := sub.ValidatePause(now, resumeAt, maxDays); err != nil {
+			return fmt.Errorf("%w: %v", ErrInvalidPause, err)
+		}
+		if err := sub.Pause(now, resumeAt, maxDays); err != nil {
+			return illegalTransition(err)
+		}
+		if err := tx.Model(&sub).Updates(map[string]interface{}{
+			"status":    sub.Status,
+			"paused_at": sub.PausedAt,
+			"resume_at": sub.ResumeAt,
+		}).Error; err != nil {
+			return err
+		}
+		return recordTransitions(tx, sub.TakeTransitions())
+	})
+
+	if err != nil {
+		log.Printf("[PauseSubscription] Failed to pause subscription: %v", err)
+		return models.Subscription{}, err
+	}
+
+	r.evictSubscription(ctx, userId)
+	log.Printf("[PauseSubscription] === Paused subscription ID %d ===", sub.ID)
+	return sub, nil
+}
+
+// ResumeSubscription ends the pause on the user's current subscription.
+func (r *Repository) ResumeSubscription(userId int) (models.Subscription, error) {
+	log.Printf("[ResumeSubscription] === Starting ResumeSubscription for user ID: %d ===", userId)
+	ctx := context.Background()
+
+	var sub models.Subscription
+	err := r.DB.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+			Where("user_id = ? AND is_current", userId).First(&sub).Error; err != nil {
+			return err
+		}
+		if !sub.Paused() {
+			return ErrSubscriptionNotPaused
+		}
+		return resumeSubscription(tx, &sub, time.Now(), models.ActorUser, "resumed by user")
+	})
+
+	if err != nil {
+		log.Printf("[ResumeSubscription] Failed to resume subscription: %v", err)
+		return models.Subscription{}, err
+	}
+
+	r.evictSubscription(ctx, userId)
+	log.Printf("[ResumeSubscription] === Resumed subscription ID %d, paid until %v ===", sub.ID, sub.EndDate)
+	return sub, nil
+}
+
+// ResumePausedSubscriptions resumes every paused subscription whose resume
+// date has come and returns how many were resumed.
+func (r *Repository) ResumePausedSubscriptions(now time.Time) (int, error) {
+	log.Println("[ResumePausedSubscriptions] === Starting ResumePausedSubscriptions ===")
+	ctx := context.Background()
+
+	var ids []uint
+	err := retry.Do(func() error {
+		return r.DB.WithContext(ctx).Model(&models.Subscription{}).
+			Where("status = ? AND paused_at IS NOT NULL AND resume_at <= ?", models.Inactive, now).
+			Pluck("id", &ids).Error
+	}, retry.Attempts(3), retry.Delay(100*time.Millisecond), retry.DelayType(retry.BackOffDelay), retry.LastErrorOnly(true))
+
+	if err != nil {
+		log.Printf("[ResumePausedSubscriptions] Failed to find due resumptions: %v", err)
+		return 0, err
+	}
+	log.Printf("[ResumePausedSubscriptions] Found %d due resumptions", len(ids))
+
+	resumed := 0
+	for _, id := range ids {
+		var sub models.Subscription
+		err := r.DB.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+			// Locking and checking again keeps a subscription resumed by its
+			// user in the meantime from being pushed back twice.
+			if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&sub, id).Error; err != nil {
+				return err
+			}
+			if !sub.Paused() {
+				return ErrSubscriptionNotPaused
+			}
+			// Resuming at the scheduled date rather than now keeps a late
+			// run from crediting the user extra time.
+			return resumeSubscription(tx, &sub, *sub.ResumeAt, models.ActorSystem, "pause ended")
+		})
+		if err != nil {
+			log.Printf("[ResumePausedSubscriptions] Skipped subscription ID %d: %v", id, err)
+			continue
+		}
+
+		r.evictSubscription(ctx, int(sub.UserID))
+		resumed++
+		log.Printf("[ResumePausedSubscriptions] Resumed subscription ID %d, paid until %v", sub.ID, sub.EndDate)
+	}
+
+	log.Printf("[ResumePausedSubscriptions] === Resumed %d subscriptions ===", resumed)
+	return resumed, nil
+}
+
+func resumeSubscription(tx *gorm.DB, sub *models.Subscription, at time.Time, actor models.Actor, reason string) error {
+	if err := sub.Resume(at, actor, reason); err != nil {
+		return illegalTransition(err)
+	}
+	err := tx.Model(sub).Updates(map[string]interface{}{
+		"status":            sub.Status,
+		"start_date":        sub.StartDate,
+		"end_date":          sub.EndDate,
+		"billing_anchor":    sub.BillingAnchor,
+		"pending_change_at": sub.PendingChangeAt,
+		"paused_at":         nil,
+		"resume_at":         nil,
+	}).Error
+	if err != nil {
+		return err
+	}
+	return recordTransitions(tx, sub.TakeTransitions())
+}
@@ -0,0 +1,189 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/Harshal292004/subscription-service/internal/catalog"
+	"github.com/Harshal292004/subscription-service/internal/models"
+	"github.com/avast/retry-go"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// GetCachedPlan looks a plan up in the cached catalog, falling back to the
+// database for archived versions.
+func (r *Repository) GetCachedPlan(planId int) (models.Plan, error) {
+	plans, err := r.GetCachedPlans()
+	if err != nil {
+		return models.Plan{}, err
+	}
+	for _, plan := range plans {
+		if plan.ID == uint(planId) {
+			return plan, nil
+		}
+	}
+
+	// Archived plan versions are not in the catalog but still have subscribers
+	log.Printf("[GetCachedPlan] Plan ID %d not in catalog, reading from DB", planId)
+	var plan models.Plan
+	err = retry.Do(func() error {
+		return r.DB.WithContext(context.Background()).First(&plan, planId).Error
+	}, retry.Attempts(3), retry.Delay(100*time.Millisecond), retry.DelayType(retry.BackOffDelay),
+		retry.LastErrorOnly(true), retry.RetryIf(retryable))
+	return plan, err
+}
+
+func (r *Repository) PostPlan(plan models.Plan) (models.Plan, error) {
+	log.Printf("[PostPlan] === Starting PostPlan for plan: %s ===", plan.Name)
+	ctx := context.Background()
+
+	if err := r.validatePlan(plan); err != nil {
+		return models.Plan{}, err
+	}
+
+	var taken int64
+	if err := r.DB.WithContext(ctx).Model(&models.Plan{}).Where("code = ?", plan.Code).Count(&taken).Error; err != nil {
+		return models.Plan{}, err
+	}
+	if taken > 0 {
+		log.Printf("[PostPlan] Code %s already in use", plan.Code)
+		return models.Plan{}, fmt.Errorf("%w: code %q is already in use", ErrInvalidPlan, plan.Code)
+	}
+
+	plan.ID = 0
+	plan.Version = 1
+	err := retry.Do(func() error {
+		createErr := r.DB.WithContext(ctx).Create(&plan).Error
+		if createErr != nil {
+			log.Printf("[PostPlan] DB create attempt failed: %v", createErr)
+		} else {
+			log.Printf("[PostPlan] Plan created successfully with ID: %d", plan.ID)
+		}
+		return createErr
+	}, retry.Attempts(3), retry.Delay(100*time.Millisecond), retry.DelayType(retry.BackOffDelay), retry.LastErrorOnly(true))
+
+	if err != nil {
+		log.Printf("[PostPlan] Failed to create plan after retries: %v", err)
+		return models.Plan{}, err
+	}
+
+	r.invalidatePlansCache(ctx)
+	log.Printf("[PostPlan] === Successfully created plan ID: %d ===", plan.ID)
+	return plan, nil
+}
+
+func (r *Repository) PutPlan(planId int, plan models.Plan) (models.Plan, error) {
+	log.Printf("[PutPlan] === Starting PutPlan for plan ID: %d ===", planId)
+	ctx := context.Background()
+
+	var existing models.Plan
+	err := retry.Do(func() error {
+		return r.DB.WithContext(ctx).First(&existing, planId).Error
+	}, retry.Attempts(3), retry.Delay(100*time.Millisecond), retry.DelayType(retry.BackOffDelay),
+		retry.LastErrorOnly(true), retry.RetryIf(retryable))
+
+	if err != nil {
+		log.Printf("[PutPlan] Failed to fetch plan: %v", err)
+		return models.Plan{}, err
+	}
+
+	plan.ID = existing.ID
+	plan.Code = existing.Code
+	plan.Version = existing.Version
+	plan.ArchivedAt = existing.ArchivedAt
+	plan.BasePlanID = existing.BasePlanID
+	plan.OwnerUserID = existing.OwnerUserID
+	plan.OrganizationID = existing.OrganizationID
+	plan.CreatedAt = existing.CreatedAt
+	if err := r.validatePlan(plan); err != nil {
+		return models.Plan{}, err
+	}
+	if err := r.checkSoldTerms(ctx, existing, plan); err != nil {
+		return models.Plan{}, err
+	}
+
+	err = retry.Do(func() error {
+		saveErr := r.DB.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+			// Places are claimed concurrently, so the count is read under
+			// the row lock and never written back from the request.
+			var current models.Plan
+			if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&current, plan.ID).Error; err != nil {
+				return err
+			}
+			if plan.MaxSubscribers != nil && *plan.MaxSubscribers < current.SubscriberCount {
+				return fmt.Errorf("%w: max_subscribers cannot be below the %d places already taken",
+					ErrInvalidPlan, current.SubscriberCount)
+			}
+			plan.SubscriberCount = current.SubscriberCount
+			return tx.Omit("subscriber_count").Save(&plan).Error
+		})
+		if saveErr != nil {
+			log.Printf("[PutPlan] Save attempt failed: %v", saveErr)
+		}
+		return saveErr
+	}, retry.Attempts(3), retry.Delay(100*time.Millisecond), retry.DelayType(retry.BackOffDelay),
+		retry.LastErrorOnly(true), retry.RetryIf(retryable))
+
+	if err != nil {
+		log.Printf("[PutPlan] Failed to update plan: %v", err)
+		return models.Plan{}, err
+	}
+
+	r.invalidatePlansCache(ctx)
+	log.Printf("[PutPlan] === Successfully updated plan ID: %d ===", plan.ID)
+	return plan, nil
+}
+
+// checkSoldTerms refuses to change the terms of an archived plan version or
+// of a plan with live subscribers in place, since subscribers keep the terms
+// they bought. Such changes publish a new version through the catalog.
+func (r *Repository) checkSoldTerms(ctx context.Context, existing, plan models.Plan) error {
+	before, err := catalog.FromModel(existing)
+	if err != nil {
+		return fmt.Errorf("%w: %v", ErrInvalidPlan, err)
+	}
+	after, err := catalog.FromModel(plan)
+	if err != nil {
+		return fmt.Errorf("%w: %v", ErrInvalidPlan, err)
+	}
+	terms := catalog.ChangedTerms(before, after)
+	if len(terms) == 0 {
+		return nil
+	}
+	if existing.ArchivedAt != nil {
+		log.Printf("[checkSoldTerms] Plan ID %d is archived, rejecting changes to %v", existing.ID, terms)
+		return fmt.Errorf("%w: %s cannot change on an archived plan version",
+			ErrInvalidPlan, strings.Join(terms, ", "))
+	}
+
+	var subscribers int64
+	err = r.DB.WithContext(ctx).Model(&models.Subscription{}).
+		Where("(plan_id = ? OR pending_plan_id = ?) AND status NOT IN ?", existing.ID, existing.ID,
+			[]models.SubscriptionStatus{models.Cancelled, models.Expired}).
+		Count(&subscribers).Error
+	if err != nil {
+		return err
+	}
+	if subscribers > 0 {
+		log.Printf("[checkSoldTerms] Plan ID %d has %d subscribers, rejecting changes to %v", existing.ID, subscribers, terms)
+		return fmt.Errorf("%w: %s cannot change on a plan with subscribers; publish a new version through the catalog",
+			ErrInvalidPlan, strings.Join(terms, ", "))
+	}
+	return nil
+}
+
+func (r *Repository) validatePlan(plan models.Plan) error {
+	defs, err := r.GetEntitlementDefinitions()
+	if err != nil {
+		return err
+	}
+	if err := plan.Validate(defs); err != nil {
+		log.Printf("[validatePlan] Plan rejected: %v", err)
+		return fmt.Errorf("%w: %v", ErrInvalidPlan, err)
+	}
+	return nil
+}
@@ -0,0 +1,276 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"slices"
+	"time"
+
+	"github.com/Harshal292004/subscription-service/internal/models"
+	"github.com/avast/retry-go"
+	"gorm.io/gorm"
+)
+
+// PostPriceChange announces a new price to the live subscribers of a plan.
+// Every subscriber paying another price is scheduled for their first renewal
+// after the notice period and sent a notice, except the exempted users.
+func (r *Repository) PostPriceChange(change models.PriceChange, exemptUserIds []uint) (models.PriceChange, error) {
+	log.Printf("[PostPriceChange] === Starting PostPriceChange for plan ID: %d, new price: %.2f ===", change.PlanID, change.NewPrice)
+	ctx := context.Background()
+
+	var plan models.Plan
+	err := retry.Do(func() error {
+		return r.DB.WithContext(ctx).First(&plan, change.PlanID).Error
+	}, retry.Attempts(3), retry.Delay(100*time.Millisecond), retry.DelayType(retry.BackOffDelay),
+		retry.LastErrorOnly(true), retry.RetryIf(retryable))
+
+	if err != nil {
+		log.Printf("[PostPriceChange] Failed to fetch plan: %v", err)
+		return models.PriceChange{}, err
+	}
+	if err := change.Validate(plan); err != nil {
+		log.Printf("[PostPriceChange] Price change rejected: %v", err)
+		return models.PriceChange{}, fmt.Errorf("%w: %v", ErrInvalidPriceChange, err)
+	}
+
+	now := time.Now()
+	change.ID = 0
+	change.AnnouncedAt = now
+	change.CompletedAt = nil
+	err = r.DB.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		var open int64
+		if err := tx.Model(&models.PriceChange{}).
+			Where("plan_id = ? AND completed_at IS NULL", plan.ID).
+			Count(&open).Error; err != nil {
+			return err
+		}
+		if open > 0 {
+			return fmt.Errorf("%w: plan %d already has a price change in progress", ErrInvalidPriceChange, plan.ID)
+		}
+		if err := tx.Create(&change).Error; err != nil {
+			return err
+		}
+
+		var subs []models.Subscription
+		if err := tx.Where("plan_id = ? AND status NOT IN ? AND price <> ?", plan.ID,
+			[]models.SubscriptionStatus{models.Cancelled, models.Expired}, change.NewPrice).
+			Find(&subs).Error; err != nil {
+			return err
+		}
+		for _, sub := range subs {
+			record := models.PriceChangeSubscriber{
+				PriceChangeID:  change.ID,
+				SubscriptionID: sub.ID,
+				UserID:         sub.UserID,
+				OldPrice:       sub.Price,
+				Status:         models.PriceChangeScheduled,
+				EffectiveAt:    change.EffectiveDate(sub, plan),
+			}
+			if slices.Contains(exemptUserIds, sub.UserID) {
+				record.Status = models.PriceChangeExempt
+			}
+			if err := tx.Create(&record).Error; err != nil {
+				return err
+			}
+			if record.Status == models.PriceChangeScheduled {
+				notice := models.PriceChangeNotice(sub, plan, change.NewPrice, record.EffectiveAt, now)
+				if err := tx.Create(&notice).Error; err != nil {
+					return err
+				}
+			}
+		}
+		log.Printf("[PostPriceChange] Scheduled %d subscriptions", len(subs))
+		return nil
+	})
+	if err != nil {
+		log.Printf("[PostPriceChange] Failed to create price change: %v", err)
+		return models.PriceChange{}, err
+	}
+
+	log.Printf("[PostPriceChange] === Announced price change ID: %d ===", change.ID)
+	return r.GetPriceChange(int(change.ID))
+}
+
+func (r *Repository) GetPriceChanges() ([]models.PriceChange, error) {
+	log.Println("[GetPriceChanges] === Starting GetPriceChanges ===")
+	ctx := context.Background()
+
+	var changes []models.PriceChange
+	err := retry.Do(func() error {
+		return r.DB.WithContext(ctx).Order("id DESC").Find(&changes).Error
+	}, retry.Attempts(3), retry.Delay(100*time.Millisecond), retry.DelayType(retry.BackOffDelay), retry.LastErrorOnly(true))
+
+	if err != nil {
+		log.Printf("[GetPriceChanges] All DB query attempts failed: %v", err)
+		return nil, err
+	}
+	for i := range changes {
+		progress, err := r.priceChangeProgress(ctx, changes[i].ID)
+		if err != nil {
+			return nil, err
+		}
+		changes[i].Progress = &progress
+	}
+
+	log.Printf("[GetPriceChanges] === Returning %d price changes ===", len(changes))
+	return changes, nil
+}
+
+// GetPriceChange returns a price change with its progress.
+func (r *Repository) GetPriceChange(changeId int) (models.PriceChange, error) {
+	ctx := context.Background()
+
+	var change models.PriceChange
+	err := retry.Do(func() error {
+		return r.DB.WithContext(ctx).First(&change, changeId).Error
+	}, retry.Attempts(3), retry.Delay(100*time.Millisecond), retry.DelayType(retry.BackOffDelay),
+		retry.LastErrorOnly(true), retry.RetryIf(retryable))
+
+	if err != nil {
+		log.Printf("[GetPriceChange] Failed to fetch price change ID %d: %v", changeId, err)
+		return models.PriceChange{}, err
+	}
+	progress, err := r.priceChangeProgress(ctx, change.ID)
+	if err != nil {
+		return models.PriceChange{}, err
+	}
+	change.Progress = &progress
+	return change, nil
+}
+
+// ExemptFromPriceChange keeps a user on the price they pay. Only changes not
+// applied yet can be waived.
+func (r *Repository) ExemptFromPriceChange(changeId int, userId int) (models.PriceChangeSubscriber, error) {
+	log.Printf("[ExemptFromPriceChange] === Exempting user ID %d from price change ID %d ===", userId, changeId)
+	ctx := context.Background()
+
+	var record models.PriceChangeSubscriber
+	err := retry.Do(func() error {
+		return r.DB.WithContext(ctx).
+			Where("price_change_id = ? AND user_id = ?", changeId, userId).
+			First(&record).Error
+	}, retry.Attempts(3), retry.Delay(100*time.Millisecond), retry.DelayType(retry.BackOffDelay),
+		retry.LastErrorOnly(true), retry.RetryIf(retryable))
+
+	if err != nil {
+		log.Printf("[ExemptFromPriceChange] Failed to find the subscriber: %v", err)
+		return models.PriceChangeSubscriber{}, err
+	}
+	if record.Status == models.PriceChangeExempt {
+		return record, nil
+	}
+
+	result := r.DB.WithContext(ctx).Model(&models.PriceChangeSubscriber{}).
+		Where("price_change_id = ? AND subscription_id = ? AND status = ?", record.PriceChangeID, record.SubscriptionID, models.PriceChangeScheduled).
+		Update("status", models.PriceChangeExempt)
+	if result.Error != nil {
+		log.Printf("[ExemptFromPriceChange] Failed to exempt the subscriber: %v", result.Error)
+		return models.PriceChangeSubscriber{}, result.Error
+	}
+	if result.RowsAffected == 0 {
+		return record, fmt.Errorf("%w: the new price was already %s for user %d", ErrInvalidPriceChange, record.Status, userId)
+	}
+
+	record.Status = models.PriceChangeExempt
+	log.Printf("[ExemptFromPriceChange] === User ID %d exempted ===", userId)
+	return record, nil
+}
+
+// ApplyPriceChanges sets the new price on every subscription whose change is
+// due and completes the price changes with nothing left to apply. It returns
+// how many subscriptions were repriced.
+func (r *Repository) ApplyPriceChanges(now time.Time) (int, error) {
+	log.Println("[ApplyPriceChanges] === Starting ApplyPriceChanges ===")
+	ctx := context.Background()
+
+	var due []struct {
+		models.PriceChangeSubscriber
+		PlanID   uint
+		NewPrice float64
+	}
+	err := retry.Do(func() error {
+		return r.DB.WithContext(ctx).Table("price_change_subscribers").
+			Select("price_change_subscribers.*, price_changes.plan_id, price_changes.new_price").
+			Joins("JOIN price_changes ON price_changes.id = price_change_subscribers.price_change_id").
+			Where("price_change_subscribers.status = ? AND price_change_subscribers.effective_at <= ?", models.PriceChangeScheduled, now).
+			Scan(&due).Error
+	}, retry.Attempts(3), retry.Delay(100*time.Millisecond), retry.DelayType(retry.BackOffDelay), retry.LastErrorOnly(true))
+
+	if err != nil {
+		log.Printf("[ApplyPriceChanges] Failed to find due price changes: %v", err)
+		return 0, err
+	}
+	log.Printf("[ApplyPriceChanges] Found %d due price changes", len(due))
+
+	applied := 0
+	for _, d := range due {
+		status := models.PriceChangeApplied
+		err := r.DB.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+			// Subscribers who left the plan meanwhile keep their new terms
+			result := tx.Model(&models.Subscription{}).
+				Where("id = ? AND plan_id = ? AND status NOT IN ?", d.SubscriptionID, d.PlanID,
+					[]models.SubscriptionStatus{models.Cancelled, models.Expired}).
+				Update("price", d.NewPrice)
+			if result.Error != nil {
+				return result.Error
+			}
+			if result.RowsAffected == 0 {
+				status = models.PriceChangeSkipped
+			}
+			return tx.Model(&models.PriceChangeSubscriber{}).
+				Where("price_change_id = ? AND subscription_id = ? AND status = ?", d.PriceChangeID, d.SubscriptionID, models.PriceChangeScheduled).
+				Updates(map[string]interface{}{"status": status, "applied_at": now}).Error
+		})
+		if err != nil {
+			log.Printf("[ApplyPriceChanges] Failed to reprice subscription ID %d: %v", d.SubscriptionID, err)
+			continue
+		}
+		if status == models.PriceChangeApplied {
+			r.evictSubscription(ctx, int(d.UserID))
+			applied++
+		}
+	}
+
+	err = r.DB.WithContext(ctx).Model(&models.PriceChange{}).
+		Where("completed_at IS NULL").
+		Where("NOT EXISTS (SELECT 1 FROM price_change_subscribers WHERE price_change_id = price_changes.id AND status = ?)", models.PriceChangeScheduled).
+		Update("completed_at", now).Error
+	if err != nil {
+		log.Printf("[ApplyPriceChanges] Failed to complete price changes: %v", err)
+		return applied, err
+	}
+
+	log.Printf("[ApplyPriceChanges] === Repriced %d subscriptions ===", applied)
+	return applied, nil
+}
+
+func (r *Repository) priceChangeProgress(ctx context.Context, changeId uint) (models.PriceChangeProgress, error) {
+	var rows []struct {
+		Status models.PriceChangeStatus
+		Count  int
+	}
+	err := r.DB.WithContext(ctx).Model(&models.PriceChangeSubscriber{}).
+		Select("status, COUNT(*) AS count").
+		Where("price_change_id = ?", changeId).
+		Group("status").
+		Scan(&rows).Error
+	if err != nil {
+		return models.PriceChangeProgress{}, err
+	}
+
+	var progress models.PriceChangeProgress
+	for _, row := range rows {
+		switch row.Status {
+		case models.PriceChangeScheduled:
+			progress.Scheduled = row.Count
+		case models.PriceChangeApplied:
+			progress.Applied = row.Count
+		case models.PriceChangeSkipped:
+			progress.Skipped = row.Count
+		case models.PriceChangeExempt:
+			progress.Exempt = row.Count
+		}
+	}
+	return progress, nil
+}
@@ -0,0 +1,156 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/Harshal292004/subscription-service/internal/models"
+	"github.com/avast/retry-go"
+	"gorm.io/gorm"
+)
+
+// PostPrivatePlan derives a private plan from a public base plan with the
+// negotiated terms. The private plan is offered the same add-ons as its base
+// plan. It stays out of the public catalog, so the cache is left alone, but
+// the catalog version moves on since its owners now see one more plan.
+func (r *Repository) PostPrivatePlan(basePlanId int, terms models.PrivatePlanTerms) (models.Plan, error) {
+	log.Printf("[PostPrivatePlan] === Starting PostPrivatePlan from base plan ID: %d ===", basePlanId)
+	ctx := context.Background()
+
+	var base models.Plan
+	err := retry.Do(func() error {
+		return r.DB.WithContext(ctx).First(&base, basePlanId).Error
+	}, retry.Attempts(3), retry.Delay(100*time.Millisecond), retry.DelayType(retry.BackOffDelay),
+		retry.LastErrorOnly(true), retry.RetryIf(retryable))
+
+	if err != nil {
+		log.Printf("[PostPrivatePlan] Failed to fetch base plan: %v", err)
+		return models.Plan{}, err
+	}
+
+	plan, err := base.Derive(terms)
+	if err != nil {
+		log.Printf("[PostPrivatePlan] Private plan rejected: %v", err)
+		return models.Plan{}, fmt.Errorf("%w: %v", ErrInvalidPlan, err)
+	}
+	if err := r.validatePlan(plan); err != nil {
+		return models.Plan{}, err
+	}
+	if err := r.checkPlanOwner(ctx, plan); err != nil {
+		return models.Plan{}, err
+	}
+
+	err = retry.Do(func() error {
+		plan.ID = 0
+		return r.DB.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+			var taken int64
+			if err := tx.Model(&models.Plan{}).Where("code = ?", plan.Code).Count(&taken).Error; err != nil {
+				return err
+			}
+			if taken > 0 {
+				return fmt.Errorf("%w: code %q is already in use, pass another one", ErrInvalidPlan, plan.Code)
+			}
+			if err := tx.Create(&plan).Error; err != nil {
+				return err
+			}
+			return tx.Exec(
+				"INSERT INTO plan_add_ons (plan_id, add_on_id, max_quantity) SELECT ?, add_on_id, max_quantity FROM plan_add_ons WHERE plan_id = ?",
+				plan.ID, base.ID,
+			).Error
+		})
+	}, retry.Attempts(3), retry.Delay(100*time.Millisecond), retry.DelayType(retry.BackOffDelay),
+		retry.LastErrorOnly(true), retry.RetryIf(retryable))
+
+	if err != nil {
+		log.Printf("[PostPrivatePlan] Failed to create private plan: %v", err)
+		return models.Plan{}, err
+	}
+
+	r.bumpCatalogVersion(ctx)
+
+	log.Printf("[PostPrivatePlan] === Created private plan ID: %d (%s) ===", plan.ID, plan.Code)
+	return plan, nil
+}
+
+// GetPrivatePlans lists the current private plans offered to the user,
+// directly or through their organization, translated into the locale.
+func (r *Repository) GetPrivatePlans(userId int, locale string) ([]models.Plan, error) {
+	log.Printf("[GetPrivatePlans] === Starting GetPrivatePlans for user ID: %d ===", userId)
+	ctx := context.Background()
+
+	var plans []models.Plan
+	err := retry.Do(func() error {
+		return r.DB.WithContext(ctx).
+			Where("archived_at IS NULL").
+			Where("owner_user_id = ? OR organization_id = (SELECT organization_id FROM users WHERE id = ?)", userId, userId).
+			Order("id").
+			Find(&plans).Error
+	}, retry.Attempts(3), retry.Delay(100*time.Millisecond), retry.DelayType(retry.BackOffDelay), retry.LastErrorOnly(true))
+
+	if err != nil {
+		log.Printf("[GetPrivatePlans] All DB query attempts failed: %v", err)
+		return nil, err
+	}
+	if len(plans) == 0 {
+		return plans, nil
+	}
+
+	translations, err := r.getPlanTranslations(ctx, locale)
+	if err != nil {
+		return nil, err
+	}
+	models.LocalizePlans(plans, translations, locale)
+
+	log.Printf("[GetPrivatePlans] === Returning %d private plans ===", len(plans))
+	return plans, nil
+}
+
+// CheckPlanVisible hides private plans from everyone but the users they are
+// offered to, reporting them as not found.
+func (r *Repository) CheckPlanVisible(plan models.Plan, userId int) error {
+	if !plan.Private() {
+		return nil
+	}
+	if userId <= 0 {
+		return gorm.ErrRecordNotFound
+	}
+
+	var user models.User
+	if plan.OrganizationID != nil {
+		err := retry.Do(func() error {
+			return r.DB.WithContext(context.Background()).Select("id", "organization_id").First(&user, userId).Error
+		}, retry.Attempts(3), retry.Delay(100*time.Millisecond), retry.DelayType(retry.BackOffDelay),
+			retry.LastErrorOnly(true), retry.RetryIf(retryable))
+		if err != nil {
+			return err
+		}
+	}
+	if !plan.VisibleTo(uint(userId), user.OrganizationID) {
+		log.Printf("[CheckPlanVisible] Private plan ID %d is not offered to user ID %d", plan.ID, userId)
+		return gorm.ErrRecordNotFound
+	}
+	return nil
+}
+
+// checkPlanOwner makes sure the user or organization a private plan is
+// offered to exists.
+func (r *Repository) checkPlanOwner(ctx context.Context, plan models.Plan) error {
+	var err error
+	if plan.OwnerUserID != nil {
+		err = r.DB.WithContext(ctx).Select("id").First(&models.User{}, *plan.OwnerUserID).Error
+	} else {
+		err = r.DB.WithContext(ctx).Select("id").First(&models.Organization{}, *plan.OrganizationID).Error
+	}
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return fmt.Errorf("%w: the plan owner does not exist", ErrInvalidPlan)
+	}
+	return err
+}
+
+// publicPlans keeps private plans out of catalog queries.
+func publicPlans(db *gorm.DB) *gorm.DB {
+	return db.Where("owner_user_id IS NULL AND organization_id IS NULL")
+}
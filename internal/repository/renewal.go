@@ -0,0 +1,423 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/Harshal292004/subscription-service/internal/models"
+	"github.com/avast/retry-go"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// renewalLockTTL bounds how long one run of the renewal worker may hold the
+// lock before another instance can take over.
+const renewalLockTTL = 10 * time.Minute
+
+// errRenewalSuperseded reports a renewal charge collected for a period that
+// did not start because the subscription changed in the meantime.
+var errRenewalSuperseded = errors.New("subscription changed while its renewal was collected")
+
+// RenewSubscriptions starts the next billing period of up to limit
+// subscriptions that are due, the first paid one for ended trials, moving those with a scheduled plan change onto
+// the new plan, and charges each renewal with collect. The charge is
+// recorded as a renewal invoice and collected outside any transaction; the
+// period starts only once it is paid. A subscription whose payment fails
+// goes PAST_DUE under the dunning policy; past due subscriptions are picked
+// up again when their next retry is due. It returns how many subscriptions
+// were renewed.
+//
+// A Redis lock keeps the worker to one instance at a time, and each
+// subscription is locked with SKIP LOCKED while its invoice is opened so it
+// is never charged twice even if the lock expires mid-run.
+func (r *Repository) RenewSubscriptions(now time.Time, limit int, policy models.DunningPolicy, collect func(models.Renewal) error) (int, error) {
+	log.Println("[RenewSubscriptions] === Starting RenewSubscriptions ===")
+	ctx := context.Background()
+
+	release, ok, err := r.acquireLock(ctx, "renewals", renewalLockTTL)
+	if err != nil {
+		log.Printf("[RenewSubscriptions] Failed to take the renewal lock: %v", err)
+		return 0, err
+	}
+	if !ok {
+		log.Println("[RenewSubscriptions] === Another instance is renewing, skipping ===")
+		return 0, nil
+	}
+	defer release()
+
+	var ids []uint
+	err = retry.Do(func() error {
+		return dueForRenewal(r.DB.WithContext(ctx), now).
+			Model(&models.Subscription{}).
+			Order("end_date").
+			Limit(limit).
+			Pluck("id", &ids).Error
+	}, retry.Attempts(3), retry.Delay(100*time.Millisecond), retry.DelayType(retry.BackOffDelay), retry.LastErrorOnly(true))
+
+	if err != nil {
+		log.Printf("[RenewSubscriptions] Failed to find due subscriptions: %v", err)
+		return 0, err
+	}
+	log.Printf("[RenewSubscriptions] Found %d due subscriptions", len(ids))
+
+	renewed := 0
+	for _, id := range ids {
+		invoice, err := r.openRenewalInvoice(ctx, id, now)
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			continue
+		}
+		if err != nil {
+			log.Printf("[RenewSubscriptions] Failed to open renewal invoice for subscription ID %d: %v", id, err)
+			continue
+		}
+
+		if err := collect(invoice.Renewal()); err != nil {
+			log.Printf("[RenewSubscriptions] Payment for subscription ID %d failed: %v", id, err)
+			if err := r.chaseRenewal(ctx, invoice, now, policy, err); err != nil {
+				log.Printf("[RenewSubscriptions] Failed to start dunning for subscription ID %d: %v", id, err)
+			}
+			continue
+		}
+
+		sub, err := r.settleRenewal(ctx, invoice, now)
+		if errors.Is(err, errRenewalSuperseded) {
+			log.Printf("[RenewSubscriptions] Subscription ID %d: %v, refunded invoice ID %d", id, err, invoice.ID)
+			continue
+		}
+		if err != nil {
+			// The invoice stays pending and is collected again under the
+			// same idempotency key on the next run.
+			log.Printf("[RenewSubscriptions] Failed to renew subscription ID %d after payment: %v", id, err)
+			continue
+		}
+
+		ttl := time.Until(sub.EndDate)
+		if ttl <= 0 {
+			ttl = time.Hour
+		}
+		r.cacheJSON(ctx, fmt.Sprintf("%d:sub", sub.UserID), sub, ttl)
+		renewed++
+		log.Printf("[RenewSubscriptions] Renewed subscription ID %d on plan ID %d until %v", sub.ID, sub.PlanID, sub.EndDate)
+	}
+
+	log.Printf("[RenewSubscriptions] === Renewed %d subscriptions ===", renewed)
+	return renewed, nil
+}
+
+// openRenewalInvoice records the charge for the next period of a due
+// subscription, or returns the invoice already open for that period. It
+// returns gorm.ErrRecordNotFound when the subscription is no longer due or
+// is being renewed elsewhere.
+func (r *Repository) openRenewalInvoice(ctx context.Context, id uint, now time.Time) (models.RenewalInvoice, error) {
+	var invoice models.RenewalInvoice
+	err := r.DB.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		var sub models.Subscription
+		if err := dueForRenewal(tx, now).
+			Clauses(clause.Locking{Strength: "UPDATE", Options: "SKIP LOCKED"}).
+			First(&sub, id).Error; err != nil {
+			return err
+		}
+
+		plan, err := renewalPlan(tx, sub, now, false)
+		if err != nil {
+			return err
+		}
+		renewal := sub.Renew(plan)
+
+		err = tx.Where("subscription_id = ? AND period_start = ?", renewal.SubscriptionID, renewal.PeriodStart).
+			First(&invoice).Error
+		switch {
+		case errors.Is(err, gorm.ErrRecordNotFound):
+			invoice = models.NewRenewalInvoice(renewal)
+			return tx.Create(&invoice).Error
+		case err != nil:
+			return err
+		case invoice.Status == models.InvoicePending:
+			// The outcome of the last charge was lost: collecting it again
+			// under the same key charges the user at most once.
+			return nil
+		case invoice.Status == models.InvoiceFailed:
+			invoice.Reprice(renewal)
+			invoice.Status = models.InvoicePending
+			return tx.Model(&invoice).Updates(map[string]interface{}{
+				"plan_id":    invoice.PlanID,
+				"amount":     invoice.Amount,
+				"currency":   invoice.Currency,
+				"period_end": invoice.PeriodEnd,
+				"status":     invoice.Status,
+			}).Error
+		default:
+			return fmt.Errorf("renewal invoice ID %d is already %s", invoice.ID, invoice.Status)
+		}
+	})
+	return invoice, err
+}
+
+// settleRenewal starts the period a collected invoice paid for and marks it
+// paid. If the subscription no longer renews into that period, the charge
+// is refunded and errRenewalSuperseded returned.
+func (r *Repository) settleRenewal(ctx context.Context, invoice models.RenewalInvoice, now time.Time) (models.Subscription, error) {
+	var sub models.Subscription
+	err := r.DB.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+			Where("is_current AND end_date = ? AND status IN ?", invoice.PeriodStart,
+				[]models.SubscriptionStatus{models.Active, models.Trialing, models.PastDue}).
+			First(&sub, invoice.SubscriptionID).Error
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			refund := models.RenewalRefundLine(invoice)
+			if err := tx.Create(&refund).Error; err != nil {
+				return err
+			}
+			if err := tx.Model(&invoice).Update("status", models.InvoiceRefunded).Error; err != nil {
+				return err
+			}
+			return errRenewalSuperseded
+		}
+		if err != nil {
+			return err
+		}
+
+		plan, err := renewalPlan(tx, sub, now, true)
+		if err != nil {
+			return err
+		}
+		sub.Renew(plan)
+		switch sub.Status {
+		case models.PastDue:
+			err = sub.Recover(now)
+		case models.Trialing:
+			err = sub.EndTrial(now)
+		}
+		if err != nil {
+			return illegalTransition(err)
+		}
+		if err := tx.Model(&sub).Updates(map[string]interface{}{
+			"status":            sub.Status,
+			"plan_id":           sub.PlanID,
+			"price":             sub.Price,
+			"start_date":        sub.StartDate,
+			"end_date":          sub.EndDate,
+			"billing_anchor":    sub.BillingAnchor,
+			"pending_plan_id":   nil,
+			"pending_change_at": nil,
+			"past_due_since":    nil,
+			"grace_ends_at":     nil,
+			"next_retry_at":     nil,
+			"payment_retries":   0,
+		}).Error; err != nil {
+			return err
+		}
+		if err := recordTransitions(tx, sub.TakeTransitions()); err != nil {
+			return err
+		}
+		if err := detachIncompatibleAddOns(tx, sub); err != nil {
+			return err
+		}
+		if err := tx.Model(&invoice).Updates(map[string]interface{}{
+			"status":  models.InvoicePaid,
+			"paid_at": now,
+		}).Error; err != nil {
+			return err
+		}
+		return tx.Preload("AddOn").Where("subscription_id = ?", sub.ID).Find(&sub.AddOns).Error
+	})
+	return sub, err
+}
+
+// renewalPlan loads the plan a subscription renews onto. A scheduled change
+// moves it to a new plan at the price the user is offered there, keeping
+// their experiment variant; convert marks the variant as bought.
+func renewalPlan(tx *gorm.DB, sub models.Subscription, now time.Time, convert bool) (models.Plan, error) {
+	var plan models.Plan
+	if sub.PendingPlanID == nil {
+		err := tx.First(&plan, sub.PlanID).Error
+		return plan, err
+	}
+	if err := tx.First(&plan, *sub.PendingPlanID).Error; err != nil {
+		return plan, err
+	}
+	var err error
+	if convert {
+		plan.Price, err = experimentPrice(tx, plan, int(sub.UserID), now)
+	} else {
+		plan.Price, _, err = variantPrice(tx, plan, int(sub.UserID), now)
+	}
+	return plan, err
+}
+
+// chaseRenewal records a failed charge on the invoice and puts the
+// subscription into dunning, or moves it to its next retry when it already
+// is past due, and reminds the user to pay.
+func (r *Repository) chaseRenewal(ctx context.Context, invoice models.RenewalInvoice, now time.Time, policy models.DunningPolicy, cause error) error {
+	var (
+		sub    models.Subscription
+		chased bool
+	)
+	err := r.DB.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(&invoice).Updates(map[string]interface{}{
+			"status":   models.InvoiceFailed,
+			"attempts": invoice.Attempts + 1,
+			"error":    cause.Error(),
+		}).Error; err != nil {
+			return err
+		}
+
+		// A subscription changed while the payment was attempted owes
+		// nothing for this period any more.
+		err := dueForRenewal(tx, now).
+			Where("end_date = ?", invoice.PeriodStart).
+			Clauses(clause.Locking{Strength: "UPDATE"}).
+			First(&sub, invoice.SubscriptionID).Error
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+
+		var plan models.Plan
+		if err := tx.First(&plan, invoice.PlanID).Error; err != nil {
+			return err
+		}
+		if sub.Status == models.PastDue {
+			sub.RetryFailed(policy)
+		} else if err := sub.MarkPastDue(now, policy); err != nil {
+			return illegalTransition(err)
+		}
+		if err := tx.Model(&sub).Updates(map[string]interface{}{
+			"status":          sub.Status,
+			"past_due_since":  sub.PastDueSince,
+			"grace_ends_at":   sub.GraceEndsAt,
+			"next_retry_at":   sub.NextRetryAt,
+			"payment_retries": sub.PaymentRetries,
+		}).Error; err != nil {
+			return err
+		}
+		if err := recordTransitions(tx, sub.TakeTransitions()); err != nil {
+			return err
+		}
+		chased = true
+		notice := models.PaymentDueNotice(sub, plan, invoice.Amount, now)
+		return tx.Create(&notice).Error
+	})
+	if err != nil || !chased {
+		return err
+	}
+
+	r.evictSubscription(ctx, int(sub.UserID))
+	log.Printf("[chaseRenewal] Subscription ID %d is past due, retry %d, next retry at %v", sub.ID, sub.PaymentRetries, sub.NextRetryAt)
+	return nil
+}
+
+// EndDunning gives up on the past due subscriptions whose grace period is
+// over, expiring or cancelling them as the policy says, and returns how many
+// were ended.
+func (r *Repository) EndDunning(now time.Time, policy models.DunningPolicy) (int, error) {
+	log.Println("[EndDunning] === Starting EndDunning ===")
+	ctx := context.Background()
+
+	var ids []uint
+	err := retry.Do(func() error {
+		return r.DB.WithContext(ctx).Model(&models.Subscription{}).
+			Where("is_current AND status = ? AND grace_ends_at <= ?", models.PastDue, now).
+			Pluck("id", &ids).Error
+	}, retry.Attempts(3), retry.Delay(100*time.Millisecond), retry.DelayType(retry.BackOffDelay), retry.LastErrorOnly(true))
+
+	if err != nil {
+		log.Printf("[EndDunning] Failed to find exhausted dunning: %v", err)
+		return 0, err
+	}
+	log.Printf("[EndDunning] Found %d subscriptions past their grace period", len(ids))
+
+	ended := 0
+	for _, id := range ids {
+		var sub models.Subscription
+		err := r.DB.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+			// Checking again under the lock leaves alone a subscription
+			// paid by a renewal run in the meantime.
+			if err := tx.Clauses(clause.Locking{Strength: "UPDATE", Options: "SKIP LOCKED"}).
+				Where("status = ? AND grace_ends_at <= ?", models.PastDue, now).
+				First(&sub, id).Error; err != nil {
+				return err
+			}
+			if err := sub.EndDunning(now, policy); err != nil {
+				return illegalTransition(err)
+			}
+			if err := tx.Model(&sub).Updates(map[string]interface{}{
+				"status":               sub.Status,
+				"is_current":           sub.IsCurrent,
+				"cancel_at_period_end": sub.CancelAtPeriodEnd,
+				"cancelled_at":         sub.CancelledAt,
+				"end_date":             sub.EndDate,
+				"pending_plan_id":      sub.PendingPlanID,
+				"pending_change_at":    sub.PendingChangeAt,
+				"next_retry_at":        nil,
+			}).Error; err != nil {
+				return err
+			}
+			return recordTransitions(tx, sub.TakeTransitions())
+		})
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			continue
+		}
+		if err != nil {
+			log.Printf("[EndDunning] Failed to end subscription ID %d: %v", id, err)
+			continue
+		}
+
+		r.evictSubscription(ctx, int(sub.UserID))
+		ended++
+		log.Printf("[EndDunning] Subscription ID %d is now %s", sub.ID, sub.Status)
+	}
+
+	log.Printf("[EndDunning] === Ended %d subscriptions ===", ended)
+	return ended, nil
+}
+
+// dueForRenewal selects the current subscriptions that renew automatically
+// and whose paid period or free trial is over, and the past due ones whose
+// next payment retry has come. Paused subscriptions and those scheduled for
+// cancellation do not renew.
+func dueForRenewal(db *gorm.DB, now time.Time) *gorm.DB {
+	return db.Where("is_current AND auto_renew AND NOT cancel_at_period_end AND paused_at IS NULL").
+		Where("(status IN ? AND end_date <= ?) OR (status = ? AND next_retry_at <= ?)",
+			[]models.SubscriptionStatus{models.Active, models.Trialing}, now, models.PastDue, now)
+}
+
+// SetAutoRenew turns automatic renewal of the user's current subscription
+// on or off.
+func (r *Repository) SetAutoRenew(userId int, autoRenew bool) (models.Subscription, error) {
+	log.Printf("[SetAutoRenew] === Starting SetAutoRenew for user ID: %d, auto renew: %v ===", userId, autoRenew)
+	ctx := context.Background()
+
+	var sub models.Subscription
+	err := retry.Do(func() error {
+		return r.DB.WithContext(ctx).Where("user_id = ? AND is_current", userId).First(&sub).Error
+	}, retry.Attempts(3), retry.Delay(100*time.Millisecond), retry.DelayType(retry.BackOffDelay),
+		retry.LastErrorOnly(true), retry.RetryIf(retryable))
+
+	if err != nil {
+		log.Printf("[SetAutoRenew] Failed to find subscription: %v", err)
+		return models.Subscription{}, err
+	}
+	if sub.Ended() {
+		return models.Subscription{}, ErrSubscriptionNotActive
+	}
+
+	sub.AutoRenew = autoRenew
+	err = retry.Do(func() error {
+		return r.DB.WithContext(ctx).Model(&sub).Update("auto_renew", autoRenew).Error
+	}, retry.Attempts(3), retry.Delay(100*time.Millisecond), retry.DelayType(retry.BackOffDelay), retry.LastErrorOnly(true))
+
+	if err != nil {
+		log.Printf("[SetAutoRenew] Failed to update subscription: %v", err)
+		return models.Subscription{}, err
+	}
+
+	r.evictSubscription(ctx, userId)
+	log.Printf("[SetAutoRenew] === Subscription ID %d auto renew set to %v ===", sub.ID, autoRenew)
+	return sub, nil
+}
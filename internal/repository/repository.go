@@ -3,6 +3,7 @@ package repository
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"log"
 	"os"
@@ -14,6 +15,7 @@ import (
 	"github.com/redis/go-redis/v9"
 	"golang.org/x/crypto/bcrypt"
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 )
 
 type Repository struct {
@@ -68,7 +70,7 @@ func (r *Repository) GetCachedPlans() ([]models.Plan, error) {
 
 	err = retry.Do(func() error {
 		log.Println("[GetCachedPlans] Attempting DB query")
-		dbErr := r.DB.WithContext(ctx).Find(&plans).Error
+		dbErr := r.DB.WithContext(ctx).Scopes(publicPlans).Where("archived_at IS NULL").Find(&plans).Error
 		if dbErr != nil {
 			log.Printf("[GetCachedPlans] DB query attempt failed: %v", dbErr)
 		} else {
@@ -152,7 +154,7 @@ func (r *Repository) GetCachedSubscription(userId int) (models.Subscription, err
 
 	err = retry.Do(func() error {
 		log.Printf("[GetCachedSubscription] Attempting DB query for user_id = %d", userId)
-		dbErr := r.DB.WithContext(ctx).Where("user_id = ?", userId).First(&sub).Error
+		dbErr := r.DB.WithContext(ctx).Preload("AddOns.AddOn").Where("user_id = ? AND is_current", userId).First(&sub).Error
 		if dbErr != nil {
 			log.Printf("[GetCachedSubscription] DB query attempt failed: %v", dbErr)
 		} else {
@@ -160,7 +162,8 @@ func (r *Repository) GetCachedSubscription(userId int) (models.Subscription, err
 				sub.ID, sub.UserID, sub.PlanID, sub.Status)
 		}
 		return dbErr
-	}, retry.Attempts(3), retry.Delay(100*time.Millisecond), retry.DelayType(retry.BackOffDelay))
+	}, retry.Attempts(3), retry.Delay(100*time.Millisecond), retry.DelayType(retry.BackOffDelay),
+		retry.LastErrorOnly(true), retry.RetryIf(retryable))
 
 	if err != nil {
 		log.Printf("[GetCachedSubscription] All DB query attempts failed: %v", err)
@@ -172,6 +175,13 @@ func (r *Repository) GetCachedSubscription(userId int) (models.Subscription, err
 	data, marshalErr := json.Marshal(sub)
 	if marshalErr == nil {
 		ttl := time.Until(sub.EndDate)
+		if sub.Paused() {
+			// A paused subscription next changes when it resumes
+			ttl = time.Hour
+			if sub.ResumeAt != nil && time.Until(*sub.ResumeAt) < ttl {
+				ttl = time.Until(*sub.ResumeAt)
+			}
+		}
 		if ttl <= 0 {
 			ttl = time.Hour
 			log.Printf("[GetCachedSubscription] Subscription expired, using default TTL of 1 hour")
@@ -201,8 +211,51 @@ func (r *Repository) GetCachedSubscription(userId int) (models.Subscription, err
 	return sub, nil
 }
 
-func (r *Repository) PostSubscription(userId int, planId int) (models.Subscription, error) {
-	log.Printf("[PostSubscription] === Starting PostSubscription for user ID: %d, plan ID: %d ===", userId, planId)
+// GetSubscriptionHistory lists every subscription of the user, current and
+// past, most recent first.
+func (r *Repository) GetSubscriptionHistory(userId int) ([]models.Subscription, error) {
+	log.Printf("[GetSubscriptionHistory] === Starting GetSubscriptionHistory for user ID: %d ===", userId)
+	ctx := context.Background()
+
+	var subs []models.Subscription
+	err := retry.Do(func() error {
+		return r.DB.WithContext(ctx).Preload("AddOns.AddOn").
+			Where("user_id = ?", userId).
+			Order("created_at DESC, id DESC").
+			Find(&subs).Error
+	}, retry.Attempts(3), retry.Delay(100*time.Millisecond), retry.DelayType(retry.BackOffDelay), retry.LastErrorOnly(true))
+
+	if err != nil {
+		log.Printf("[GetSubscriptionHistory] All DB query attempts failed: %v", err)
+		return nil, err
+	}
+
+	log.Printf("[GetSubscriptionHistory] === Returning %d subscriptions ===", len(subs))
+	return subs, nil
+}
+
+// retireEndedSubscription moves the user's current subscription to their
+// history when it has ended, so a new one can become current. A live
+// subscription is left alone and reported with ErrAlreadySubscribed.
+func retireEndedSubscription(tx *gorm.DB, userId int) error {
+	var current models.Subscription
+	err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Where("user_id = ? AND is_current", userId).First(&current).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if !current.Ended() {
+		return ErrAlreadySubscribed
+	}
+	return tx.Model(&current).Update("is_current", false).Error
+}
+
+// PostSubscription subscribes the user to quantity seats of the plan. A
+// quantity of zero takes the plan's minimum.
+func (r *Repository) PostSubscription(userId int, planId int, quantity int) (models.Subscription, error) {
+	log.Printf("[PostSubscription] === Starting PostSubscription for user ID: %d, plan ID: %d, quantity: %d ===", userId, planId, quantity)
 	ctx := context.Background()
 	key := fmt.Sprintf("%d:sub", userId)
 	log.Printf("[PostSubscription] Will use Redis key: %s", key)
@@ -215,44 +268,104 @@ func (r *Repository) PostSubscription(userId int, planId int) (models.Subscripti
 		if dbErr != nil {
 			log.Printf("[PostSubscription] Plan fetch attempt failed: %v", dbErr)
 		} else {
-			log.Printf("[PostSubscription] Plan fetched successfully: ID=%d, Name=%s, Duration=%d days",
-				plan.ID, plan.Name, plan.Duration)
+			unit, count := plan.Interval()
+			log.Printf("[PostSubscription] Plan fetched successfully: ID=%d, Name=%s, Interval=%d %s",
+				plan.ID, plan.Name, count, unit)
 		}
 		return dbErr
-	}, retry.Attempts(3), retry.Delay(100*time.Millisecond), retry.DelayType(retry.BackOffDelay))
+	}, retry.Attempts(3), retry.Delay(100*time.Millisecond), retry.DelayType(retry.BackOffDelay),
+		retry.LastErrorOnly(true), retry.RetryIf(retryable))
 
 	if err != nil {
 		log.Printf("[PostSubscription] Failed to fetch plan after retries: %v", err)
 		log.Println("[PostSubscription] === Returning error ===")
 		return models.Subscription{}, err
 	}
+	if err := r.CheckPlanVisible(plan, userId); err != nil {
+		return models.Subscription{}, err
+	}
+	now := time.Now()
+	if !plan.Purchasable(now) {
+		log.Printf("[PostSubscription] Plan ID %d is archived or outside its availability window", plan.ID)
+		return models.Subscription{}, ErrPlanUnavailable
+	}
+	if quantity == 0 {
+		quantity = max(plan.MinQuantity, 1)
+	}
+	if err := plan.CheckQuantity(quantity); err != nil {
+		log.Printf("[PostSubscription] Rejected quantity %d: %v", quantity, err)
+		return models.Subscription{}, fmt.Errorf("%w: %v", ErrInvalidQuantity, err)
+	}
 	log.Printf("[PostSubscription] The plan is %v", plan)
 	// Create subscription
-	now := time.Now()
-	log.Printf("[PostSubscription] The plan duration is %v", plan.Duration)
-	end := now.AddDate(0, 0, plan.Duration)
+	end := plan.PeriodEnd(now, 1)
 	log.Printf("[PostSubscription] Creating subscription: Start=%v, End=%v", now, end)
 
 	sub := models.Subscription{
-		UserID:    uint(userId),
-		PlanID:    uint(planId),
-		Status:    models.Active,
-		StartDate: now,
-		EndDate:   end,
+		UserID:        uint(userId),
+		PlanID:        uint(planId),
+		Price:         plan.Price,
+		Quantity:      quantity,
+		StartDate:     now,
+		EndDate:       end,
+		BillingAnchor: now,
+		IsCurrent:     true,
+		AutoRenew:     true,
 	}
 
-	log.Printf("[PostSubscription] Subscription object created: UserID=%d, PlanID=%d, Status=%v",
-		sub.UserID, sub.PlanID, sub.Status)
+	log.Printf("[PostSubscription] Subscription object created: UserID=%d, PlanID=%d",
+		sub.UserID, sub.PlanID)
 
 	err = retry.Do(func() error {
-		createErr := r.DB.WithContext(ctx).Create(&sub).Error
+		// Start from the paid terms on every attempt; a rolled back attempt
+		// must not leave the trial applied.
+		sub.Status, sub.EndDate, sub.BillingAnchor, sub.TrialEnd = "", end, now, nil
+		sub.TakeTransitions()
+		createErr := r.DB.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+			if err := retireEndedSubscription(tx, userId); err != nil {
+				return err
+			}
+			if err := claimPlace(tx, plan.ID); err != nil {
+				return err
+			}
+			// Lock in the price the user was shown, including an experiment variant
+			price, err := experimentPrice(tx, plan, userId, now)
+			if err != nil {
+				return err
+			}
+			sub.Price = price
+			if plan.TrialDays > 0 {
+				claimed, claimErr := claimTrial(tx, userId, now)
+				if claimErr != nil {
+					return claimErr
+				}
+				if claimed {
+					if err := sub.StartTrial(plan, now); err != nil {
+						return illegalTransition(err)
+					}
+					log.Printf("[PostSubscription] Trial granted until %v", *sub.TrialEnd)
+				} else {
+					log.Printf("[PostSubscription] User ID %d already used a trial, subscribing without one", userId)
+				}
+			}
+			if sub.Status == "" {
+				if err := sub.Transition(models.Active, models.ActorUser, "subscribed", now); err != nil {
+					return illegalTransition(err)
+				}
+			}
+			if err := tx.Create(&sub).Error; err != nil {
+				return err
+			}
+			return recordTransitions(tx, sub.TakeTransitions())
+		})
 		if createErr != nil {
 			log.Printf("[PostSubscription] DB create attempt failed: %v", createErr)
 		} else {
 			log.Printf("[PostSubscription] Subscription created successfully with ID: %d", sub.ID)
 		}
 		return createErr
-	}, retry.Attempts(3), retry.Delay(100*time.Millisecond), retry.DelayType(retry.BackOffDelay))
+	}, retry.Attempts(3), retry.Delay(100*time.Millisecond), retry.DelayType(retry.BackOffDelay),
+		retry.LastErrorOnly(true), retry.RetryIf(retryable))
 
 	if err != nil {
 		log.Printf("[PostSubscription] Failed to create subscription after retries: %v", err)
@@ -263,7 +376,7 @@ func (r *Repository) PostSubscription(userId int, planId int) (models.Subscripti
 	// Cache the subscription
 	data, marshalErr := json.Marshal(sub)
 	if marshalErr == nil {
-		ttl := time.Until(end)
+		ttl := time.Until(sub.EndDate)
 		if ttl <= 0 {
 			ttl = time.Hour
 			log.Printf("[PostSubscription] Subscription expired, using default TTL of 1 hour")
@@ -293,161 +406,371 @@ func (r *Repository) PostSubscription(userId int, planId int) (models.Subscripti
 	return sub, nil
 }
 
-func (r *Repository) DeleteSubscription(userId int) (models.Subscription, error) {
-	log.Printf("[DeleteSubscription] === Starting DeleteSubscription for user ID: %d ===", userId)
+// DeleteSubscription cancels the user's current subscription. By default it
+// runs until the end of the paid period; an immediate cancellation ends it
+// now and, when asked, refunds the unused part of the period.
+func (r *Repository) DeleteSubscription(userId int, cancel models.Cancellation) (models.SubscriptionCancellation, error) {
+	log.Printf("[DeleteSubscription] === Starting DeleteSubscription for user ID: %d, mode: %q ===", userId, cancel.Mode)
 	ctx := context.Background()
-	key := fmt.Sprintf("%d:sub", userId)
-	log.Printf("[DeleteSubscription] Using Redis key: %s", key)
+
+	if err := cancel.Validate(); err != nil {
+		return models.SubscriptionCancellation{}, fmt.Errorf("%w: %v", ErrInvalidCancellation, err)
+	}
 
 	// First, get the subscription
 	log.Printf("[DeleteSubscription] Fetching existing subscription for user ID: %d", userId)
 	var sub models.Subscription
 	err := retry.Do(func() error {
-		dbErr := r.DB.WithContext(ctx).Where("user_id = ?", userId).First(&sub).Error
+		dbErr := r.DB.WithContext(ctx).Where("user_id = ? AND is_current", userId).First(&sub).Error
 		if dbErr != nil {
 			log.Printf("[DeleteSubscription] Subscription fetch attempt failed: %v", dbErr)
 		} else {
 			log.Printf("[DeleteSubscription] Found subscription: ID=%d, Status=%v", sub.ID, sub.Status)
 		}
 		return dbErr
-	}, retry.Attempts(3), retry.Delay(100*time.Millisecond), retry.DelayType(retry.BackOffDelay))
+	}, retry.Attempts(3), retry.Delay(100*time.Millisecond), retry.DelayType(retry.BackOffDelay),
+		retry.LastErrorOnly(true), retry.RetryIf(retryable))
 
 	if err != nil {
 		log.Printf("[DeleteSubscription] Failed to find subscription: %v", err)
 		log.Println("[DeleteSubscription] === Returning error ===")
-		return models.Subscription{}, err
+		return models.SubscriptionCancellation{}, err
+	}
+	if sub.Ended() {
+		return models.SubscriptionCancellation{}, ErrSubscriptionNotActive
 	}
 
-	// Update status to cancelled
-	log.Printf("[DeleteSubscription] Updating subscription ID %d status to Cancelled", sub.ID)
-	sub.Status = models.Cancelled
+	if sub.Paused() && !cancel.Immediate() {
+		// A paused subscription has no period running to the end of
+		log.Printf("[DeleteSubscription] Subscription ID %d is paused, cancelling now", sub.ID)
+		cancel.Mode = models.CancelImmediately
+	}
 
-	err = retry.Do(func() error {
-		saveErr := r.DB.WithContext(ctx).Save(&sub).Error
-		if saveErr != nil {
-			log.Printf("[DeleteSubscription] Save attempt failed: %v", saveErr)
-		} else {
-			log.Printf("[DeleteSubscription] Successfully updated subscription status to: %v", sub.Status)
+	now := time.Now()
+	result := models.SubscriptionCancellation{}
+	if !cancel.Immediate() {
+		if sub.CancelAtPeriodEnd {
+			log.Printf("[DeleteSubscription] Subscription ID %d is already cancelled at period end", sub.ID)
+			result.Subscription = sub
+			return result, nil
 		}
-		return saveErr
-	}, retry.Attempts(3), retry.Delay(100*time.Millisecond), retry.DelayType(retry.BackOffDelay))
+		log.Printf("[DeleteSubscription] Scheduling cancellation of subscription ID %d at %v", sub.ID, sub.EndDate)
+		sub.ScheduleCancellation(now)
+		err = retry.Do(func() error {
+			return r.DB.WithContext(ctx).Model(&sub).Updates(map[string]interface{}{
+				"cancel_at_period_end": true,
+				"cancelled_at":         sub.CancelledAt,
+				"pending_plan_id":      nil,
+				"pending_change_at":    nil,
+			}).Error
+		}, retry.Attempts(3), retry.Delay(100*time.Millisecond), retry.DelayType(retry.BackOffDelay), retry.LastErrorOnly(true))
+	} else {
+		if cancel.Refund {
+			var plan models.Plan
+			if err := r.DB.WithContext(ctx).First(&plan, sub.PlanID).Error; err != nil {
+				log.Printf("[DeleteSubscription] Failed to fetch plan ID %d: %v", sub.PlanID, err)
+				return models.SubscriptionCancellation{}, err
+			}
+			if line := models.RefundLine(sub, plan, now, prorationGranularity()); line.Amount < 0 {
+				result.Refund = &line
+			}
+		}
+
+		log.Printf("[DeleteSubscription] Cancelling subscription ID %d now", sub.ID)
+		if err := sub.Cancel(now, models.ActorUser, "cancelled by user"); err != nil {
+			return models.SubscriptionCancellation{}, illegalTransition(err)
+		}
+		transitions := sub.TakeTransitions()
+		err = retry.Do(func() error {
+			return r.DB.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+				if err := tx.Model(&sub).Updates(map[string]interface{}{
+					"status":               sub.Status,
+					"is_current":           sub.IsCurrent,
+					"cancel_at_period_end": sub.CancelAtPeriodEnd,
+					"cancelled_at":         sub.CancelledAt,
+					"end_date":             sub.EndDate,
+					"pending_plan_id":      nil,
+					"pending_change_at":    nil,
+				}).Error; err != nil {
+					return err
+				}
+				if err := recordTransitions(tx, transitions); err != nil {
+					return err
+				}
+				if result.Refund == nil {
+					return nil
+				}
+				result.Refund.ID = 0
+				return tx.Create(result.Refund).Error
+			})
+		}, retry.Attempts(3), retry.Delay(100*time.Millisecond), retry.DelayType(retry.BackOffDelay), retry.LastErrorOnly(true))
+	}
 
 	if err != nil {
-		log.Printf("[DeleteSubscription] Failed to update subscription status: %v", err)
+		log.Printf("[DeleteSubscription] Failed to cancel subscription: %v", err)
 		log.Println("[DeleteSubscription] === Returning error ===")
-		return models.Subscription{}, err
+		return models.SubscriptionCancellation{}, err
 	}
 
-	// Delete the subscription
-	log.Printf("[DeleteSubscription] Deleting subscription ID: %d", sub.ID)
-	err = retry.Do(func() error {
-		deleteErr := r.DB.WithContext(ctx).Delete(&models.Subscription{}, sub.ID).Error
-		if deleteErr != nil {
-			log.Printf("[DeleteSubscription] Delete attempt failed: %v", deleteErr)
-		} else {
-			log.Printf("[DeleteSubscription] Successfully deleted subscription ID: %d", sub.ID)
+	r.evictSubscription(ctx, userId)
+	result.Subscription = sub
+
+	log.Printf("[DeleteSubscription] === Successfully cancelled subscription for user ID: %d ===", userId)
+	return result, nil
+}
+
+// UndoCancellation keeps the user's current subscription running when it is
+// scheduled to be cancelled at the end of its period.
+func (r *Repository) UndoCancellation(userId int) (models.Subscription, error) {
+	log.Printf("[UndoCancellation] === Starting UndoCancellation for user ID: %d ===", userId)
+	ctx := context.Background()
+
+	var sub models.Subscription
+	err := r.DB.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+			Where("user_id = ? AND is_current", userId).First(&sub).Error; err != nil {
+			return err
 		}
-		return deleteErr
-	}, retry.Attempts(3), retry.Delay(100*time.Millisecond), retry.DelayType(retry.BackOffDelay))
+		if !sub.CancelAtPeriodEnd || sub.Ended() {
+			return ErrNoPendingCancellation
+		}
+		sub.UndoCancellation()
+		return tx.Model(&sub).Updates(map[string]interface{}{
+			"cancel_at_period_end": false,
+			"cancelled_at":         nil,
+		}).Error
+	})
 
 	if err != nil {
-		log.Printf("[DeleteSubscription] Failed to delete subscription: %v", err)
-		log.Println("[DeleteSubscription] === Returning error ===")
+		log.Printf("[UndoCancellation] Failed to undo cancellation: %v", err)
 		return models.Subscription{}, err
 	}
 
-	// Remove from cache
-	log.Printf("[DeleteSubscription] Removing subscription from Redis cache with key: %s", key)
-	cacheErr := retry.Do(func() error {
-		delErr := r.Redis.Del(ctx, key).Err()
-		if delErr != nil {
-			log.Printf("[DeleteSubscription] Redis DEL attempt failed: %v", delErr)
-		} else {
-			log.Println("[DeleteSubscription] Successfully removed from Redis cache")
+	r.evictSubscription(ctx, userId)
+	log.Printf("[UndoCancellation] === Subscription ID %d keeps running ===", sub.ID)
+	return sub, nil
+}
+
+// EndCancelledSubscriptions cancels every subscription scheduled for
+// cancellation whose paid period is over and returns how many were cancelled.
+func (r *Repository) EndCancelledSubscriptions(now time.Time) (int, error) {
+	log.Println("[EndCancelledSubscriptions] === Starting EndCancelledSubscriptions ===")
+	ctx := context.Background()
+
+	var subs []models.Subscription
+	err := retry.Do(func() error {
+		return r.DB.WithContext(ctx).
+			Where("is_current AND cancel_at_period_end AND end_date <= ? AND paused_at IS NULL", now).
+			Find(&subs).Error
+	}, retry.Attempts(3), retry.Delay(100*time.Millisecond), retry.DelayType(retry.BackOffDelay), retry.LastErrorOnly(true))
+
+	if err != nil {
+		log.Printf("[EndCancelledSubscriptions] Failed to find due cancellations: %v", err)
+		return 0, err
+	}
+	log.Printf("[EndCancelledSubscriptions] Found %d due cancellations", len(subs))
+
+	cancelled := 0
+	for _, sub := range subs {
+		if err := sub.Cancel(now, models.ActorSystem, "cancelled at the end of the paid period"); err != nil {
+			log.Printf("[EndCancelledSubscriptions] Cannot cancel subscription ID %d: %v", sub.ID, err)
+			continue
+		}
+		err := r.DB.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+			// The guard keeps a cancellation undone in the meantime from
+			// being applied.
+			result := tx.Model(&models.Subscription{}).
+				Where("id = ? AND is_current AND cancel_at_period_end", sub.ID).
+				Updates(map[string]interface{}{
+					"status":               sub.Status,
+					"is_current":           sub.IsCurrent,
+					"cancel_at_period_end": sub.CancelAtPeriodEnd,
+					"pending_plan_id":      nil,
+					"pending_change_at":    nil,
+				})
+			if result.Error != nil {
+				return result.Error
+			}
+			if result.RowsAffected == 0 {
+				return gorm.ErrRecordNotFound
+			}
+			return recordTransitions(tx, sub.TakeTransitions())
+		})
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			continue
+		}
+		if err != nil {
+			log.Printf("[EndCancelledSubscriptions] Failed to cancel subscription ID %d: %v", sub.ID, err)
+			continue
 		}
-		return delErr
-	}, retry.Attempts(3), retry.Delay(100*time.Millisecond), retry.DelayType(retry.BackOffDelay))
 
-	if cacheErr != nil {
-		log.Printf("[DeleteSubscription] Failed to remove from cache (non-critical): %v", cacheErr)
+		r.evictSubscription(ctx, int(sub.UserID))
+		cancelled++
+		log.Printf("[EndCancelledSubscriptions] Cancelled subscription ID %d at the end of its period", sub.ID)
 	}
 
-	log.Printf("[DeleteSubscription] === Successfully deleted subscription for user ID: %d ===", userId)
-	return sub, nil
+	log.Printf("[EndCancelledSubscriptions] === Cancelled %d subscriptions ===", cancelled)
+	return cancelled, nil
 }
 
-func (r *Repository) PutSubscription(userId int, newPlanId int) (models.Subscription, error) {
+// PutSubscription moves the user's subscription to another plan following
+// the transition policy between the two plans: the change applies right away
+// or is scheduled for the end of the paid period. timing may ask for an
+// immediate change to wait for the end of the period instead, but never for a
+// scheduled one to apply now.
+func (r *Repository) PutSubscription(userId int, newPlanId int, timing models.ChangeTiming) (models.PlanChange, error) {
 	log.Printf("[PutSubscription] === Starting PutSubscription for user ID: %d, new plan ID: %d ===", userId, newPlanId)
 	ctx := context.Background()
 	key := fmt.Sprintf("%d:sub", userId)
 	log.Printf("[PutSubscription] Using Redis key: %s", key)
 
-	// Get existing subscription
-	log.Printf("[PutSubscription] Fetching existing subscription for user ID: %d", userId)
-	var sub models.Subscription
-	err := retry.Do(func() error {
-		dbErr := r.DB.WithContext(ctx).Where("user_id = ?", userId).First(&sub).Error
-		if dbErr != nil {
-			log.Printf("[PutSubscription] Subscription fetch attempt failed: %v", dbErr)
-		} else {
-			log.Printf("[PutSubscription] Found subscription: ID=%d, Current PlanID=%d, Status=%v",
-				sub.ID, sub.PlanID, sub.Status)
-		}
-		return dbErr
-	}, retry.Attempts(3), retry.Delay(100*time.Millisecond), retry.DelayType(retry.BackOffDelay))
-
-	if err != nil {
-		log.Printf("[PutSubscription] Failed to find existing subscription: %v", err)
-		log.Println("[PutSubscription] === Returning error ===")
-		return models.Subscription{}, err
-	}
-
 	// Get new plan
 	log.Printf("[PutSubscription] Fetching new plan with ID: %d", newPlanId)
 	var newPlan models.Plan
-	err = retry.Do(func() error {
+	err := retry.Do(func() error {
 		dbErr := r.DB.WithContext(ctx).First(&newPlan, newPlanId).Error
 		if dbErr != nil {
 			log.Printf("[PutSubscription] New plan fetch attempt failed: %v", dbErr)
 		} else {
-			log.Printf("[PutSubscription] New plan fetched: ID=%d, Name=%s, Duration=%d days",
-				newPlan.ID, newPlan.Name, newPlan.Duration)
+			unit, count := newPlan.Interval()
+			log.Printf("[PutSubscription] New plan fetched: ID=%d, Name=%s, Interval=%d %s",
+				newPlan.ID, newPlan.Name, count, unit)
 		}
 		return dbErr
-	}, retry.Attempts(3), retry.Delay(100*time.Millisecond), retry.DelayType(retry.BackOffDelay))
+	}, retry.Attempts(3), retry.Delay(100*time.Millisecond), retry.DelayType(retry.BackOffDelay),
+		retry.LastErrorOnly(true), retry.RetryIf(retryable))
 
 	if err != nil {
 		log.Printf("[PutSubscription] Failed to fetch new plan: %v", err)
 		log.Println("[PutSubscription] === Returning error ===")
-		return models.Subscription{}, err
+		return models.PlanChange{}, err
+	}
+	if err := r.CheckPlanVisible(newPlan, userId); err != nil {
+		return models.PlanChange{}, err
+	}
+	if !newPlan.Purchasable(time.Now()) {
+		log.Printf("[PutSubscription] Plan ID %d is archived or outside its availability window", newPlan.ID)
+		return models.PlanChange{}, ErrPlanUnavailable
 	}
 
-	// Update subscription
+	// The subscription is read and written under a row lock: renewals,
+	// dunning, pauses, cancellations and seat changes update the same row.
+	var (
+		sub        models.Subscription
+		transition models.PlanTransition
+		lines      []models.BillingLineItem
+	)
 	now := time.Now()
-	newEndDate := now.AddDate(0, 0, newPlan.Duration)
+	err = retry.Do(func() error {
+		return r.DB.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+			var err error
+			sub, err = lockCurrentSubscription(tx, userId)
+			if err != nil {
+				return err
+			}
+			log.Printf("[PutSubscription] Locked subscription: ID=%d, Current PlanID=%d, Status=%v",
+				sub.ID, sub.PlanID, sub.Status)
+			if sub.CancelAtPeriodEnd {
+				log.Printf("[PutSubscription] Subscription ID %d is scheduled for cancellation", sub.ID)
+				return ErrCancellationPending
+			}
+			if sub.Paused() || sub.Status == models.PastDue {
+				log.Printf("[PutSubscription] Subscription ID %d is %s", sub.ID, sub.Status)
+				return ErrSubscriptionNotActive
+			}
+			if sub.PlanID == newPlan.ID {
+				log.Printf("[PutSubscription] User %d is already on plan ID %d", userId, newPlan.ID)
+				return fmt.Errorf("%w: already subscribed to this plan", ErrTransitionNotAllowed)
+			}
+			if err := newPlan.CheckQuantity(sub.Quantity); err != nil {
+				log.Printf("[PutSubscription] Subscription ID %d has %d seats: %v", sub.ID, sub.Quantity, err)
+				return fmt.Errorf("%w: %v", ErrInvalidQuantity, err)
+			}
 
-	log.Printf("[PutSubscription] Updating subscription: Old PlanID=%d -> New PlanID=%d", sub.PlanID, newPlanId)
-	log.Printf("[PutSubscription] New dates: Start=%v, End=%v", now, newEndDate)
+			// Check the transition policy before the subscription is modified
+			var currentPlan models.Plan
+			if err := tx.First(&currentPlan, sub.PlanID).Error; err != nil {
+				log.Printf("[PutSubscription] Failed to fetch current plan: %v", err)
+				return err
+			}
+			transition, err = r.resolveTransition(ctx, currentPlan, newPlan)
+			if err != nil {
+				log.Printf("[PutSubscription] Failed to resolve transition: %v", err)
+				return err
+			}
+			log.Printf("[PutSubscription] Transition %s -> %s: kind=%s, timing=%s, prorate=%v",
+				currentPlan.Code, newPlan.Code, transition.Kind, transition.Timing, transition.Prorate)
+			if !transition.Allowed() {
+				return fmt.Errorf("%w: changing from %s to %s is forbidden",
+					ErrTransitionNotAllowed, currentPlan.Name, newPlan.Name)
+			}
+			if timing == models.ChangeAtPeriodEnd {
+				transition.Timing = models.ChangeAtPeriodEnd
+			}
 
-	sub.PlanID = uint(newPlanId)
-	sub.Status = models.Active
-	sub.StartDate = now
-	sub.EndDate = newEndDate
+			// Update subscription
+			before := sub
+			if transition.Timing == models.ChangeAtPeriodEnd && sub.EndDate.After(now) {
+				log.Printf("[PutSubscription] Scheduling change to plan ID %d at %v", newPlan.ID, sub.EndDate)
+				sub.SchedulePlanChange(newPlan.ID, sub.EndDate)
+			} else {
+				log.Printf("[PutSubscription] Updating subscription: Old PlanID=%d -> New PlanID=%d", sub.PlanID, newPlanId)
+				if err := sub.ChangePlan(currentPlan, newPlan, transition, now, models.ActorUser); err != nil {
+					return illegalTransition(err)
+				}
+				log.Printf("[PutSubscription] New dates: Start=%v, End=%v", sub.StartDate, sub.EndDate)
+			}
 
-	err = retry.Do(func() error {
-		saveErr := r.DB.WithContext(ctx).Save(&sub).Error
-		if saveErr != nil {
-			log.Printf("[PutSubscription] Save attempt failed: %v", saveErr)
-		} else {
-			log.Printf("[PutSubscription] Successfully updated subscription: ID=%d, PlanID=%d", sub.ID, sub.PlanID)
-		}
-		return saveErr
-	}, retry.Attempts(3), retry.Delay(100*time.Millisecond), retry.DelayType(retry.BackOffDelay))
+			// Moving onto a capped plan takes a place, even when scheduled
+			if err := claimPlace(tx, newPlan.ID); err != nil {
+				return err
+			}
+			if sub.PlanID == newPlan.ID {
+				price, err := experimentPrice(tx, newPlan, userId, now)
+				if err != nil {
+					return err
+				}
+				sub.Price = price
+			}
+			err = tx.Model(&sub).Updates(map[string]interface{}{
+				"plan_id":           sub.PlanID,
+				"price":             sub.Price,
+				"status":            sub.Status,
+				"start_date":        sub.StartDate,
+				"end_date":          sub.EndDate,
+				"billing_anchor":    sub.BillingAnchor,
+				"trial_end":         sub.TrialEnd,
+				"pending_plan_id":   sub.PendingPlanID,
+				"pending_change_at": sub.PendingChangeAt,
+			}).Error
+			if err != nil {
+				return err
+			}
+			if err := recordTransitions(tx, sub.TakeTransitions()); err != nil {
+				return err
+			}
+			lines = nil
+			if sub.PlanID == newPlan.ID && transition.Prorate {
+				lines = models.Proration(before, currentPlan, sub, newPlan, now, prorationGranularity())
+				if len(lines) > 0 {
+					if err := tx.Create(&lines).Error; err != nil {
+						return err
+					}
+				}
+			}
+			// Add-ons not offered on the new plan are dropped with the change
+			if err := detachIncompatibleAddOns(tx, sub); err != nil {
+				return err
+			}
+			return tx.Preload("AddOn").Where("subscription_id = ?", sub.ID).Find(&sub.AddOns).Error
+		})
+	}, retry.Attempts(3), retry.Delay(100*time.Millisecond), retry.DelayType(retry.BackOffDelay),
+		retry.LastErrorOnly(true), retry.RetryIf(retryable))
 
 	if err != nil {
 		log.Printf("[PutSubscription] Failed to update subscription: %v", err)
 		log.Println("[PutSubscription] === Returning error ===")
-		return models.Subscription{}, err
+		return models.PlanChange{}, err
 	}
 
 	// Update cache
@@ -480,7 +803,13 @@ func (r *Repository) PutSubscription(userId int, newPlanId int) (models.Subscrip
 	}
 
 	log.Printf("[PutSubscription] === Successfully updated subscription for user ID: %d ===", userId)
-	return sub, nil
+	return models.PlanChange{Subscription: sub, Transition: transition, LineItems: lines, AmountDue: models.AmountDue(lines)}, nil
+}
+
+// prorationGranularity is how finely unused time is measured for prorations
+// and refunds, set with PRORATION_GRANULARITY (second or day).
+func prorationGranularity() models.ProrationGranularity {
+	return models.ParseProrationGranularity(os.Getenv("PRORATION_GRANULARITY"))
 }
 
 func (r *Repository) PostUser(name string, password string) (string, error) {
@@ -0,0 +1,218 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/Harshal292004/subscription-service/internal/models"
+	"github.com/avast/retry-go"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// ChangeQuantity sets the number of seats on the user's subscription within
+// the bounds of its plan, charging the added seats or crediting the removed
+// ones for the rest of the current period. Seats cannot drop below the
+// number assigned.
+func (r *Repository) ChangeQuantity(userId int, quantity int) (models.QuantityChange, error) {
+	log.Printf("[ChangeQuantity] === Starting ChangeQuantity for user ID: %d, quantity: %d ===", userId, quantity)
+	ctx := context.Background()
+	now := time.Now()
+
+	var change models.QuantityChange
+	err := retry.Do(func() error {
+		change = models.QuantityChange{}
+		return r.DB.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+			sub, err := lockCurrentSubscription(tx, userId)
+			if err != nil {
+				return err
+			}
+			if sub.Ended() || sub.Paused() || sub.Status == models.PastDue {
+				return ErrSubscriptionNotActive
+			}
+			if sub.CancelAtPeriodEnd {
+				return ErrCancellationPending
+			}
+
+			var plan models.Plan
+			if err := tx.First(&plan, sub.PlanID).Error; err != nil {
+				return err
+			}
+			before := sub
+			if err := sub.ChangeQuantity(plan, quantity); err != nil {
+				return fmt.Errorf("%w: %v", ErrInvalidQuantity, err)
+			}
+			if sub.PendingPlanID != nil {
+				var pending models.Plan
+				if err := tx.First(&pending, *sub.PendingPlanID).Error; err != nil {
+					return err
+				}
+				if err := pending.CheckQuantity(quantity); err != nil {
+					return fmt.Errorf("%w: scheduled plan change: %v", ErrInvalidQuantity, err)
+				}
+			}
+
+			var assigned int64
+			if err := tx.Model(&models.SeatAssignment{}).Where("subscription_id = ?", sub.ID).Count(&assigned).Error; err != nil {
+				return err
+			}
+			if int64(quantity) < assigned {
+				return fmt.Errorf("%w: %d seats are assigned", ErrSeatsInUse, assigned)
+			}
+
+			change.Subscription = sub
+			if quantity == before.Quantity {
+				return nil
+			}
+			if err := tx.Model(&sub).Update("quantity", quantity).Error; err != nil {
+				return err
+			}
+			change.LineItems = models.SeatProration(before, sub, plan, now, prorationGranularity())
+			if len(change.LineItems) > 0 {
+				if err := tx.Create(&change.LineItems).Error; err != nil {
+					return err
+				}
+			}
+			change.AmountDue = models.AmountDue(change.LineItems)
+			return nil
+		})
+	}, retry.Attempts(3), retry.Delay(100*time.Millisecond), retry.DelayType(retry.BackOffDelay),
+		retry.LastErrorOnly(true), retry.RetryIf(retryable))
+
+	if err != nil {
+		log.Printf("[ChangeQuantity] Failed to change quantity: %v", err)
+		return models.QuantityChange{}, err
+	}
+
+	r.evictSubscription(ctx, userId)
+	log.Printf("[ChangeQuantity] === Subscription ID %d now has %d seats, amount due %.2f ===",
+		change.ID, change.Quantity, change.AmountDue)
+	return change, nil
+}
+
+// GetSeatUsage reports the seats bought on the user's current subscription
+// and who they are assigned to.
+func (r *Repository) GetSeatUsage(userId int) (models.SeatUsage, error) {
+	log.Printf("[GetSeatUsage] === Starting GetSeatUsage for user ID: %d ===", userId)
+	ctx := context.Background()
+
+	var usage models.SeatUsage
+	err := retry.Do(func() error {
+		var sub models.Subscription
+		if err := r.DB.WithContext(ctx).Where("user_id = ? AND is_current", userId).First(&sub).Error; err != nil {
+			return err
+		}
+		var err error
+		usage, err = seatUsage(r.DB.WithContext(ctx), sub)
+		return err
+	}, retry.Attempts(3), retry.Delay(100*time.Millisecond), retry.DelayType(retry.BackOffDelay),
+		retry.LastErrorOnly(true), retry.RetryIf(retryable))
+
+	if err != nil {
+		log.Printf("[GetSeatUsage] Failed to get seat usage: %v", err)
+		return models.SeatUsage{}, err
+	}
+	return usage, nil
+}
+
+// AssignSeat gives a seat on the user's subscription to a member. Assigning
+// a member who already holds a seat changes nothing; assigning beyond the
+// seats bought is refused.
+func (r *Repository) AssignSeat(userId int, memberId int) (models.SeatUsage, error) {
+	log.Printf("[AssignSeat] === Starting AssignSeat for user ID: %d, member ID: %d ===", userId, memberId)
+	ctx := context.Background()
+
+	var usage models.SeatUsage
+	err := retry.Do(func() error {
+		return r.DB.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+			// Locking the subscription serializes assignments, so two
+			// requests cannot both take the last seat.
+			sub, err := lockCurrentSubscription(tx, userId)
+			if err != nil {
+				return err
+			}
+			if sub.Ended() {
+				return ErrSubscriptionNotActive
+			}
+			if err := tx.First(&models.User{}, memberId).Error; err != nil {
+				return err
+			}
+			if usage, err = seatUsage(tx, sub); err != nil {
+				return err
+			}
+			for _, assignment := range usage.Assignments {
+				if assignment.UserID == uint(memberId) {
+					return nil
+				}
+			}
+			if usage.Available == 0 {
+				return fmt.Errorf("%w: %d of %d seats in use", ErrNoSeatAvailable, usage.Assigned, usage.Quantity)
+			}
+			assignment := models.SeatAssignment{SubscriptionID: sub.ID, UserID: uint(memberId)}
+			if err := tx.Create(&assignment).Error; err != nil {
+				return err
+			}
+			usage, err = seatUsage(tx, sub)
+			return err
+		})
+	}, retry.Attempts(3), retry.Delay(100*time.Millisecond), retry.DelayType(retry.BackOffDelay),
+		retry.LastErrorOnly(true), retry.RetryIf(retryable))
+
+	if err != nil {
+		log.Printf("[AssignSeat] Failed to assign seat: %v", err)
+		return models.SeatUsage{}, err
+	}
+	log.Printf("[AssignSeat] === %d of %d seats assigned ===", usage.Assigned, usage.Quantity)
+	return usage, nil
+}
+
+// UnassignSeat frees the seat a member holds on the user's subscription.
+func (r *Repository) UnassignSeat(userId int, memberId int) (models.SeatUsage, error) {
+	log.Printf("[UnassignSeat] === Starting UnassignSeat for user ID: %d, member ID: %d ===", userId, memberId)
+	ctx := context.Background()
+
+	var usage models.SeatUsage
+	err := retry.Do(func() error {
+		return r.DB.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+			sub, err := lockCurrentSubscription(tx, userId)
+			if err != nil {
+				return err
+			}
+			result := tx.Where("subscription_id = ? AND user_id = ?", sub.ID, memberId).Delete(&models.SeatAssignment{})
+			if result.Error != nil {
+				return result.Error
+			}
+			if result.RowsAffected == 0 {
+				return gorm.ErrRecordNotFound
+			}
+			usage, err = seatUsage(tx, sub)
+			return err
+		})
+	}, retry.Attempts(3), retry.Delay(100*time.Millisecond), retry.DelayType(retry.BackOffDelay),
+		retry.LastErrorOnly(true), retry.RetryIf(retryable))
+
+	if err != nil {
+		log.Printf("[UnassignSeat] Failed to unassign seat: %v", err)
+		return models.SeatUsage{}, err
+	}
+	log.Printf("[UnassignSeat] === %d of %d seats assigned ===", usage.Assigned, usage.Quantity)
+	return usage, nil
+}
+
+func lockCurrentSubscription(tx *gorm.DB, userId int) (models.Subscription, error) {
+	var sub models.Subscription
+	err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+		Where("user_id = ? AND is_current", userId).
+		First(&sub).Error
+	return sub, err
+}
+
+func seatUsage(db *gorm.DB, sub models.Subscription) (models.SeatUsage, error) {
+	var assignments []models.SeatAssignment
+	if err := db.Where("subscription_id = ?", sub.ID).Order("created_at, id").Find(&assignments).Error; err != nil {
+		return models.SeatUsage{}, err
+	}
+	return models.NewSeatUsage(sub, assignments), nil
+}
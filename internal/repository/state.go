@@ -0,0 +1,61 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/Harshal292004/subscription-service/internal/models"
+	"github.com/avast/retry-go"
+	"gorm.io/gorm"
+)
+
+// recordTransitions stores the status transitions taken from a subscription
+// in the transaction that saves it. IDs are cleared so a retried
+// transaction inserts them again.
+func recordTransitions(tx *gorm.DB, transitions []models.StatusTransition) error {
+	if len(transitions) == 0 {
+		return nil
+	}
+	for i := range transitions {
+		transitions[i].ID = 0
+	}
+	return tx.Create(&transitions).Error
+}
+
+// illegalTransition reports a status change refused by the state machine.
+func illegalTransition(err error) error {
+	return fmt.Errorf("%w: %v", ErrStatusTransition, err)
+}
+
+// GetSubscriptionTransitions lists the status transitions of a
+// subscription, oldest first.
+func (r *Repository) GetSubscriptionTransitions(subscriptionId int) ([]models.StatusTransition, error) {
+	log.Printf("[GetSubscriptionTransitions] === Starting GetSubscriptionTransitions for subscription ID: %d ===", subscriptionId)
+	ctx := context.Background()
+
+	var count int64
+	if err := r.DB.WithContext(ctx).Model(&models.Subscription{}).Where("id = ?", subscriptionId).Count(&count).Error; err != nil {
+		return nil, err
+	}
+	if count == 0 {
+		return nil, gorm.ErrRecordNotFound
+	}
+
+	var transitions []models.StatusTransition
+	err := retry.Do(func() error {
+		return r.DB.WithContext(ctx).
+			Where("subscription_id = ?", subscriptionId).
+			Order("created_at, id").
+			Find(&transitions).Error
+	}, retry.Attempts(3), retry.Delay(100*time.Millisecond), retry.DelayType(retry.BackOffDelay), retry.LastErrorOnly(true))
+
+	if err != nil {
+		log.Printf("[GetSubscriptionTransitions] All DB query attempts failed: %v", err)
+		return nil, err
+	}
+
+	log.Printf("[GetSubscriptionTransitions] === Returning %d transitions ===", len(transitions))
+	return transitions, nil
+}
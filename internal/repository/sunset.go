@@ -0,0 +1,263 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/Harshal292004/subscription-service/internal/models"
+	"github.com/avast/retry-go"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// PostPlanSunset retires a plan: its current version is archived right away
+// so it can no longer be bought, and its subscribers are scheduled onto the
+// replacement plan by ProcessPlanSunsets.
+func (r *Repository) PostPlanSunset(planId int, replacementPlanId int, noticeDays int) (models.PlanSunset, error) {
+	log.Printf("[PostPlanSunset] === Starting PostPlanSunset for plan ID: %d, replacement plan ID: %d ===", planId, replacementPlanId)
+	ctx := context.Background()
+
+	var plan, replacement models.Plan
+	err := retry.Do(func() error {
+		if err := r.DB.WithContext(ctx).First(&plan, planId).Error; err != nil {
+			return err
+		}
+		return r.DB.WithContext(ctx).First(&replacement, replacementPlanId).Error
+	}, retry.Attempts(3), retry.Delay(100*time.Millisecond), retry.DelayType(retry.BackOffDelay),
+		retry.LastErrorOnly(true), retry.RetryIf(retryable))
+
+	if err != nil {
+		log.Printf("[PostPlanSunset] Failed to fetch plans: %v", err)
+		return models.PlanSunset{}, err
+	}
+
+	now := time.Now()
+	sunset := models.PlanSunset{
+		PlanCode:          plan.Code,
+		ReplacementPlanID: replacement.ID,
+		NoticeDays:        noticeDays,
+		AnnouncedAt:       now,
+	}
+	if err := sunset.Validate(plan, replacement, now); err != nil {
+		log.Printf("[PostPlanSunset] Sunset rejected: %v", err)
+		return models.PlanSunset{}, fmt.Errorf("%w: %v", ErrInvalidSunset, err)
+	}
+
+	err = r.DB.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		var open int64
+		if err := tx.Model(&models.PlanSunset{}).
+			Where("plan_code IN ? AND completed_at IS NULL", []string{plan.Code, replacement.Code}).
+			Count(&open).Error; err != nil {
+			return err
+		}
+		if open > 0 {
+			return fmt.Errorf("%w: %s or %s is already being retired", ErrInvalidSunset, plan.Code, replacement.Code)
+		}
+		if err := tx.Create(&sunset).Error; err != nil {
+			return err
+		}
+		return tx.Model(&models.Plan{}).
+			Where("code = ? AND archived_at IS NULL", plan.Code).
+			Update("archived_at", now).Error
+	})
+	if err != nil {
+		log.Printf("[PostPlanSunset] Failed to create sunset: %v", err)
+		return models.PlanSunset{}, err
+	}
+
+	r.invalidatePlansCache(ctx)
+	log.Printf("[PostPlanSunset] === Retiring plan %s into plan ID %d, sunset ID: %d ===", plan.Code, replacement.ID, sunset.ID)
+	return sunset, nil
+}
+
+// GetPlanSunsets lists sunsets, most recent first, with their progress.
+func (r *Repository) GetPlanSunsets() ([]models.PlanSunset, error) {
+	log.Println("[GetPlanSunsets] === Starting GetPlanSunsets ===")
+	ctx := context.Background()
+
+	var sunsets []models.PlanSunset
+	err := retry.Do(func() error {
+		return r.DB.WithContext(ctx).Order("id DESC").Find(&sunsets).Error
+	}, retry.Attempts(3), retry.Delay(100*time.Millisecond), retry.DelayType(retry.BackOffDelay), retry.LastErrorOnly(true))
+
+	if err != nil {
+		log.Printf("[GetPlanSunsets] All DB query attempts failed: %v", err)
+		return nil, err
+	}
+	for i := range sunsets {
+		progress, err := r.sunsetProgress(ctx, sunsets[i])
+		if err != nil {
+			return nil, err
+		}
+		sunsets[i].Progress = &progress
+	}
+
+	log.Printf("[GetPlanSunsets] === Returning %d sunsets ===", len(sunsets))
+	return sunsets, nil
+}
+
+func (r *Repository) GetPlanSunset(sunsetId int) (models.PlanSunset, error) {
+	log.Printf("[GetPlanSunset] === Starting GetPlanSunset for sunset ID: %d ===", sunsetId)
+	ctx := context.Background()
+
+	var sunset models.PlanSunset
+	err := retry.Do(func() error {
+		return r.DB.WithContext(ctx).First(&sunset, sunsetId).Error
+	}, retry.Attempts(3), retry.Delay(100*time.Millisecond), retry.DelayType(retry.BackOffDelay),
+		retry.LastErrorOnly(true), retry.RetryIf(retryable))
+
+	if err != nil {
+		log.Printf("[GetPlanSunset] Failed to fetch sunset: %v", err)
+		return models.PlanSunset{}, err
+	}
+	progress, err := r.sunsetProgress(ctx, sunset)
+	if err != nil {
+		return models.PlanSunset{}, err
+	}
+	sunset.Progress = &progress
+	return sunset, nil
+}
+
+// ProcessPlanSunsets schedules the subscribers of retired plans onto the
+// replacement plan, queues a notice for each, and completes the sunsets
+// whose plan has no subscribers left. It returns how many subscriptions
+// were scheduled.
+func (r *Repository) ProcessPlanSunsets(now time.Time) (int, error) {
+	log.Println("[ProcessPlanSunsets] === Starting ProcessPlanSunsets ===")
+	ctx := context.Background()
+
+	var sunsets []models.PlanSunset
+	err := retry.Do(func() error {
+		return r.DB.WithContext(ctx).Where("completed_at IS NULL").Find(&sunsets).Error
+	}, retry.Attempts(3), retry.Delay(100*time.Millisecond), retry.DelayType(retry.BackOffDelay), retry.LastErrorOnly(true))
+
+	if err != nil {
+		log.Printf("[ProcessPlanSunsets] Failed to find open sunsets: %v", err)
+		return 0, err
+	}
+
+	scheduled := 0
+	for _, sunset := range sunsets {
+		count, err := r.processPlanSunset(ctx, sunset, now)
+		scheduled += count
+		if err != nil {
+			log.Printf("[ProcessPlanSunsets] Failed to process sunset ID %d: %v", sunset.ID, err)
+		}
+	}
+
+	log.Printf("[ProcessPlanSunsets] === Scheduled %d subscriptions ===", scheduled)
+	return scheduled, nil
+}
+
+func (r *Repository) processPlanSunset(ctx context.Context, sunset models.PlanSunset, now time.Time) (int, error) {
+	var replacement models.Plan
+	if err := r.DB.WithContext(ctx).First(&replacement, sunset.ReplacementPlanID).Error; err != nil {
+		return 0, err
+	}
+
+	// Subscribers with their own change scheduled elsewhere keep it
+	var subs []models.Subscription
+	err := r.DB.WithContext(ctx).
+		Preload("Plan").
+		Joins("JOIN plans ON plans.id = subscriptions.plan_id").
+		Where("plans.code = ? AND subscriptions.status NOT IN ?", sunset.PlanCode, []models.SubscriptionStatus{models.Cancelled, models.Expired}).
+		Where("subscriptions.pending_plan_id IS NULL OR subscriptions.pending_plan_id IN (SELECT id FROM plans WHERE code = ?)", sunset.PlanCode).
+		Find(&subs).Error
+	if err != nil {
+		return 0, err
+	}
+
+	scheduled := 0
+	for _, sub := range subs {
+		err := r.DB.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+			return scheduleSunsetMigration(tx, sunset, sub, replacement, now)
+		})
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			continue
+		}
+		if err != nil {
+			log.Printf("[processPlanSunset] Failed to schedule subscription ID %d: %v", sub.ID, err)
+			continue
+		}
+		r.evictSubscription(ctx, int(sub.UserID))
+		scheduled++
+	}
+
+	progress, err := r.sunsetProgress(ctx, sunset)
+	if err != nil {
+		return scheduled, err
+	}
+	if progress.Remaining == 0 {
+		log.Printf("[processPlanSunset] Plan %s has no subscribers left, completing sunset ID %d", sunset.PlanCode, sunset.ID)
+		return scheduled, r.DB.WithContext(ctx).Model(&sunset).Update("completed_at", now).Error
+	}
+	return scheduled, nil
+}
+
+// scheduleSunsetMigration sets the pending change of one subscription to the
+// replacement plan. The first time a subscription is scheduled it takes a
+// place on the replacement plan and its notice is queued; later runs only
+// restore a schedule the subscriber dropped, on the date they were given.
+func scheduleSunsetMigration(tx *gorm.DB, sunset models.PlanSunset, sub models.Subscription, replacement models.Plan, now time.Time) error {
+	record := models.SunsetSubscriber{
+		SunsetID:       sunset.ID,
+		SubscriptionID: sub.ID,
+		MigrateAt:      sunset.MigrationDate(sub, *sub.Plan),
+		NotifiedAt:     now,
+	}
+	result := tx.Clauses(clause.OnConflict{DoNothing: true}).Create(&record)
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 1 {
+		if err := claimPlace(tx, replacement.ID); err != nil {
+			return err
+		}
+		notice := models.SunsetNotice(sub, *sub.Plan, replacement, record.MigrateAt, now)
+		if err := tx.Create(&notice).Error; err != nil {
+			return err
+		}
+	} else if err := tx.Where("sunset_id = ? AND subscription_id = ?", sunset.ID, sub.ID).First(&record).Error; err != nil {
+		return err
+	}
+
+	// Skip the subscription if it changed since it was read
+	update := tx.Model(&models.Subscription{}).
+		Where("id = ? AND plan_id = ? AND pending_plan_id IS NOT DISTINCT FROM ?", sub.ID, sub.PlanID, sub.PendingPlanID).
+		Updates(map[string]interface{}{
+			"pending_plan_id":   replacement.ID,
+			"pending_change_at": record.MigrateAt,
+		})
+	if update.Error != nil {
+		return update.Error
+	}
+	if update.RowsAffected == 0 {
+		return gorm.ErrRecordNotFound
+	}
+	return nil
+}
+
+// sunsetProgress counts the live subscriptions still on the retired plan,
+// the scheduled ones among them and those already moved.
+func (r *Repository) sunsetProgress(ctx context.Context, sunset models.PlanSunset) (models.SunsetProgress, error) {
+	var progress models.SunsetProgress
+	err := r.DB.WithContext(ctx).Raw(`
+		SELECT
+			(SELECT COUNT(*) FROM subscriptions s JOIN plans p ON p.id = s.plan_id
+			 WHERE p.code = @code AND s.status NOT IN @ended) AS remaining,
+			(SELECT COUNT(*) FROM sunset_subscribers ss
+			 JOIN subscriptions s ON s.id = ss.subscription_id JOIN plans p ON p.id = s.plan_id
+			 WHERE ss.sunset_id = @id AND p.code = @code AND s.status NOT IN @ended) AS scheduled,
+			(SELECT COUNT(*) FROM sunset_subscribers ss
+			 JOIN subscriptions s ON s.id = ss.subscription_id JOIN plans p ON p.id = s.plan_id
+			 WHERE ss.sunset_id = @id AND p.code <> @code) AS migrated`,
+		map[string]interface{}{
+			"code":  sunset.PlanCode,
+			"id":    sunset.ID,
+			"ended": []models.SubscriptionStatus{models.Cancelled, models.Expired},
+		}).Scan(&progress).Error
+	return progress, err
+}
@@ -0,0 +1,162 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/Harshal292004/subscription-service/internal/models"
+	"github.com/avast/retry-go"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+func (r *Repository) GetPlanTransitions() ([]models.PlanTransition, error) {
+	log.Println("[GetPlanTransitions] === Starting GetPlanTransitions ===")
+	ctx := context.Background()
+
+	var transitions []models.PlanTransition
+	err := retry.Do(func() error {
+		return r.DB.WithContext(ctx).Order("from_plan_code, to_plan_code").Find(&transitions).Error
+	}, retry.Attempts(3), retry.Delay(100*time.Millisecond), retry.DelayType(retry.BackOffDelay), retry.LastErrorOnly(true))
+
+	if err != nil {
+		log.Printf("[GetPlanTransitions] All DB query attempts failed: %v", err)
+		return nil, err
+	}
+
+	log.Printf("[GetPlanTransitions] === Returning %d transitions ===", len(transitions))
+	return transitions, nil
+}
+
+// PutPlanTransition creates or replaces the rule between two plan codes.
+func (r *Repository) PutPlanTransition(t models.PlanTransition) (models.PlanTransition, error) {
+	log.Printf("[PutPlanTransition] === Starting PutPlanTransition %s -> %s ===", t.FromPlanCode, t.ToPlanCode)
+	ctx := context.Background()
+
+	if err := t.Validate(); err != nil {
+		return models.PlanTransition{}, fmt.Errorf("%w: %v", ErrInvalidTransition, err)
+	}
+	for _, code := range []string{t.FromPlanCode, t.ToPlanCode} {
+		var count int64
+		if err := r.DB.WithContext(ctx).Model(&models.Plan{}).Where("code = ?", code).Count(&count).Error; err != nil {
+			return models.PlanTransition{}, err
+		}
+		if count == 0 {
+			return models.PlanTransition{}, fmt.Errorf("%w: unknown plan code %q", ErrInvalidTransition, code)
+		}
+	}
+	if t.Kind == models.TransitionForbidden {
+		t.Timing, t.Prorate = "", false
+	}
+
+	err := retry.Do(func() error {
+		return r.DB.WithContext(ctx).Clauses(clause.OnConflict{
+			Columns:   []clause.Column{{Name: "from_plan_code"}, {Name: "to_plan_code"}},
+			DoUpdates: clause.AssignmentColumns([]string{"kind", "timing", "prorate", "updated_at"}),
+		}).Create(&t).Error
+	}, retry.Attempts(3), retry.Delay(100*time.Millisecond), retry.DelayType(retry.BackOffDelay), retry.LastErrorOnly(true))
+
+	if err != nil {
+		log.Printf("[PutPlanTransition] Failed to save transition: %v", err)
+		return models.PlanTransition{}, err
+	}
+
+	log.Printf("[PutPlanTransition] === Saved %s transition %s -> %s ===", t.Kind, t.FromPlanCode, t.ToPlanCode)
+	return t, nil
+}
+
+// DeletePlanTransition removes a rule so the default policy applies again.
+func (r *Repository) DeletePlanTransition(fromCode, toCode string) error {
+	log.Printf("[DeletePlanTransition] === Starting DeletePlanTransition %s -> %s ===", fromCode, toCode)
+	ctx := context.Background()
+
+	var result *gorm.DB
+	err := retry.Do(func() error {
+		result = r.DB.WithContext(ctx).
+			Where("from_plan_code = ? AND to_plan_code = ?", fromCode, toCode).
+			Delete(&models.PlanTransition{})
+		return result.Error
+	}, retry.Attempts(3), retry.Delay(100*time.Millisecond), retry.DelayType(retry.BackOffDelay), retry.LastErrorOnly(true))
+
+	if err != nil {
+		log.Printf("[DeletePlanTransition] Failed to delete transition: %v", err)
+		return err
+	}
+	if result.RowsAffected == 0 {
+		return gorm.ErrRecordNotFound
+	}
+	return nil
+}
+
+// resolveTransition returns the configured rule between two plans, or the
+// default policy derived from their prices.
+func (r *Repository) resolveTransition(ctx context.Context, from, to models.Plan) (models.PlanTransition, error) {
+	var rule models.PlanTransition
+	err := retry.Do(func() error {
+		return r.DB.WithContext(ctx).
+			Where("from_plan_code = ? AND to_plan_code = ?", from.Code, to.Code).
+			First(&rule).Error
+	}, retry.Attempts(3), retry.Delay(100*time.Millisecond), retry.DelayType(retry.BackOffDelay),
+		retry.LastErrorOnly(true), retry.RetryIf(retryable))
+
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return models.DefaultTransition(from, to), nil
+	}
+	return rule, err
+}
+
+// GetPendingPlanChange returns the plan change scheduled on the user's
+// current subscription, or gorm.ErrRecordNotFound when there is none.
+func (r *Repository) GetPendingPlanChange(userId int) (models.PendingPlanChange, error) {
+	log.Printf("[GetPendingPlanChange] === Starting GetPendingPlanChange for user ID: %d ===", userId)
+
+	sub, err := r.GetCachedSubscription(userId)
+	if err != nil {
+		return models.PendingPlanChange{}, err
+	}
+	if sub.PendingPlanID == nil || sub.PendingChangeAt == nil {
+		return models.PendingPlanChange{}, gorm.ErrRecordNotFound
+	}
+
+	plan, err := r.GetCachedPlan(int(*sub.PendingPlanID))
+	if err != nil {
+		log.Printf("[GetPendingPlanChange] Failed to fetch plan ID %d: %v", *sub.PendingPlanID, err)
+		return models.PendingPlanChange{}, err
+	}
+
+	log.Printf("[GetPendingPlanChange] === Subscription ID %d moves to plan ID %d at %v ===", sub.ID, plan.ID, *sub.PendingChangeAt)
+	return models.PendingPlanChange{SubscriptionID: sub.ID, Plan: plan, EffectiveAt: *sub.PendingChangeAt}, nil
+}
+
+// DeletePendingPlanChange drops the plan change scheduled on the user's
+// current subscription, which then renews on its current plan.
+func (r *Repository) DeletePendingPlanChange(userId int) (models.Subscription, error) {
+	log.Printf("[DeletePendingPlanChange] === Starting DeletePendingPlanChange for user ID: %d ===", userId)
+	ctx := context.Background()
+
+	var sub models.Subscription
+	err := r.DB.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+			Where("user_id = ? AND is_current AND pending_plan_id IS NOT NULL", userId).
+			First(&sub).Error; err != nil {
+			return err
+		}
+		sub.ClearPendingChange()
+		return tx.Model(&sub).Updates(map[string]interface{}{
+			"pending_plan_id":   nil,
+			"pending_change_at": nil,
+		}).Error
+	})
+
+	if err != nil {
+		log.Printf("[DeletePendingPlanChange] Failed to drop pending change: %v", err)
+		return models.Subscription{}, err
+	}
+
+	r.evictSubscription(ctx, userId)
+	log.Printf("[DeletePendingPlanChange] === Dropped pending change on subscription ID %d ===", sub.ID)
+	return sub, nil
+}
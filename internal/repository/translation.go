@@ -0,0 +1,199 @@
+package repository
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/Harshal292004/subscription-service/internal/models"
+	"github.com/avast/retry-go"
+	"gorm.io/gorm/clause"
+)
+
+// GetLocales lists the locales that have at least one plan or entitlement
+// translation. The list is cached next to the catalog.
+func (r *Repository) GetLocales() ([]string, error) {
+	log.Println("[GetLocales] === Starting GetLocales ===")
+	ctx := context.Background()
+	key := "plans:locales"
+
+	var locales []string
+	if val, err := r.Redis.Get(ctx, key).Result(); err == nil {
+		if err := json.Unmarshal([]byte(val), &locales); err == nil {
+			log.Printf("[GetLocales] Cache hit, %d locales", len(locales))
+			return locales, nil
+		}
+	}
+
+	err := retry.Do(func() error {
+		return r.DB.WithContext(ctx).Raw(
+			"SELECT locale FROM plan_translations UNION SELECT locale FROM entitlement_translations ORDER BY locale",
+		).Scan(&locales).Error
+	}, retry.Attempts(3), retry.Delay(100*time.Millisecond), retry.DelayType(retry.BackOffDelay), retry.LastErrorOnly(true))
+
+	if err != nil {
+		log.Printf("[GetLocales] All DB query attempts failed: %v", err)
+		return nil, err
+	}
+
+	r.cacheJSON(ctx, key, locales, 12*time.Hour)
+	log.Printf("[GetLocales] === Returning %d locales ===", len(locales))
+	return locales, nil
+}
+
+// GetLocalizedPlans returns the catalog translated into the locale, cached
+// per locale under plans:<locale>.
+func (r *Repository) GetLocalizedPlans(locale string) ([]models.Plan, error) {
+	log.Printf("[GetLocalizedPlans] === Starting GetLocalizedPlans for locale: %s ===", locale)
+	ctx := context.Background()
+	key := "plans:" + locale
+
+	var plans []models.Plan
+	if val, err := r.Redis.Get(ctx, key).Result(); err == nil {
+		if err := json.Unmarshal([]byte(val), &plans); err == nil {
+			log.Printf("[GetLocalizedPlans] Cache hit, %d plans", len(plans))
+			return plans, nil
+		}
+		log.Printf("[GetLocalizedPlans] Failed to unmarshal cached plans: %v", err)
+	}
+
+	plans, err := r.GetCachedPlans()
+	if err != nil {
+		return nil, err
+	}
+	translations, err := r.getPlanTranslations(ctx, locale)
+	if err != nil {
+		return nil, err
+	}
+	models.LocalizePlans(plans, translations, locale)
+
+	r.cacheJSON(ctx, key, plans, 12*time.Hour)
+	log.Printf("[GetLocalizedPlans] === Returning %d plans ===", len(plans))
+	return plans, nil
+}
+
+// GetLocalizedPlan looks a plan up in the localized catalog, falling back to
+// the database for archived versions.
+func (r *Repository) GetLocalizedPlan(planId int, locale string) (models.Plan, error) {
+	plans, err := r.GetLocalizedPlans(locale)
+	if err != nil {
+		return models.Plan{}, err
+	}
+	for _, plan := range plans {
+		if plan.ID == uint(planId) {
+			return plan, nil
+		}
+	}
+
+	plan, err := r.GetCachedPlan(planId)
+	if err != nil {
+		return models.Plan{}, err
+	}
+	translations, err := r.getPlanTranslations(context.Background(), locale)
+	if err != nil {
+		return models.Plan{}, err
+	}
+	localized := []models.Plan{plan}
+	models.LocalizePlans(localized, translations, locale)
+	return localized[0], nil
+}
+
+// GetLocalizedEntitlementDefinitions returns the entitlement schema with
+// descriptions translated into the locale.
+func (r *Repository) GetLocalizedEntitlementDefinitions(locale string) ([]models.EntitlementDefinition, error) {
+	defs, err := r.GetEntitlementDefinitions()
+	if err != nil {
+		return nil, err
+	}
+
+	var translations []models.EntitlementTranslation
+	err = retry.Do(func() error {
+		return r.DB.WithContext(context.Background()).
+			Where("locale IN ?", models.LocaleFallbacks(locale)).Find(&translations).Error
+	}, retry.Attempts(3), retry.Delay(100*time.Millisecond), retry.DelayType(retry.BackOffDelay), retry.LastErrorOnly(true))
+
+	if err != nil {
+		log.Printf("[GetLocalizedEntitlementDefinitions] Failed to load translations: %v", err)
+		return nil, err
+	}
+
+	models.LocalizeEntitlements(defs, translations, locale)
+	return defs, nil
+}
+
+// PutPlanTranslation creates or replaces the translation of a plan. It
+// applies to every version of the plan.
+func (r *Repository) PutPlanTranslation(planId int, translation models.PlanTranslation) (models.PlanTranslation, error) {
+	log.Printf("[PutPlanTranslation] === Starting PutPlanTranslation for plan ID: %d, locale: %s ===", planId, translation.Locale)
+	ctx := context.Background()
+
+	plan, err := r.GetCachedPlan(planId)
+	if err != nil {
+		log.Printf("[PutPlanTranslation] Failed to fetch plan: %v", err)
+		return models.PlanTranslation{}, err
+	}
+	translation.PlanCode = plan.Code
+
+	err = retry.Do(func() error {
+		return r.DB.WithContext(ctx).Clauses(clause.OnConflict{
+			Columns:   []clause.Column{{Name: "plan_code"}, {Name: "locale"}},
+			DoUpdates: clause.AssignmentColumns([]string{"name", "features", "updated_at"}),
+		}).Create(&translation).Error
+	}, retry.Attempts(3), retry.Delay(100*time.Millisecond), retry.DelayType(retry.BackOffDelay), retry.LastErrorOnly(true))
+
+	if err != nil {
+		log.Printf("[PutPlanTranslation] Failed to save translation: %v", err)
+		return models.PlanTranslation{}, err
+	}
+
+	r.invalidatePlansCache(ctx)
+	log.Printf("[PutPlanTranslation] === Saved %s translation of plan %s ===", translation.Locale, translation.PlanCode)
+	return translation, nil
+}
+
+// PutEntitlementTranslation creates or replaces the translated description
+// of an entitlement.
+func (r *Repository) PutEntitlementTranslation(translation models.EntitlementTranslation) (models.EntitlementTranslation, error) {
+	log.Printf("[PutEntitlementTranslation] === Starting PutEntitlementTranslation for key: %s, locale: %s ===", translation.EntitlementKey, translation.Locale)
+	ctx := context.Background()
+
+	err := retry.Do(func() error {
+		return r.DB.WithContext(ctx).First(&models.EntitlementDefinition{}, "key = ?", translation.EntitlementKey).Error
+	}, retry.Attempts(3), retry.Delay(100*time.Millisecond), retry.DelayType(retry.BackOffDelay),
+		retry.LastErrorOnly(true), retry.RetryIf(retryable))
+
+	if err != nil {
+		log.Printf("[PutEntitlementTranslation] Failed to fetch entitlement: %v", err)
+		return models.EntitlementTranslation{}, err
+	}
+
+	err = retry.Do(func() error {
+		return r.DB.WithContext(ctx).Clauses(clause.OnConflict{
+			Columns:   []clause.Column{{Name: "entitlement_key"}, {Name: "locale"}},
+			DoUpdates: clause.AssignmentColumns([]string{"description", "updated_at"}),
+		}).Create(&translation).Error
+	}, retry.Attempts(3), retry.Delay(100*time.Millisecond), retry.DelayType(retry.BackOffDelay), retry.LastErrorOnly(true))
+
+	if err != nil {
+		log.Printf("[PutEntitlementTranslation] Failed to save translation: %v", err)
+		return models.EntitlementTranslation{}, err
+	}
+
+	r.invalidatePlansCache(ctx)
+	log.Printf("[PutEntitlementTranslation] === Saved %s translation of %s ===", translation.Locale, translation.EntitlementKey)
+	return translation, nil
+}
+
+func (r *Repository) getPlanTranslations(ctx context.Context, locale string) ([]models.PlanTranslation, error) {
+	var translations []models.PlanTranslation
+	err := retry.Do(func() error {
+		return r.DB.WithContext(ctx).Where("locale IN ?", models.LocaleFallbacks(locale)).Find(&translations).Error
+	}, retry.Attempts(3), retry.Delay(100*time.Millisecond), retry.DelayType(retry.BackOffDelay), retry.LastErrorOnly(true))
+
+	if err != nil {
+		return nil, fmt.Errorf("loading %s plan translations: %w", locale, err)
+	}
+	return translations, nil
+}
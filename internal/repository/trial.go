@@ -0,0 +1,20 @@
+package repository
+
+import (
+	"time"
+
+	"github.com/Harshal292004/subscription-service/internal/models"
+	"gorm.io/gorm"
+)
+
+// claimTrial marks the user's one free trial as used. It reports false when
+// the trial was already used, including by a concurrent request.
+func claimTrial(tx *gorm.DB, userId int, now time.Time) (bool, error) {
+	result := tx.Model(&models.User{}).
+		Where("id = ? AND trial_used_at IS NULL", userId).
+		Update("trial_used_at", now)
+	if result.Error != nil {
+		return false, result.Error
+	}
+	return result.RowsAffected == 1, nil
+}
@@ -0,0 +1,78 @@
+package services
+
+import (
+	"fmt"
+
+	"github.com/Harshal292004/subscription-service/internal/catalog"
+	"github.com/Harshal292004/subscription-service/internal/models"
+	"github.com/Harshal292004/subscription-service/internal/repository"
+)
+
+type CatalogService struct {
+	repo *repository.Repository
+}
+
+func NewCatalogService(r *repository.Repository) *CatalogService {
+	return &CatalogService{repo: r}
+}
+
+// Export returns the current version of every plan and the entitlement schema.
+func (s *CatalogService) Export() (catalog.Catalog, error) {
+	plans, err := s.repo.GetCurrentPlans()
+	if err != nil {
+		return catalog.Catalog{}, err
+	}
+	defs, err := s.repo.GetEntitlementDefinitions()
+	if err != nil {
+		return catalog.Catalog{}, err
+	}
+	return catalog.FromModels(plans, defs)
+}
+
+// Diff validates the desired catalog and lists the changes applying it would
+// make.
+func (s *CatalogService) Diff(desired catalog.Catalog) ([]catalog.Change, error) {
+	current, err := s.Export()
+	if err != nil {
+		return nil, err
+	}
+	if err := validateCatalog(current, desired); err != nil {
+		return nil, err
+	}
+	return catalog.Diff(current, desired)
+}
+
+// Apply brings the database in line with the desired catalog and returns the
+// changes made. Applying the same catalog again changes nothing.
+func (s *CatalogService) Apply(desired catalog.Catalog) ([]catalog.Change, error) {
+	changes, err := s.Diff(desired)
+	if err != nil || len(changes) == 0 {
+		return changes, err
+	}
+	return changes, s.repo.ApplyCatalog(changes)
+}
+
+func validateCatalog(current, desired catalog.Catalog) error {
+	schema := map[string]models.EntitlementDefinition{}
+	for _, e := range current.Entitlements {
+		schema[e.Key] = e.Model()
+	}
+	for _, e := range desired.Entitlements {
+		def := e.Model()
+		if err := def.Validate(); err != nil {
+			return err
+		}
+		schema[e.Key] = def
+	}
+
+	defs := make([]models.EntitlementDefinition, 0, len(schema))
+	for _, def := range schema {
+		defs = append(defs, def)
+	}
+	for _, p := range desired.Plans {
+		if err := p.Model().Validate(defs); err != nil {
+			return fmt.Errorf("plan %q: %w", p.Code, err)
+		}
+	}
+	return nil
+}
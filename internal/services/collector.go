@@ -0,0 +1,24 @@
+package services
+
+import (
+	"log"
+
+	"github.com/Harshal292004/subscription-service/internal/models"
+)
+
+// PaymentCollector charges users for their renewals. A renewal whose
+// outcome was lost is collected again with the same IdempotencyKey, which
+// must not charge the user a second time.
+type PaymentCollector interface {
+	Collect(r models.Renewal) error
+}
+
+// LogCollector writes renewal charges to the log and always succeeds. It
+// stands in until a payment provider is wired in.
+type LogCollector struct{}
+
+func (LogCollector) Collect(r models.Renewal) error {
+	log.Printf("[LogCollector] Charging user ID %d %.2f %s for subscription ID %d, %v - %v, key %s",
+		r.UserID, r.Amount, r.Currency, r.SubscriptionID, r.PeriodStart, r.PeriodEnd, r.IdempotencyKey)
+	return nil
+}
@@ -0,0 +1,21 @@
+package services
+
+import (
+	"log"
+
+	"github.com/Harshal292004/subscription-service/internal/models"
+)
+
+// Notifier delivers notifications to users.
+type Notifier interface {
+	Notify(n models.Notification) error
+}
+
+// LogNotifier writes notifications to the log. It stands in until an email
+// or push provider is wired in.
+type LogNotifier struct{}
+
+func (LogNotifier) Notify(n models.Notification) error {
+	log.Printf("[LogNotifier] To user ID %d: %s - %s", n.UserID, n.Subject, n.Body)
+	return nil
+}
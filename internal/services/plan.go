@@ -1,10 +1,20 @@
 package services
 
 import (
+	"errors"
+	"fmt"
+	"os"
+	"time"
+
 	"github.com/Harshal292004/subscription-service/internal/models"
 	"github.com/Harshal292004/subscription-service/internal/repository"
 )
 
+var (
+	ErrInvalidQuantity  = errors.New("invalid quantity")
+	ErrInvalidPlanQuery = errors.New("invalid plan query")
+)
+
 type PlanService struct {
 	repo *repository.Repository
 }
@@ -16,3 +26,232 @@ func NewPlanService(r *repository.Repository) *PlanService {
 func (s *PlanService) GetAllPlans() ([]models.Plan, error) {
 	return s.repo.GetCachedPlans()
 }
+
+// FindPlans filters and sorts the cached catalog translated into locale. A
+// positive userId adds the private plans offered to the user and shows their
+// price experiment variants.
+func (s *PlanService) FindPlans(filter models.PlanFilter, sort string, locale string, userId int) ([]models.Plan, error) {
+	plans, err := s.repo.GetLocalizedPlans(locale)
+	if err != nil {
+		return nil, err
+	}
+	if userId > 0 {
+		private, err := s.repo.GetPrivatePlans(userId, locale)
+		if err != nil {
+			return nil, err
+		}
+		plans = append(plans, private...)
+		if err := s.repo.PersonalizePlans(userId, plans); err != nil {
+			return nil, err
+		}
+	}
+
+	counts, err := s.repo.GetSubscriberCounts()
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	matched := make([]models.Plan, 0, len(plans))
+	for _, plan := range plans {
+		if !plan.Available(now) || !filter.Matches(plan) {
+			continue
+		}
+		if plan.MaxSubscribers != nil {
+			plan.SetSubscriberCount(counts[plan.ID])
+		}
+		matched = append(matched, plan)
+	}
+	if err := models.SortPlans(matched, sort); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidPlanQuery, err)
+	}
+	return matched, nil
+}
+
+// CatalogVersion identifies what the plan endpoints currently serve, so
+// unchanged responses can be revalidated without building them.
+func (s *PlanService) CatalogVersion() (string, error) {
+	return s.repo.GetCatalogVersion(time.Now())
+}
+
+func (s *PlanService) GetPlan(planId int, locale string, userId int) (models.Plan, error) {
+	plan, err := s.repo.GetLocalizedPlan(planId, locale)
+	if err != nil {
+		return models.Plan{}, err
+	}
+	if err := s.repo.CheckPlanVisible(plan, userId); err != nil {
+		return models.Plan{}, err
+	}
+	if plan, err = s.personalizePlan(userId, plan); err != nil {
+		return models.Plan{}, err
+	}
+	if plan.MaxSubscribers == nil {
+		return plan, nil
+	}
+	counts, err := s.repo.GetSubscriberCounts()
+	if err != nil {
+		return models.Plan{}, err
+	}
+	plan.SetSubscriberCount(counts[plan.ID])
+	return plan, nil
+}
+
+// ResolveLocale picks the locale to serve among the default locale and the
+// translated ones. An explicit locale wins over the Accept-Language header;
+// either falls back to its base language and then to the default.
+func (s *PlanService) ResolveLocale(requested string, acceptLanguage string) (string, error) {
+	locales, err := s.repo.GetLocales()
+	if err != nil {
+		return "", err
+	}
+	offers := append([]string{defaultLocale()}, locales...)
+
+	candidates := models.AcceptedLocales(acceptLanguage)
+	if requested != "" {
+		normalized, err := models.NormalizeLocale(requested)
+		if err != nil {
+			return "", fmt.Errorf("%w: unknown locale %q", ErrInvalidPlanQuery, requested)
+		}
+		candidates = []string{normalized}
+	}
+	if locale := models.MatchLocale(candidates, offers); locale != "" {
+		return locale, nil
+	}
+	return offers[0], nil
+}
+
+func (s *PlanService) SetPlanTranslation(planId int, translation models.PlanTranslation) (models.PlanTranslation, error) {
+	return s.repo.PutPlanTranslation(planId, translation)
+}
+
+func (s *PlanService) SetEntitlementTranslation(translation models.EntitlementTranslation) (models.EntitlementTranslation, error) {
+	return s.repo.PutEntitlementTranslation(translation)
+}
+
+// QuotePlan prices quantity units of a plan without subscribing. A positive
+// userId quotes the user's price experiment variant.
+func (s *PlanService) QuotePlan(planId int, quantity int, userId int) (models.PriceQuote, error) {
+	plan, err := s.repo.GetCachedPlan(planId)
+	if err != nil {
+		return models.PriceQuote{}, err
+	}
+	if err := s.repo.CheckPlanVisible(plan, userId); err != nil {
+		return models.PriceQuote{}, err
+	}
+	if plan, err = s.personalizePlan(userId, plan); err != nil {
+		return models.PriceQuote{}, err
+	}
+	quote, err := plan.Quote(quantity)
+	if err != nil {
+		return models.PriceQuote{}, fmt.Errorf("%w: %v", ErrInvalidQuantity, err)
+	}
+	return quote, nil
+}
+
+func (s *PlanService) CreatePlan(plan models.Plan) (models.Plan, error) {
+	return s.repo.PostPlan(plan)
+}
+
+// CreatePrivatePlan derives a plan reserved for one user or organization
+// from a public base plan.
+func (s *PlanService) CreatePrivatePlan(basePlanId int, terms models.PrivatePlanTerms) (models.Plan, error) {
+	return s.repo.PostPrivatePlan(basePlanId, terms)
+}
+
+func (s *PlanService) UpdatePlan(planId int, plan models.Plan) (models.Plan, error) {
+	return s.repo.PutPlan(planId, plan)
+}
+
+func (s *PlanService) GetEntitlementDefinitions(locale string) ([]models.EntitlementDefinition, error) {
+	return s.repo.GetLocalizedEntitlementDefinitions(locale)
+}
+
+func (s *PlanService) CreateEntitlementDefinition(def models.EntitlementDefinition) (models.EntitlementDefinition, error) {
+	return s.repo.PostEntitlementDefinition(def)
+}
+
+func (s *PlanService) GetAddOns(planId int) ([]models.AddOn, error) {
+	return s.repo.GetAddOns(planId)
+}
+
+func (s *PlanService) CreateAddOn(addOn models.AddOn) (models.AddOn, error) {
+	return s.repo.PostAddOn(addOn)
+}
+
+func (s *PlanService) GetExperiments() ([]models.Experiment, error) {
+	return s.repo.GetExperiments()
+}
+
+func (s *PlanService) CreateExperiment(experiment models.Experiment) (models.Experiment, error) {
+	return s.repo.PostExperiment(experiment)
+}
+
+func (s *PlanService) StopExperiment(experimentId int) (models.Experiment, error) {
+	return s.repo.StopExperiment(experimentId)
+}
+
+func (s *PlanService) GetExperimentReport(experimentId int) ([]models.VariantResult, error) {
+	return s.repo.GetExperimentReport(experimentId)
+}
+
+func (s *PlanService) personalizePlan(userId int, plan models.Plan) (models.Plan, error) {
+	if userId <= 0 {
+		return plan, nil
+	}
+	plans := []models.Plan{plan}
+	err := s.repo.PersonalizePlans(userId, plans)
+	return plans[0], err
+}
+
+// defaultLocale is the language plan names and features are written in,
+// set with DEFAULT_LOCALE.
+func defaultLocale() string {
+	if locale, err := models.NormalizeLocale(os.Getenv("DEFAULT_LOCALE")); err == nil && locale != "und" {
+		return locale
+	}
+	return "en"
+}
+
+func (s *PlanService) GetPlanTransitions() ([]models.PlanTransition, error) {
+	return s.repo.GetPlanTransitions()
+}
+
+func (s *PlanService) SetPlanTransition(t models.PlanTransition) (models.PlanTransition, error) {
+	return s.repo.PutPlanTransition(t)
+}
+
+func (s *PlanService) DeletePlanTransition(fromCode, toCode string) error {
+	return s.repo.DeletePlanTransition(fromCode, toCode)
+}
+
+// SunsetPlan retires a plan in favor of a replacement. Subscribers move at
+// their first renewal after noticeDays.
+func (s *PlanService) SunsetPlan(planId int, replacementPlanId int, noticeDays int) (models.PlanSunset, error) {
+	return s.repo.PostPlanSunset(planId, replacementPlanId, noticeDays)
+}
+
+func (s *PlanService) GetPlanSunsets() ([]models.PlanSunset, error) {
+	return s.repo.GetPlanSunsets()
+}
+
+func (s *PlanService) GetPlanSunset(sunsetId int) (models.PlanSunset, error) {
+	return s.repo.GetPlanSunset(sunsetId)
+}
+
+// AnnouncePriceChange schedules a new price for the existing subscribers of
+// a plan, except the exempted users.
+func (s *PlanService) AnnouncePriceChange(change models.PriceChange, exemptUserIds []uint) (models.PriceChange, error) {
+	return s.repo.PostPriceChange(change, exemptUserIds)
+}
+
+func (s *PlanService) GetPriceChanges() ([]models.PriceChange, error) {
+	return s.repo.GetPriceChanges()
+}
+
+func (s *PlanService) GetPriceChange(changeId int) (models.PriceChange, error) {
+	return s.repo.GetPriceChange(changeId)
+}
+
+func (s *PlanService) ExemptFromPriceChange(changeId int, userId int) (models.PriceChangeSubscriber, error) {
+	return s.repo.ExemptFromPriceChange(changeId, userId)
+}
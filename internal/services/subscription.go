@@ -1,53 +1,215 @@
 package services
 
 import (
+	"time"
+
 	"github.com/Harshal292004/subscription-service/internal/models"
 	"github.com/Harshal292004/subscription-service/internal/repository"
 )
 
 type SubscriptionService struct {
-	repo *repository.Repository
+	repo      *repository.Repository
+	notifier  Notifier
+	collector PaymentCollector
 }
 
 func NewSubscriptionService(r *repository.Repository) *SubscriptionService {
-	return &SubscriptionService{repo: r}
+	return &SubscriptionService{repo: r, notifier: LogNotifier{}, collector: LogCollector{}}
 }
 
 func (s *SubscriptionService) GetSubscription(userId int) (models.Subscription, error) {
 	return s.repo.GetCachedSubscription(userId)
 }
 
-func (s *SubscriptionService) PostSubscription(userId int, planId int) (models.Subscription, error) {
-	return s.repo.PostSubscription(userId, planId)
+func (s *SubscriptionService) GetSubscriptionHistory(userId int) ([]models.Subscription, error) {
+	return s.repo.GetSubscriptionHistory(userId)
+}
+
+func (s *SubscriptionService) PostSubscription(userId int, planId int, quantity int) (models.Subscription, error) {
+	return s.repo.PostSubscription(userId, planId, quantity)
+}
+
+func (s *SubscriptionService) DeleteSubscription(userId int, cancel models.Cancellation) (models.SubscriptionCancellation, error) {
+	return s.repo.DeleteSubscription(userId, cancel)
 }
 
-func (s *SubscriptionService) DeleteSubscription(userId int) (models.Subscription, error) {
-	return s.repo.DeleteSubscription(userId)
+func (s *SubscriptionService) UndoCancellation(userId int) (models.Subscription, error) {
+	return s.repo.UndoCancellation(userId)
 }
 
-func (s *SubscriptionService) PutSubscription(userId int, newPlanId int) (models.Subscription, error) {
-	return s.repo.PutSubscription(userId, newPlanId)
+func (s *SubscriptionService) PutSubscription(userId int, newPlanId int, timing models.ChangeTiming) (models.PlanChange, error) {
+	return s.repo.PutSubscription(userId, newPlanId, timing)
 }
 
-// func (s *SubscriptionService) CheckExpiredSubscriptions() error {
-// 	now := time.Now()
-// 	var expiredSubs []models.Subscription
+// PauseSubscription puts the user's subscription on hold until resumeAt, or
+// until resumed when resumeAt is nil.
+func (s *SubscriptionService) PauseSubscription(userId int, resumeAt *time.Time) (models.Subscription, error) {
+	return s.repo.PauseSubscription(userId, resumeAt)
+}
 
-// 	err := s.repo.DB.
-// 		Where("end_date <= ? AND status = ?", now, models.Active).
-// 		Find(&expiredSubs).Error
-// 	if err != nil {
-// 		return err
-// 	}
+func (s *SubscriptionService) ResumeSubscription(userId int) (models.Subscription, error) {
+	return s.repo.ResumeSubscription(userId)
+}
 
-// 	for _, sub := range expiredSubs {
-// 		sub.Status = models.Expired
-// 		if err := s.repo.DB.Save(&sub).Error; err != nil {
-// 			log.Fatal(err)
-// 			continue
-// 		}
-// 		s.repo.Redis.Del(context.Background(), fmt.Sprintf("%d:sub", sub.UserID))
-// 	}
+func (s *SubscriptionService) SetAutoRenew(userId int, autoRenew bool) (models.Subscription, error) {
+	return s.repo.SetAutoRenew(userId, autoRenew)
+}
 
-// 	return nil
-// }
+// ChangeQuantity sets the number of seats on the user's subscription,
+// prorating the difference for the rest of the current period.
+func (s *SubscriptionService) ChangeQuantity(userId int, quantity int) (models.QuantityChange, error) {
+	return s.repo.ChangeQuantity(userId, quantity)
+}
+
+func (s *SubscriptionService) GetSeatUsage(userId int) (models.SeatUsage, error) {
+	return s.repo.GetSeatUsage(userId)
+}
+
+func (s *SubscriptionService) AssignSeat(userId int, memberId int) (models.SeatUsage, error) {
+	return s.repo.AssignSeat(userId, memberId)
+}
+
+func (s *SubscriptionService) UnassignSeat(userId int, memberId int) (models.SeatUsage, error) {
+	return s.repo.UnassignSeat(userId, memberId)
+}
+
+func (s *SubscriptionService) GetPendingPlanChange(userId int) (models.PendingPlanChange, error) {
+	return s.repo.GetPendingPlanChange(userId)
+}
+
+func (s *SubscriptionService) DeletePendingPlanChange(userId int) (models.Subscription, error) {
+	return s.repo.DeletePendingPlanChange(userId)
+}
+
+func (s *SubscriptionService) GetEntitlements(userId int) (models.EntitlementSet, error) {
+	return s.repo.GetEntitlements(userId)
+}
+
+func (s *SubscriptionService) AttachAddOn(userId int, addOnId int, quantity int) (models.Subscription, error) {
+	return s.repo.AttachAddOn(userId, addOnId, quantity)
+}
+
+func (s *SubscriptionService) DetachAddOn(userId int, addOnId int) (models.Subscription, error) {
+	return s.repo.DetachAddOn(userId, addOnId)
+}
+
+// EndCancelledSubscriptions cancels every subscription scheduled for
+// cancellation whose paid period is over.
+func (s *SubscriptionService) EndCancelledSubscriptions() error {
+	_, err := s.repo.EndCancelledSubscriptions(time.Now())
+	return err
+}
+
+// ResumePausedSubscriptions resumes every paused subscription whose resume
+// date has come.
+func (s *SubscriptionService) ResumePausedSubscriptions() error {
+	_, err := s.repo.ResumePausedSubscriptions(time.Now())
+	return err
+}
+
+// RenewSubscriptions starts the next period of the subscriptions that are
+// due, applying scheduled plan changes and collecting payment.
+func (s *SubscriptionService) RenewSubscriptions() error {
+	now := time.Now()
+	// Price changes taking effect at these renewals must land before they
+	// are charged.
+	if _, err := s.repo.ApplyPriceChanges(now); err != nil {
+		return err
+	}
+	_, err := s.repo.RenewSubscriptions(now, 100, repository.DunningPolicy(), s.collector.Collect)
+	return err
+}
+
+// EndDunning expires or cancels the past due subscriptions whose grace
+// period ran out unpaid.
+func (s *SubscriptionService) EndDunning() error {
+	_, err := s.repo.EndDunning(time.Now(), repository.DunningPolicy())
+	return err
+}
+
+// ProcessPlanSunsets schedules the subscribers of retired plans onto their
+// replacement plan and completes finished sunsets.
+func (s *SubscriptionService) ProcessPlanSunsets() error {
+	_, err := s.repo.ProcessPlanSunsets(time.Now())
+	return err
+}
+
+// ApplyPriceChanges moves subscribers to the new price of their plan once
+// their notice period is over and they renew.
+func (s *SubscriptionService) ApplyPriceChanges() error {
+	_, err := s.repo.ApplyPriceChanges(time.Now())
+	return err
+}
+
+// DeliverNotifications sends the queued notifications that are due.
+func (s *SubscriptionService) DeliverNotifications() error {
+	_, err := s.repo.DeliverNotifications(time.Now(), 100, s.notifier.Notify)
+	return err
+}
+
+// CreateMigrationJob records a bulk plan migration and starts processing it
+// in the background.
+func (s *SubscriptionService) CreateMigrationJob(job models.MigrationJob) (models.MigrationJob, error) {
+	job, err := s.repo.PostMigrationJob(job)
+	if err != nil {
+		return models.MigrationJob{}, err
+	}
+	go s.repo.RunMigrationJob(int(job.ID))
+	return job, nil
+}
+
+func (s *SubscriptionService) GetMigrationJobs() ([]models.MigrationJob, error) {
+	return s.repo.GetMigrationJobs()
+}
+
+func (s *SubscriptionService) GetMigrationJob(jobId int) (models.MigrationJob, error) {
+	return s.repo.GetMigrationJob(jobId)
+}
+
+func (s *SubscriptionService) GetMigrationJobItems(jobId int, status models.MigrationItemStatus) ([]models.MigrationJobItem, error) {
+	return s.repo.GetMigrationJobItems(jobId, status)
+}
+
+// SetMigrationJobStatus pauses, resumes or cancels a job. A resumed job is
+// picked up again right away.
+func (s *SubscriptionService) SetMigrationJobStatus(jobId int, status models.MigrationJobStatus) (models.MigrationJob, error) {
+	job, err := s.repo.SetMigrationJobStatus(jobId, status)
+	if err != nil {
+		return job, err
+	}
+	if job.Runnable() {
+		go s.repo.RunMigrationJob(jobId)
+	}
+	return job, nil
+}
+
+// RunMigrationJobs processes the migration jobs left pending or running, such
+// as those interrupted by a restart.
+func (s *SubscriptionService) RunMigrationJobs() error {
+	ids, err := s.repo.GetRunnableMigrationJobs()
+	if err != nil {
+		return err
+	}
+	for _, id := range ids {
+		if err := s.repo.RunMigrationJob(id); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// CheckExpiredSubscriptions moves the subscriptions that ended without
+// renewing to EXPIRED.
+func (s *SubscriptionService) CheckExpiredSubscriptions() error {
+	_, _, err := s.repo.ExpireSubscriptions(time.Now(), 500)
+	return err
+}
+
+func (s *SubscriptionService) GetSubscriptionTransitions(subscriptionId int) ([]models.StatusTransition, error) {
+	return s.repo.GetSubscriptionTransitions(subscriptionId)
+}
+
+// GetExpiryJobStatus reports the last run of the expiry sweeper.
+func (s *SubscriptionService) GetExpiryJobStatus() (models.JobRun, error) {
+	return s.repo.GetJobRun(repository.ExpiryJob)
+}
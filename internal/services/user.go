@@ -1,6 +1,9 @@
 package services
 
-import "github.com/Harshal292004/subscription-service/internal/repository"
+import (
+	"github.com/Harshal292004/subscription-service/internal/models"
+	"github.com/Harshal292004/subscription-service/internal/repository"
+)
 
 type UserService struct {
 	repo *repository.Repository
@@ -13,3 +16,15 @@ func NewUserService(r *repository.Repository) *UserService {
 func (s *UserService) RegisterUser(name, password string) (string, error) {
 	return s.repo.PostUser(name, password)
 }
+
+func (s *UserService) CreateOrganization(org models.Organization) (models.Organization, error) {
+	return s.repo.PostOrganization(org)
+}
+
+func (s *UserService) AddOrganizationMember(orgId int, userId int) (models.User, error) {
+	return s.repo.PutOrganizationMember(orgId, userId)
+}
+
+func (s *UserService) RemoveOrganizationMember(orgId int, userId int) error {
+	return s.repo.DeleteOrganizationMember(orgId, userId)
+}
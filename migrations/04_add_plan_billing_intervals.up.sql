@@ -0,0 +1,9 @@
+ALTER TABLE plans
+    ADD COLUMN interval_unit VARCHAR(10) CHECK (interval_unit IN ('day', 'week', 'month', 'year')),
+    ADD COLUMN interval_count INTEGER NOT NULL DEFAULT 1 CHECK (interval_count > 0);
+
+-- Plans without an interval_unit keep billing every duration_days days.
+
+ALTER TABLE subscriptions ADD COLUMN billing_anchor TIMESTAMPTZ;
+UPDATE subscriptions SET billing_anchor = start_date;
+ALTER TABLE subscriptions ALTER COLUMN billing_anchor SET NOT NULL;
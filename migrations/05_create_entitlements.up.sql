@@ -0,0 +1,25 @@
+CREATE TABLE entitlement_definitions (
+    key VARCHAR(100) PRIMARY KEY,
+    type VARCHAR(20) NOT NULL CHECK (type IN ('boolean', 'limit', 'enum')),
+    allowed_values JSONB NOT NULL DEFAULT '[]',
+    description TEXT NOT NULL DEFAULT '',
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+INSERT INTO entitlement_definitions (key, type, allowed_values, description)
+VALUES
+('feature_a', 'boolean', '[]', 'Access to Feature A'),
+('feature_b', 'boolean', '[]', 'Access to Feature B'),
+('feature_c', 'boolean', '[]', 'Access to Feature C'),
+('projects', 'limit', '[]', 'Maximum number of projects, -1 for unlimited'),
+('support_tier', 'enum', '["community", "standard", "priority"]', 'Support level');
+
+ALTER TABLE plans ADD COLUMN entitlements JSONB NOT NULL DEFAULT '{}';
+
+UPDATE plans SET entitlements = '{"feature_a": true, "feature_b": true, "projects": 3, "support_tier": "community"}'
+WHERE name = 'Basic Plan';
+UPDATE plans SET entitlements = '{"feature_a": true, "feature_b": true, "feature_c": true, "projects": 20, "support_tier": "standard"}'
+WHERE name = 'Pro Plan';
+UPDATE plans SET entitlements = '{"feature_a": true, "feature_b": true, "feature_c": true, "projects": -1, "support_tier": "priority"}'
+WHERE name = 'Enterprise Plan';
@@ -0,0 +1,8 @@
+ALTER TYPE subscription_status ADD VALUE 'TRIALING';
+
+ALTER TABLE plans ADD COLUMN trial_days INTEGER NOT NULL DEFAULT 0 CHECK (trial_days >= 0);
+
+ALTER TABLE subscriptions ADD COLUMN trial_end TIMESTAMPTZ;
+
+-- A user gets one free trial across all plans.
+ALTER TABLE users ADD COLUMN trial_used_at TIMESTAMPTZ;
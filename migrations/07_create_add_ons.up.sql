@@ -0,0 +1,47 @@
+CREATE TABLE add_ons (
+    id SERIAL PRIMARY KEY,
+    code VARCHAR(100) NOT NULL UNIQUE,
+    name VARCHAR(100) NOT NULL,
+    price DOUBLE PRECISION NOT NULL CHECK (price >= 0),
+    entitlements JSONB NOT NULL DEFAULT '{}',
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+-- An add-on can only be attached to subscriptions on the plans listed here.
+CREATE TABLE plan_add_ons (
+    plan_id INTEGER NOT NULL REFERENCES plans(id) ON DELETE CASCADE,
+    add_on_id INTEGER NOT NULL REFERENCES add_ons(id) ON DELETE CASCADE,
+    max_quantity INTEGER NOT NULL DEFAULT 1 CHECK (max_quantity > 0),
+    PRIMARY KEY (plan_id, add_on_id)
+);
+
+CREATE TABLE subscription_add_ons (
+    id SERIAL PRIMARY KEY,
+    subscription_id INTEGER NOT NULL REFERENCES subscriptions(id) ON DELETE CASCADE,
+    add_on_id INTEGER NOT NULL REFERENCES add_ons(id) ON DELETE RESTRICT,
+    quantity INTEGER NOT NULL CHECK (quantity > 0),
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    UNIQUE (subscription_id, add_on_id)
+);
+
+INSERT INTO entitlement_definitions (key, type, allowed_values, description)
+VALUES ('storage_gb', 'limit', '[]', 'Storage in GB, -1 for unlimited');
+
+UPDATE plans SET entitlements = entitlements || '{"storage_gb": 10}' WHERE name = 'Basic Plan';
+UPDATE plans SET entitlements = entitlements || '{"storage_gb": 100}' WHERE name = 'Pro Plan';
+UPDATE plans SET entitlements = entitlements || '{"storage_gb": 1000}' WHERE name = 'Enterprise Plan';
+
+INSERT INTO add_ons (code, name, price, entitlements)
+VALUES
+('extra_storage', 'Extra Storage (50 GB)', 4.99, '{"storage_gb": 50}'),
+('priority_support', 'Priority Support', 14.99, '{"support_tier": "priority"}');
+
+INSERT INTO plan_add_ons (plan_id, add_on_id, max_quantity)
+SELECT p.id, a.id, 10 FROM plans p, add_ons a
+WHERE a.code = 'extra_storage' AND p.name IN ('Basic Plan', 'Pro Plan', 'Enterprise Plan');
+
+INSERT INTO plan_add_ons (plan_id, add_on_id, max_quantity)
+SELECT p.id, a.id, 1 FROM plans p, add_ons a
+WHERE a.code = 'priority_support' AND p.name IN ('Basic Plan', 'Pro Plan');
@@ -0,0 +1,14 @@
+ALTER TABLE plans
+    ADD COLUMN currency CHAR(3) NOT NULL DEFAULT 'USD',
+    ADD COLUMN pricing_model VARCHAR(20) NOT NULL DEFAULT 'flat'
+        CHECK (pricing_model IN ('flat', 'per_unit', 'graduated', 'volume')),
+    ADD COLUMN price_tiers JSONB NOT NULL DEFAULT '[]';
+
+-- For flat plans price is the charge per period, for per_unit plans it is the
+-- charge per unit; graduated and volume plans are priced from price_tiers.
+
+INSERT INTO plans (name, price, features, duration_days, interval_unit, interval_count, entitlements, pricing_model, price_tiers)
+VALUES
+('Team Plan', 0, '["Feature A", "Feature B", "Feature C"]', 30, 'month', 1,
+ '{"feature_a": true, "feature_b": true, "feature_c": true, "projects": 50, "storage_gb": 500, "support_tier": "standard"}',
+ 'graduated', '[{"up_to": 10, "unit_price": 8}, {"up_to": 50, "unit_price": 6}, {"unit_price": 4}]');
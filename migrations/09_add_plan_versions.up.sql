@@ -0,0 +1,13 @@
+-- Plans are identified by a stable code. Changing the sold terms of a plan
+-- archives the current version and creates the next one, so existing
+-- subscribers keep the terms they bought.
+ALTER TABLE plans
+    ADD COLUMN code VARCHAR(100),
+    ADD COLUMN version INTEGER NOT NULL DEFAULT 1,
+    ADD COLUMN archived_at TIMESTAMPTZ;
+
+UPDATE plans SET code = lower(regexp_replace(regexp_replace(name, '\s+Plan$', ''), '[^A-Za-z0-9]+', '_', 'g'));
+
+ALTER TABLE plans ALTER COLUMN code SET NOT NULL;
+ALTER TABLE plans ADD CONSTRAINT plans_code_version_key UNIQUE (code, version);
+CREATE UNIQUE INDEX plans_current_code_idx ON plans (code) WHERE archived_at IS NULL;
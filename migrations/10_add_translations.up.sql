@@ -0,0 +1,19 @@
+-- Translations are keyed by plan code so every version of a plan shares them.
+CREATE TABLE plan_translations (
+    plan_code VARCHAR(100) NOT NULL,
+    locale VARCHAR(35) NOT NULL,
+    name VARCHAR(100) NOT NULL,
+    features JSONB NOT NULL DEFAULT '[]',
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    PRIMARY KEY (plan_code, locale)
+);
+
+CREATE TABLE entitlement_translations (
+    entitlement_key VARCHAR(100) NOT NULL REFERENCES entitlement_definitions(key) ON DELETE CASCADE,
+    locale VARCHAR(35) NOT NULL,
+    description TEXT NOT NULL,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    PRIMARY KEY (entitlement_key, locale)
+);
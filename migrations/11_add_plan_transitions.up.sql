@@ -0,0 +1,20 @@
+-- Rules for moving a subscription between plans, keyed by plan code. Plan
+-- pairs without a rule follow the default policy derived from their prices.
+CREATE TABLE plan_transitions (
+    from_plan_code VARCHAR(100) NOT NULL,
+    to_plan_code VARCHAR(100) NOT NULL,
+    kind VARCHAR(20) NOT NULL CHECK (kind IN ('upgrade', 'downgrade', 'lateral', 'forbidden')),
+    timing VARCHAR(20) NOT NULL DEFAULT '' CHECK (timing IN ('', 'immediate', 'period_end')),
+    prorate BOOLEAN NOT NULL DEFAULT FALSE,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    PRIMARY KEY (from_plan_code, to_plan_code),
+    CHECK (from_plan_code <> to_plan_code)
+);
+
+-- A plan change scheduled for the end of the paid period.
+ALTER TABLE subscriptions
+    ADD COLUMN pending_plan_id INTEGER REFERENCES plans(id),
+    ADD COLUMN pending_change_at TIMESTAMPTZ;
+
+CREATE INDEX subscriptions_pending_change_at_idx ON subscriptions (pending_change_at) WHERE pending_plan_id IS NOT NULL;
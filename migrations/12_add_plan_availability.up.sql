@@ -0,0 +1,15 @@
+-- Launch offers: plans sold only inside a time window and/or to the first
+-- max_subscribers subscribers. subscriber_count counts the places taken.
+ALTER TABLE plans
+    ADD COLUMN available_from TIMESTAMPTZ,
+    ADD COLUMN available_until TIMESTAMPTZ,
+    ADD COLUMN max_subscribers INTEGER CHECK (max_subscribers > 0),
+    ADD COLUMN subscriber_count INTEGER NOT NULL DEFAULT 0,
+    ADD CONSTRAINT plans_availability_window_check CHECK (available_until > available_from),
+    ADD CONSTRAINT plans_subscriber_cap_check CHECK (subscriber_count <= max_subscribers);
+
+-- Subscriptions that already ended do not take a place on an existing plan.
+UPDATE plans SET subscriber_count = (
+    SELECT COUNT(*) FROM subscriptions
+    WHERE subscriptions.plan_id = plans.id AND subscriptions.status NOT IN ('CANCELLED', 'EXPIRED')
+);
@@ -0,0 +1,35 @@
+-- The price a subscription was sold at, locked in at purchase.
+ALTER TABLE subscriptions ADD COLUMN price DOUBLE PRECISION;
+UPDATE subscriptions SET price = plans.price FROM plans WHERE plans.id = subscriptions.plan_id;
+ALTER TABLE subscriptions ALTER COLUMN price SET NOT NULL;
+
+CREATE TABLE experiments (
+    id SERIAL PRIMARY KEY,
+    name VARCHAR(100) NOT NULL,
+    plan_id INTEGER NOT NULL REFERENCES plans(id),
+    started_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    ended_at TIMESTAMPTZ,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+-- A plan runs at most one experiment at a time.
+CREATE UNIQUE INDEX experiments_running_plan_idx ON experiments (plan_id) WHERE ended_at IS NULL;
+
+CREATE TABLE experiment_variants (
+    id SERIAL PRIMARY KEY,
+    experiment_id INTEGER NOT NULL REFERENCES experiments(id) ON DELETE CASCADE,
+    name VARCHAR(100) NOT NULL,
+    price DOUBLE PRECISION NOT NULL CHECK (price >= 0),
+    weight INTEGER NOT NULL CHECK (weight > 0),
+    UNIQUE (experiment_id, name)
+);
+
+CREATE TABLE experiment_assignments (
+    experiment_id INTEGER NOT NULL REFERENCES experiments(id) ON DELETE CASCADE,
+    user_id INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    variant_id INTEGER NOT NULL REFERENCES experiment_variants(id) ON DELETE CASCADE,
+    assigned_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    converted_at TIMESTAMPTZ,
+    PRIMARY KEY (experiment_id, user_id)
+);
@@ -0,0 +1,19 @@
+CREATE TABLE organizations (
+    id SERIAL PRIMARY KEY,
+    name VARCHAR(100) NOT NULL,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+ALTER TABLE users ADD COLUMN organization_id INTEGER REFERENCES organizations(id) ON DELETE SET NULL;
+
+-- Private plans are derived from a public base plan and sold only to one
+-- user or to the members of one organization.
+ALTER TABLE plans
+    ADD COLUMN base_plan_id INTEGER REFERENCES plans(id),
+    ADD COLUMN owner_user_id INTEGER REFERENCES users(id),
+    ADD COLUMN organization_id INTEGER REFERENCES organizations(id),
+    ADD CONSTRAINT plans_single_owner_check CHECK (owner_user_id IS NULL OR organization_id IS NULL);
+
+CREATE INDEX plans_owner_user_idx ON plans (owner_user_id) WHERE owner_user_id IS NOT NULL;
+CREATE INDEX plans_organization_idx ON plans (organization_id) WHERE organization_id IS NOT NULL;
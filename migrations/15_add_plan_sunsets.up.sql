@@ -0,0 +1,35 @@
+-- Messages to users, delivered by a background job once send_at passes.
+CREATE TABLE notifications (
+    id SERIAL PRIMARY KEY,
+    user_id INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    kind VARCHAR(50) NOT NULL,
+    subject VARCHAR(200) NOT NULL,
+    body TEXT NOT NULL,
+    send_at TIMESTAMPTZ NOT NULL,
+    sent_at TIMESTAMPTZ,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX notifications_due_idx ON notifications (send_at) WHERE sent_at IS NULL;
+
+CREATE TABLE plan_sunsets (
+    id SERIAL PRIMARY KEY,
+    plan_code VARCHAR(100) NOT NULL,
+    replacement_plan_id INTEGER NOT NULL REFERENCES plans(id),
+    notice_days INTEGER NOT NULL CHECK (notice_days >= 0),
+    announced_at TIMESTAMPTZ NOT NULL,
+    completed_at TIMESTAMPTZ,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+-- A plan code is retired by at most one sunset at a time.
+CREATE UNIQUE INDEX plan_sunsets_open_code_idx ON plan_sunsets (plan_code) WHERE completed_at IS NULL;
+
+CREATE TABLE sunset_subscribers (
+    sunset_id INTEGER NOT NULL REFERENCES plan_sunsets(id) ON DELETE CASCADE,
+    subscription_id INTEGER NOT NULL REFERENCES subscriptions(id) ON DELETE CASCADE,
+    migrate_at TIMESTAMPTZ NOT NULL,
+    notified_at TIMESTAMPTZ NOT NULL,
+    PRIMARY KEY (sunset_id, subscription_id)
+);
@@ -0,0 +1,25 @@
+-- Bulk moves of subscriptions from one plan to another, processed in chunks.
+CREATE TABLE migration_jobs (
+    id SERIAL PRIMARY KEY,
+    from_plan_id INTEGER NOT NULL REFERENCES plans(id),
+    to_plan_id INTEGER NOT NULL REFERENCES plans(id),
+    dry_run BOOLEAN NOT NULL DEFAULT FALSE,
+    chunk_size INTEGER NOT NULL CHECK (chunk_size > 0),
+    status VARCHAR(20) NOT NULL,
+    started_at TIMESTAMPTZ,
+    finished_at TIMESTAMPTZ,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE TABLE migration_job_items (
+    job_id INTEGER NOT NULL REFERENCES migration_jobs(id) ON DELETE CASCADE,
+    subscription_id INTEGER NOT NULL,
+    user_id INTEGER NOT NULL,
+    status VARCHAR(20) NOT NULL,
+    error TEXT NOT NULL DEFAULT '',
+    processed_at TIMESTAMPTZ,
+    PRIMARY KEY (job_id, subscription_id)
+);
+
+CREATE INDEX migration_job_items_pending_idx ON migration_job_items (job_id, subscription_id) WHERE status = 'pending';
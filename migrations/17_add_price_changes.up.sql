@@ -0,0 +1,25 @@
+-- Price changes rolled out to the existing subscribers of a plan at their
+-- first renewal after a notice period.
+CREATE TABLE price_changes (
+    id SERIAL PRIMARY KEY,
+    plan_id INTEGER NOT NULL REFERENCES plans(id),
+    new_price DOUBLE PRECISION NOT NULL CHECK (new_price >= 0),
+    notice_days INTEGER NOT NULL CHECK (notice_days >= 0),
+    announced_at TIMESTAMPTZ NOT NULL,
+    completed_at TIMESTAMPTZ,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE TABLE price_change_subscribers (
+    price_change_id INTEGER NOT NULL REFERENCES price_changes(id) ON DELETE CASCADE,
+    subscription_id INTEGER NOT NULL,
+    user_id INTEGER NOT NULL,
+    old_price DOUBLE PRECISION NOT NULL,
+    status VARCHAR(20) NOT NULL,
+    effective_at TIMESTAMPTZ NOT NULL,
+    applied_at TIMESTAMPTZ,
+    PRIMARY KEY (price_change_id, subscription_id)
+);
+
+CREATE INDEX price_change_subscribers_due_idx ON price_change_subscribers (effective_at) WHERE status = 'scheduled';
@@ -0,0 +1,9 @@
+-- Subscriptions are kept after they end. A user has at most one current
+-- subscription and any number of past ones.
+ALTER TABLE subscriptions
+    ADD COLUMN is_current BOOLEAN NOT NULL DEFAULT TRUE,
+    ADD COLUMN cancelled_at TIMESTAMPTZ;
+
+ALTER TABLE subscriptions DROP CONSTRAINT subscriptions_user_id_key;
+CREATE UNIQUE INDEX subscriptions_current_user_idx ON subscriptions (user_id) WHERE is_current;
+CREATE INDEX subscriptions_user_idx ON subscriptions (user_id, created_at);
@@ -0,0 +1,20 @@
+-- Cancellations run until the end of the paid period by default; immediate
+-- cancellations may refund the unused part of the period.
+ALTER TABLE subscriptions ADD COLUMN cancel_at_period_end BOOLEAN NOT NULL DEFAULT FALSE;
+
+CREATE INDEX subscriptions_cancel_at_period_end_idx ON subscriptions (end_date) WHERE is_current AND cancel_at_period_end;
+
+CREATE TABLE billing_line_items (
+    id SERIAL PRIMARY KEY,
+    user_id INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    subscription_id INTEGER NOT NULL REFERENCES subscriptions(id) ON DELETE CASCADE,
+    kind VARCHAR(50) NOT NULL,
+    description TEXT NOT NULL,
+    amount DOUBLE PRECISION NOT NULL,
+    currency VARCHAR(3) NOT NULL,
+    period_start TIMESTAMPTZ NOT NULL,
+    period_end TIMESTAMPTZ NOT NULL,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX billing_line_items_subscription_idx ON billing_line_items (subscription_id);
@@ -0,0 +1,7 @@
+-- Paused subscriptions are INACTIVE; their remaining time is frozen from
+-- paused_at until they resume, by hand or automatically at resume_at.
+ALTER TABLE subscriptions
+    ADD COLUMN paused_at TIMESTAMPTZ,
+    ADD COLUMN resume_at TIMESTAMPTZ;
+
+CREATE INDEX subscriptions_resume_at_idx ON subscriptions (resume_at) WHERE status = 'INACTIVE';
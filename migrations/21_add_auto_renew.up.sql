@@ -0,0 +1,6 @@
+-- Subscriptions renew automatically at the end of their paid period unless
+-- the user turns it off.
+ALTER TABLE subscriptions ADD COLUMN auto_renew BOOLEAN NOT NULL DEFAULT TRUE;
+
+CREATE INDEX subscriptions_renewal_due_idx ON subscriptions (end_date)
+    WHERE is_current AND status = 'ACTIVE' AND auto_renew AND NOT cancel_at_period_end AND paused_at IS NULL;
@@ -0,0 +1,15 @@
+-- A subscription whose renewal payment failed is PAST_DUE: it keeps its
+-- entitlements while payment is retried until the grace period ends.
+ALTER TYPE subscription_status ADD VALUE 'PAST_DUE';
+
+ALTER TABLE subscriptions ADD COLUMN past_due_since TIMESTAMPTZ;
+ALTER TABLE subscriptions ADD COLUMN grace_ends_at TIMESTAMPTZ;
+ALTER TABLE subscriptions ADD COLUMN next_retry_at TIMESTAMPTZ;
+ALTER TABLE subscriptions ADD COLUMN payment_retries INTEGER NOT NULL DEFAULT 0;
+
+-- The new enum value cannot be used in the transaction that adds it, so the
+-- indexes filter on the dunning columns instead of the status.
+CREATE INDEX subscriptions_next_retry_idx ON subscriptions (next_retry_at)
+    WHERE next_retry_at IS NOT NULL;
+CREATE INDEX subscriptions_grace_ends_idx ON subscriptions (grace_ends_at)
+    WHERE grace_ends_at IS NOT NULL;
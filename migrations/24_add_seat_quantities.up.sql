@@ -0,0 +1,14 @@
+-- Subscriptions buy a number of seats of their plan, within bounds set by
+-- the plan, and the seats are assigned to users.
+ALTER TABLE plans ADD COLUMN min_quantity INTEGER NOT NULL DEFAULT 1 CHECK (min_quantity >= 1);
+ALTER TABLE plans ADD COLUMN max_quantity INTEGER CHECK (max_quantity >= min_quantity);
+
+ALTER TABLE subscriptions ADD COLUMN quantity INTEGER NOT NULL DEFAULT 1 CHECK (quantity >= 1);
+
+CREATE TABLE seat_assignments (
+    id SERIAL PRIMARY KEY,
+    subscription_id INTEGER NOT NULL REFERENCES subscriptions(id) ON DELETE CASCADE,
+    user_id INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    UNIQUE (subscription_id, user_id)
+);
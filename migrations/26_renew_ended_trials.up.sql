@@ -0,0 +1,6 @@
+-- Ended free trials are renewed into their first paid period by the renewal
+-- worker, so the index it scans covers trialing subscriptions too.
+DROP INDEX subscriptions_renewal_due_idx;
+
+CREATE INDEX subscriptions_renewal_due_idx ON subscriptions (end_date)
+    WHERE is_current AND status IN ('ACTIVE', 'TRIALING') AND auto_renew AND NOT cancel_at_period_end AND paused_at IS NULL;